@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// PackedItem is an item or logistic unit packed in a logistic unit. Contents
+// is only set for logistic units.
+type PackedItem struct {
+	Item     string
+	Contents []PackedItem
+}
+
+// hashContents returns the hash of the items packed in a logistic unit
+func hashContents(contents []PackedItem) []byte {
+	var fields [][]byte
+
+	for _, packed := range contents {
+		fields = append(fields, []byte(packed.Item), hashContents(packed.Contents))
+	}
+
+	return HashFields(fields...)
+}
+
+// walkContents calls fn with item and every item packed in it at any depth,
+// along with the logistic units containing them, outermost first
+func walkContents(item string, contents []PackedItem, containers []string, fn func(item string, contents []PackedItem, containers []string)) {
+	fn(item, contents, containers)
+
+	inner := append(append([]string{}, containers...), item)
+	for _, packed := range contents {
+		walkContents(packed.Item, packed.Contents, inner, fn)
+	}
+}
+
+// Carries returns the logistic units, outermost first, containing item in the
+// output and whether the output carries item at all
+func (out *TXOutput) Carries(item string) ([]string, bool) {
+	var found []string
+	carried := false
+
+	walkContents(out.Item, out.Contents, nil, func(i string, _ []PackedItem, containers []string) {
+		if i == item {
+			found = containers
+			carried = true
+		}
+	})
+
+	return found, carried
+}
+
+// CarriedItems returns the item of the output and every item packed in it
+func (out *TXOutput) CarriedItems() []string {
+	var items []string
+
+	walkContents(out.Item, out.Contents, nil, func(item string, _ []PackedItem, _ []string) {
+		items = append(items, item)
+	})
+
+	return items
+}
+
+// formatContents lists packed items, each logistic unit followed by its
+// contents in brackets
+func formatContents(contents []PackedItem) string {
+	var items []string
+
+	for _, packed := range contents {
+		if len(packed.Contents) > 0 {
+			items = append(items, fmt.Sprintf("%s [%s]", packed.Item, formatContents(packed.Contents)))
+		} else {
+			items = append(items, packed.Item)
+		}
+	}
+
+	return strings.Join(items, ", ")
+}
+
+// carriedContents maps every item carried by outs to the hash of its contents
+func carriedContents(outs []TXOutput) (map[string]string, error) {
+	carried := make(map[string]string)
+	var err error
+
+	for _, out := range outs {
+		walkContents(out.Item, out.Contents, nil, func(item string, contents []PackedItem, _ []string) {
+			if _, ok := carried[item]; ok && err == nil {
+				err = fmt.Errorf("Item %s is referenced twice", item)
+			}
+			carried[item] = hex.EncodeToString(hashContents(contents))
+		})
+	}
+
+	return carried, err
+}
+
+// NewPackTransaction packs items held by address into a new logistic unit
+// identified by an SSCC under the prefix of its organisation
+func NewPackTransaction(wallet Wallet, address string, items []string, UTXOSet *UTXOSet) (*Transaction, error) {
+	org, err := UTXOSet.Blockchain.FindOrganisationByPublicKey(wallet.PublicKey)
+	if err != nil {
+		return nil, errors.New("Organisation not found")
+	}
+
+	return NewPackTransactionInto(wallet, address, UTXOSet.Blockchain.GenerateSSCC(string(org.Prefix)), items, UTXOSet)
+}
+
+// NewPackTransactionInto packs items held by address into the logistic unit
+// identified by the SSCC unit
+func NewPackTransactionInto(wallet Wallet, address string, unit string, items []string, UTXOSet *UTXOSet) (*Transaction, error) {
+	inputs, outputs, err := UTXOSet.findOutputsOf(wallet, items)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []PackedItem
+	for _, out := range outputs {
+		contents = append(contents, PackedItem{out.Item, out.Contents})
+	}
+
+	out := NewTXOutput(0, unit, address)
+	out.Contents = contents
+
+	tx := Transaction{nil, inputs, []TXOutput{*out}}
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+
+	return &tx, nil
+}
+
+// NewUnpackTransaction unpacks a logistic unit held by address into the items
+// packed in it
+func NewUnpackTransaction(wallet Wallet, address string, unit string, UTXOSet *UTXOSet) (*Transaction, error) {
+	if _, _, err := ParseSSCC(unit); err != nil {
+		return nil, err
+	}
+
+	inputs, outputs, err := UTXOSet.findOutputsOf(wallet, []string{unit})
+	if err != nil {
+		return nil, err
+	}
+
+	tx := Transaction{nil, inputs, nil}
+	for i, packed := range outputs[0].Contents {
+		out := NewTXOutput(i, packed.Item, address)
+		out.Contents = packed.Contents
+		tx.Vout = append(tx.Vout, *out)
+	}
+
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+
+	return &tx, nil
+}
+
+// findOutputsOf returns inputs spending the unspent outputs of wallet that
+// carry items at the top level, along with those outputs in the order of items
+func (u UTXOSet) findOutputsOf(wallet Wallet, items []string) ([]TXInput, []TXOutput, error) {
+	var inputs []TXInput
+	byItem := make(map[string]TXOutput)
+
+	found, validOutputs := u.FindSpendableOutputs(HashPubKey(wallet.PublicKey), items)
+	if found != len(items) {
+		return nil, nil, errors.New("Item not found")
+	}
+
+	for txid, outs := range validOutputs {
+		txID, err := hex.DecodeString(txid)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		for _, index := range outs {
+			out, _ := u.FindUnspentOutput(txID, index)
+			byItem[out.Item] = out
+			inputs = append(inputs, TXInput{txID, index, nil, wallet.PublicKey, nil})
+		}
+	}
+
+	var outputs []TXOutput
+	for _, item := range items {
+		outputs = append(outputs, byItem[item])
+	}
+
+	return inputs, outputs, nil
+}
+
+// GenerateSSCC returns the next SSCC of the logistic units packed under prefix
+func (bc *Blockchain) GenerateSSCC(prefix string) string {
+	serial := 0
+
+	bc.forEachCarriedItem(func(item string) bool {
+		p, s, err := ParseSSCC(item)
+		if err == nil && p == prefix && s > serial {
+			serial = s
+		}
+		return true
+	})
+
+	return FormatSSCC(prefix, serial+1)
+}
+
+// IsPacked checks whether a logistic unit has ever been recorded on the blockchain
+func (bc *Blockchain) IsPacked(unit string) bool {
+	found := false
+
+	bc.forEachCarriedItem(func(item string) bool {
+		found = item == unit
+		return !found
+	})
+
+	return found
+}
+
+// forEachCarriedItem calls fn with every item carried by an output on the
+// blockchain, packed or not, until fn returns false
+func (bc *Blockchain) forEachCarriedItem(fn func(item string) bool) {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions() {
+			for _, out := range tx.Vout {
+				for _, item := range out.CarriedItems() {
+					if !fn(item) {
+						return
+					}
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+}
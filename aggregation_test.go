@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPacking(t *testing.T) {
+	tests := []struct {
+		name string
+		// pack returns the transaction to validate, given the manufacturer
+		// holding 0123.1.1 to 0123.1.3
+		pack  func(c *testChain, maker *Wallet) (*Transaction, error)
+		valid bool
+	}{
+		{"pack items", func(c *testChain, maker *Wallet) (*Transaction, error) {
+			return NewPackTransaction(*maker, addressOf(maker), []string{"0123.1.1", "0123.1.2"}, &UTXOSet{c.bc})
+		}, true},
+		{"pack a unit into a unit", func(c *testChain, maker *Wallet) (*Transaction, error) {
+			c.pack(maker, "0123.1.1", "0123.1.2")
+			return NewPackTransaction(*maker, addressOf(maker), []string{"0123.1", "0123.1.3"}, &UTXOSet{c.bc})
+		}, true},
+		{"unpack a unit", func(c *testChain, maker *Wallet) (*Transaction, error) {
+			c.pack(maker, "0123.1.1", "0123.1.2")
+			return NewUnpackTransaction(*maker, addressOf(maker), "0123.1", &UTXOSet{c.bc})
+		}, true},
+		{"unit under the prefix of another organisation", func(c *testChain, maker *Wallet) (*Transaction, error) {
+			return NewPackTransactionInto(*maker, addressOf(maker), "0124.1", []string{"0123.1.1"}, &UTXOSet{c.bc})
+		}, false},
+		{"unit packed before", func(c *testChain, maker *Wallet) (*Transaction, error) {
+			c.pack(maker, "0123.1.1")
+			tx, err := NewUnpackTransaction(*maker, addressOf(maker), "0123.1", &UTXOSet{c.bc})
+			if err != nil {
+				return nil, err
+			}
+			c.mustMine(NewTransactionEntries([]*Transaction{tx})...)
+			return NewPackTransactionInto(*maker, addressOf(maker), "0123.1", []string{"0123.1.2"}, &UTXOSet{c.bc})
+		}, false},
+		{"unit dropping a packed item", func(c *testChain, maker *Wallet) (*Transaction, error) {
+			tx, err := NewPackTransaction(*maker, addressOf(maker), []string{"0123.1.1", "0123.1.2"}, &UTXOSet{c.bc})
+			if err != nil {
+				return nil, err
+			}
+			tx.Vout[0].Contents = tx.Vout[0].Contents[:1]
+			c.resign(tx, maker)
+			return tx, nil
+		}, false},
+		{"unpacking dropping a packed item", func(c *testChain, maker *Wallet) (*Transaction, error) {
+			c.pack(maker, "0123.1.1", "0123.1.2")
+			tx, err := NewUnpackTransaction(*maker, addressOf(maker), "0123.1", &UTXOSet{c.bc})
+			if err != nil {
+				return nil, err
+			}
+			tx.Vout = tx.Vout[:1]
+			c.resign(tx, maker)
+			return tx, nil
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestChain(t, powConsensus, nil)
+			maker := c.register("Maker", "0123", "Manufacturer")
+			c.register("Dist", "0124", "Distributor")
+			c.addProducts(maker, "soap")
+			c.mint(maker, items("0123", 1, 3)...)
+
+			tx, err := test.pack(c, maker)
+			if err == nil {
+				err = c.mine(NewTransactionEntries([]*Transaction{tx})...)
+			}
+
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("packing returned %v, expected valid %t", err, test.valid)
+			}
+		})
+	}
+}
+
+// pack records the packing of items held by wallet into a new logistic unit
+func (c *testChain) pack(wallet *Wallet, items ...string) {
+	c.t.Helper()
+
+	tx, err := NewPackTransaction(*wallet, addressOf(wallet), items, &UTXOSet{c.bc})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	c.mustMine(NewTransactionEntries([]*Transaction{tx})...)
+}
+
+// resign signs a transaction changed after it was built
+func (c *testChain) resign(tx *Transaction, wallet *Wallet) {
+	for i := range tx.Vin {
+		tx.Vin[i].Signature = nil
+	}
+	tx.ID = tx.Hash()
+
+	c.bc.SignTransaction(tx, wallet.PrivateKey)
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiError is the body of every error response
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// StartAPI serves the HTTP API of the node on address
+func StartAPI(address string, bc *Blockchain) {
+	fmt.Printf("Serving HTTP API on %s\n", address)
+
+	err := http.ListenAndServe(address, newAPIMux(bc))
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// newAPIMux routes the endpoints of the HTTP API:
+//
+//	GET  /inventory/{address}      items owned by an address or a former address of its organisation
+//	GET  /items/{item}             chain of custody of an item or unit, oldest first,
+//	                               given as SGTIN, SSCC, EPC URI, element string or Digital Link
+//	GET  /verify/{item}            whether an SGTIN, given in any of the forms above, is genuine
+//	GET  /lots/{lot}               items minted with a lot number and their owners
+//	GET  /epcis                    EPCIS 2.0 document of all events, oldest first
+//	GET  /epcis/{item}             EPCIS 2.0 document of the events of an item or unit
+//	GET  /organisations            all organisations in registration order, with their status and current key
+//	GET  /organisations/{key}      organisation by any address or hex public key it held, with its status and current key
+//	GET  /products/{address}       catalog of a manufacturer, under any of its keys, at the current
+//	                               version of each product or as of block ?height=HEIGHT
+//	GET  /recalls                  all recalls in registration order
+//	GET  /recalls/holders          addresses holding recalled items
+//	GET  /policy                   transfer policy of the genesis block
+//	GET  /proposals                registry changes waiting for the approval of admins
+//	GET  /blocks                   all blocks, newest first
+//	GET  /blocks/{hash}            block by hash
+//	GET  /transactions/{id}        transaction by ID
+//	POST /transactions             submit a signed transaction
+func newAPIMux(bc *Blockchain) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/inventory/", apiGet(bc, handleAPIInventory))
+	mux.HandleFunc("/items/", apiGet(bc, handleAPIItem))
+	mux.HandleFunc("/verify/", apiGet(bc, handleAPIVerifyItem))
+	mux.HandleFunc("/lots/", apiGet(bc, handleAPILot))
+	mux.HandleFunc("/epcis", apiGet(bc, handleAPIEPCIS))
+	mux.HandleFunc("/epcis/", apiGet(bc, handleAPIItemEPCIS))
+	mux.HandleFunc("/organisations", apiGet(bc, handleAPIOrganisations))
+	mux.HandleFunc("/organisations/", apiGet(bc, handleAPIOrganisation))
+	mux.HandleFunc("/products/", apiGetAt(bc, handleAPIProducts))
+	mux.HandleFunc("/recalls", apiGet(bc, handleAPIRecalls))
+	mux.HandleFunc("/recalls/holders", apiGet(bc, handleAPIRecallHolders))
+	mux.HandleFunc("/policy", apiGet(bc, handleAPIPolicy))
+	mux.HandleFunc("/proposals", apiGet(bc, handleAPIProposals))
+	mux.HandleFunc("/blocks", apiGet(bc, handleAPIBlocks))
+	mux.HandleFunc("/blocks/", apiGet(bc, handleAPIBlock))
+	mux.HandleFunc("/transactions/", apiGet(bc, handleAPITransaction))
+	mux.HandleFunc("/transactions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeAPIError(w, http.StatusMethodNotAllowed, "Use POST to submit a transaction")
+			return
+		}
+
+		nodeLock.Lock()
+		defer nodeLock.Unlock()
+		defer recoverAPIError(w)
+		handleAPISubmitTransaction(w, r, bc)
+	})
+
+	return mux
+}
+
+// apiGet wraps a read-only handler that receives the last segment of the path,
+// unescaped
+func apiGet(bc *Blockchain, handler func(http.ResponseWriter, string, *Blockchain)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAPIError(w, http.StatusMethodNotAllowed, "Use GET")
+			return
+		}
+
+		nodeLock.Lock()
+		defer nodeLock.Unlock()
+		defer recoverAPIError(w)
+
+		parts := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+		segment, err := url.PathUnescape(parts[len(parts)-1])
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "Path is not escaped")
+			return
+		}
+		handler(w, segment, bc)
+	}
+}
+
+// apiGetAt wraps a read-only handler that also receives the block height given
+// as ?height=HEIGHT, the height of the tip by default
+func apiGetAt(bc *Blockchain, handler func(http.ResponseWriter, string, int, *Blockchain)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.URL.Query().Get("height")
+
+		apiGet(bc, func(w http.ResponseWriter, segment string, bc *Blockchain) {
+			best := bc.GetBestHeight()
+			if value == "" {
+				handler(w, segment, best, bc)
+				return
+			}
+
+			height, err := strconv.Atoi(value)
+			if err != nil || height < 0 || height > best {
+				writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("Height must be a block height from 0 to %d", best))
+				return
+			}
+			handler(w, segment, height, bc)
+		})(w, r)
+	}
+}
+
+// recoverAPIError turns a panic of a handler into an error response
+func recoverAPIError(w http.ResponseWriter) {
+	if r := recover(); r != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprint(r))
+	}
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		fmt.Printf("Cannot write API response: %s\n", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIResponse(w, status, apiError{message})
+}
+
+func handleAPIInventory(w http.ResponseWriter, address string, bc *Blockchain) {
+	if !ValidateAddress(address) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	pubKeyHash := Base58Decode([]byte(address))
+	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+
+	recalls := bc.GetRecalls()
+	batches := bc.GetBatches()
+	now := time.Now()
+
+	inventoryView := func(out TXOutput) OutputView {
+		view := NewOutputView(out)
+		view.Recalled = findRecall(recalls, out.Item) != nil
+		if markRecalled(view.Contents, recalls) {
+			view.Recalled = true
+		}
+		view.Batch = NewBatchStatusView(batches[out.Item], now)
+		markBatches(view.Contents, batches, now)
+		return view
+	}
+
+	UTXOSet := UTXOSet{bc}
+	inventory := InventoryView{address, []OutputView{}, []OutputView{}, []OutputView{}}
+	for _, out := range UTXOSet.FindUTXO(pubKeyHash) {
+		if out.IsOffered() {
+			inventory.Outgoing = append(inventory.Outgoing, inventoryView(out))
+		} else {
+			inventory.Items = append(inventory.Items, inventoryView(out))
+		}
+	}
+	for _, out := range UTXOSet.FindOffers(pubKeyHash) {
+		inventory.Incoming = append(inventory.Incoming, inventoryView(out))
+	}
+
+	writeAPIResponse(w, http.StatusOK, inventory)
+}
+
+func handleAPIItem(w http.ResponseWriter, key string, bc *Blockchain) {
+	item, err := bc.ResolveItem(key)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	history := NewItemHistoryView(bc.TraceItem(item), bc.GetOrganisations(), bc.GetKeyRotations())
+	if len(history.Events) == 0 {
+		writeAPIError(w, http.StatusNotFound, "Item is not found")
+		return
+	}
+	history.Batch = NewBatchStatusView(bc.GetBatches()[item], time.Now())
+	if ids, err := EncodeGS1(item); err == nil {
+		view := NewGS1View(ids)
+		history.GS1 = &view
+	}
+	if recall := findRecall(bc.GetRecalls(), item); recall != nil {
+		view := NewRecallView(recall)
+		history.Recall = &view
+	}
+
+	writeAPIResponse(w, http.StatusOK, history)
+}
+
+func handleAPIVerifyItem(w http.ResponseWriter, key string, bc *Blockchain) {
+	item, err := bc.ResolveItem(key)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, _, err := ParseSSCC(item); err == nil {
+		writeAPIError(w, http.StatusBadRequest, "Only items identified by an SGTIN can be verified")
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewItemVerificationView(bc.VerifyItem(item), bc.GetOrganisations(), bc.GetKeyRotations()))
+}
+
+func handleAPILot(w http.ResponseWriter, lot string, bc *Blockchain) {
+	items := bc.FindLot(lot)
+	if len(items) == 0 {
+		writeAPIError(w, http.StatusNotFound, "Lot is not found")
+		return
+	}
+
+	recalls := bc.GetRecalls()
+	batches := bc.GetBatches()
+	now := time.Now()
+
+	view := LotView{lot, []LotItemView{}}
+	for _, item := range items {
+		entry := LotItemView{item, "", []string{}, findRecall(recalls, item) != nil, *NewBatchStatusView(batches[item], now)}
+
+		for _, out := range (UTXOSet{bc}).FindItem(item) {
+			entry.Owner = string(Base58Encode(out.PubKeyHash))
+			if containers, _ := out.Carries(item); containers != nil {
+				entry.Containers = containers
+			}
+		}
+
+		view.Items = append(view.Items, entry)
+	}
+
+	writeAPIResponse(w, http.StatusOK, view)
+}
+
+func handleAPIEPCIS(w http.ResponseWriter, _ string, bc *Blockchain) {
+	events, err := bc.ExportEPCIS("")
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewEPCISDocument(events, time.Now()))
+}
+
+func handleAPIItemEPCIS(w http.ResponseWriter, key string, bc *Blockchain) {
+	item, err := bc.ResolveItem(key)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := bc.ExportEPCIS(item)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(events) == 0 {
+		writeAPIError(w, http.StatusNotFound, "Item is not found")
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewEPCISDocument(events, time.Now()))
+}
+
+func handleAPIOrganisations(w http.ResponseWriter, _ string, bc *Blockchain) {
+	changes := bc.GetStatusChanges()
+	rotations := bc.GetKeyRotations()
+	height := bc.GetBestHeight() + 1
+
+	orgs := OrganisationViews{}
+	for _, org := range bc.GetOrganisations() {
+		orgs = append(orgs, NewOrganisationStatusView(&org, changes, rotations, height))
+	}
+
+	writeAPIResponse(w, http.StatusOK, orgs)
+}
+
+func handleAPIOrganisation(w http.ResponseWriter, key string, bc *Blockchain) {
+	var org Organisation
+	var err error
+
+	if ValidateAddress(key) {
+		org, err = bc.FindOrganisationByAddress(key)
+	} else {
+		pubKey, decodeErr := hex.DecodeString(key)
+		if decodeErr != nil {
+			writeAPIError(w, http.StatusBadRequest, "Key is neither an address nor a hex public key")
+			return
+		}
+		org, err = bc.FindOrganisationByPublicKey(pubKey)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewOrganisationStatusView(&org, bc.GetStatusChanges(), bc.GetKeyRotations(), bc.GetBestHeight()+1))
+}
+
+func handleAPIProducts(w http.ResponseWriter, address string, height int, bc *Blockchain) {
+	if !ValidateAddress(address) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	products := ProductViews{}
+	for _, p := range bc.GetCatalog(address, height) {
+		products = append(products, NewCatalogProductView(&p))
+	}
+
+	writeAPIResponse(w, http.StatusOK, products)
+}
+
+func handleAPIRecalls(w http.ResponseWriter, _ string, bc *Blockchain) {
+	recalls := RecallViews{}
+	for _, recall := range bc.GetRecalls() {
+		recalls = append(recalls, NewRecallView(&recall))
+	}
+
+	writeAPIResponse(w, http.StatusOK, recalls)
+}
+
+func handleAPIPolicy(w http.ResponseWriter, _ string, bc *Blockchain) {
+	writeAPIResponse(w, http.StatusOK, NewPolicyView(bc.GetTransferPolicy()))
+}
+
+func handleAPIProposals(w http.ResponseWriter, _ string, bc *Blockchain) {
+	required := bc.RequiredApprovals()
+
+	proposals := ProposalViews{}
+	for _, proposal := range bc.GetProposals() {
+		proposals = append(proposals, NewProposalView(proposal, required))
+	}
+
+	writeAPIResponse(w, http.StatusOK, proposals)
+}
+
+func handleAPIRecallHolders(w http.ResponseWriter, _ string, bc *Blockchain) {
+	holders := RecallHolderViews{}
+	index := make(map[string]int)
+
+	recalls := bc.GetRecalls()
+	for _, out := range (UTXOSet{bc}).FindRecalledOutputs(recalls) {
+		address := string(Base58Encode(out.PubKeyHash))
+
+		i, ok := index[address]
+		if !ok {
+			i = len(holders)
+			index[address] = i
+			holders = append(holders, RecallHolderView{address, nil})
+		}
+		for _, item := range out.CarriedItems() {
+			if findRecall(recalls, item) != nil {
+				holders[i].Items = append(holders[i].Items, item)
+			}
+		}
+	}
+
+	writeAPIResponse(w, http.StatusOK, holders)
+}
+
+func handleAPIBlock(w http.ResponseWriter, hash string, bc *Blockchain) {
+	blockHash, err := hex.DecodeString(hash)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Block hash is not hex")
+		return
+	}
+
+	block, err := bc.GetBlock(blockHash)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	view := NewBlockView(&block)
+	view.Sealed = bc.verifySeal(&block) == nil
+
+	writeAPIResponse(w, http.StatusOK, view)
+}
+
+func handleAPIBlocks(w http.ResponseWriter, _ string, bc *Blockchain) {
+	blocks := BlockViews{}
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		view := NewBlockView(block)
+		view.Sealed = bc.verifySeal(block) == nil
+		blocks = append(blocks, view)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	writeAPIResponse(w, http.StatusOK, blocks)
+}
+
+func handleAPITransaction(w http.ResponseWriter, id string, bc *Blockchain) {
+	txID, err := hex.DecodeString(id)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Transaction ID is not hex")
+		return
+	}
+
+	tx, err := bc.FindTransaction(txID)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewTransactionView(&tx))
+}
+
+func handleAPISubmitTransaction(w http.ResponseWriter, r *http.Request, bc *Blockchain) {
+	var view TransactionView
+
+	err := json.NewDecoder(r.Body).Decode(&view)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Body is not a JSON transaction")
+		return
+	}
+
+	tx, err := view.Transaction()
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = submitTransaction(bc, tx, nodeAddress)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	writeAPIResponse(w, http.StatusAccepted, map[string]string{"id": hex.EncodeToString(tx.ID)})
+}
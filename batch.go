@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// gs1DateLayout is the YYMMDD layout of the dates of GS1 AIs 11 and 17
+const gs1DateLayout = "060102"
+
+// isoDateLayout is the YYYY-MM-DD layout of dates accepted by the CLI and
+// written by the API
+const isoDateLayout = "2006-01-02"
+
+// maxLotLength is the length limit of a batch or lot number, GS1 AI 10
+const maxLotLength = 20
+
+// gs1Characters are the characters GS1 allows in a batch or lot number
+const gs1Characters = "!\"%&'()*+,-./0123456789:;<=>?ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+// nearExpiryDays is how many days before its expiry date an item is near expiry
+const nearExpiryDays = 30
+
+// Expiry status of an item in an inventory
+const expiredStatus = "expired"
+const nearExpiryStatus = "near_expiry"
+
+// Batch holds the production data of minted items: the batch or lot number
+// (GS1 AI 10), the production date (AI 11) and the expiration date (AI 17).
+// Dates are YYMMDD and any field may be empty.
+type Batch struct {
+	Lot          string
+	Manufactured string
+	Expiry       string
+}
+
+// NewBatch builds the production data of minted items. Dates are YYMMDD, where
+// day 00 is the last day of the month as in GS1, or YYYY-MM-DD. It returns nil
+// if no field is set.
+func NewBatch(lot, manufactured, expiry string) (*Batch, error) {
+	if lot == "" && manufactured == "" && expiry == "" {
+		return nil, nil
+	}
+
+	batch := &Batch{lot, "", ""}
+	var err error
+
+	if manufactured != "" {
+		batch.Manufactured, err = parseGS1Date(manufactured)
+		if err != nil {
+			return nil, fmt.Errorf("Manufacturing date %s", err)
+		}
+	}
+	if expiry != "" {
+		batch.Expiry, err = parseGS1Date(expiry)
+		if err != nil {
+			return nil, fmt.Errorf("Expiry date %s", err)
+		}
+	}
+
+	return batch, batch.Validate()
+}
+
+// parseGS1Date reads a YYMMDD or YYYY-MM-DD date and returns it as YYMMDD
+func parseGS1Date(date string) (string, error) {
+	if t, err := time.Parse(isoDateLayout, date); err == nil {
+		// YYMMDD reads years 00 to 68 as 2000 to 2068
+		if t.Year() < 2000 || t.Year() > 2068 {
+			return "", errors.New("is not between 2000 and 2068")
+		}
+		return t.Format(gs1DateLayout), nil
+	}
+
+	if len(date) == len(gs1DateLayout) && strings.HasSuffix(date, "00") {
+		month, err := time.Parse("0601", date[:4])
+		if err != nil {
+			return "", errors.New("is neither YYMMDD nor YYYY-MM-DD")
+		}
+		return month.AddDate(0, 1, -1).Format(gs1DateLayout), nil
+	}
+
+	t, err := time.Parse(gs1DateLayout, date)
+	if err != nil {
+		return "", errors.New("is neither YYMMDD nor YYYY-MM-DD")
+	}
+
+	return t.Format(gs1DateLayout), nil
+}
+
+// gs1Date returns the time of a YYMMDD date, or false if it is not set
+func gs1Date(date string) (time.Time, bool) {
+	t, err := time.Parse(gs1DateLayout, date)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// Validate checks the fields of the production data as recorded on the blockchain
+func (b *Batch) Validate() error {
+	if b.Lot == "" && b.Manufactured == "" && b.Expiry == "" {
+		return errors.New("Batch has no lot number or dates")
+	}
+	if len(b.Lot) > maxLotLength {
+		return fmt.Errorf("Lot number is longer than %d characters", maxLotLength)
+	}
+	for _, c := range b.Lot {
+		if !strings.ContainsRune(gs1Characters, c) {
+			return fmt.Errorf("Lot number contains %q, which GS1 does not allow", c)
+		}
+	}
+
+	manufactured, hasManufactured := gs1Date(b.Manufactured)
+	if b.Manufactured != "" && (!hasManufactured || manufactured.Format(gs1DateLayout) != b.Manufactured) {
+		return errors.New("Manufacturing date is not YYMMDD")
+	}
+	expiry, hasExpiry := gs1Date(b.Expiry)
+	if b.Expiry != "" && (!hasExpiry || expiry.Format(gs1DateLayout) != b.Expiry) {
+		return errors.New("Expiry date is not YYMMDD")
+	}
+	if hasManufactured && hasExpiry && expiry.Before(manufactured) {
+		return errors.New("Expiry date is before the manufacturing date")
+	}
+
+	return nil
+}
+
+// Hash returns the hash of the production data
+func (b *Batch) Hash() []byte {
+	return HashFields([]byte(b.Lot), []byte(b.Manufactured), []byte(b.Expiry))
+}
+
+// ExpiryStatus returns whether an item of the batch is expired or near expiry
+// at now, or an empty string
+func (b *Batch) ExpiryStatus(now time.Time) string {
+	expiry, ok := gs1Date(b.Expiry)
+	if !ok {
+		return ""
+	}
+
+	// the item may be used until the end of its expiry date
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if today.After(expiry) {
+		return expiredStatus
+	}
+	if !today.AddDate(0, 0, nearExpiryDays).Before(expiry) {
+		return nearExpiryStatus
+	}
+
+	return ""
+}
+
+// String returns a human-readable representation of the production data
+func (b Batch) String() string {
+	return NewBatchView(&b).String()
+}
+
+// GetBatches maps the items minted with production data to that data
+func (bc *Blockchain) GetBatches() map[string]*Batch {
+	batches := make(map[string]*Batch)
+
+	bc.forEachMintedOutput(func(out TXOutput) bool {
+		if out.Batch != nil {
+			batches[out.Item] = out.Batch
+		}
+		return true
+	})
+
+	return batches
+}
+
+// FindLot returns the items minted with lot number lot, ordered by product
+// code and serial
+func (bc *Blockchain) FindLot(lot string) []string {
+	var items []string
+
+	bc.forEachMintedOutput(func(out TXOutput) bool {
+		if out.Batch != nil && out.Batch.Lot == lot {
+			items = append(items, out.Item)
+		}
+		return true
+	})
+
+	sort.Slice(items, func(i, j int) bool {
+		pi, ci, si, _ := ParseSGTIN(items[i])
+		pj, cj, sj, _ := ParseSGTIN(items[j])
+		if pi != pj {
+			return pi < pj
+		}
+		if ci != cj {
+			return ci < cj
+		}
+		return si < sj
+	})
+
+	return items
+}
@@ -10,60 +10,149 @@ import (
 // Block represents a block in the blockchain
 type Block struct {
 	Timestamp     int64
-	Transactions  []*Transaction
-	Products      []*Product
-	Organisation  *Organisation
+	Entries       []*Entry
 	PrevBlockHash []byte
 	Hash          []byte
 	Nonce         int
 	Height        int
+	Validator     []byte
+	Signature     []byte
 }
 
-// NewBlock creates and returns Block
-func NewBlock(transactions []*Transaction, products []*Product, organisation *Organisation, prevBlockHash []byte, height int) *Block {
-	var block *Block
-	if transactions != nil {
-		block = &Block{time.Now().Unix(), transactions, nil, nil, prevBlockHash, []byte{}, 0, height}
-	} else if products != nil {
-		block = &Block{time.Now().Unix(), nil, products, nil, prevBlockHash, []byte{}, 0, height}
-	} else {
-		block = &Block{time.Now().Unix(), nil, nil, organisation, prevBlockHash, []byte{}, 0, height}
+// NewBlock creates and returns an unsealed Block
+func NewBlock(entries []*Entry, prevBlockHash []byte, height int) *Block {
+	block := &Block{time.Now().Unix(), entries, prevBlockHash, []byte{}, 0, height, nil, nil}
+
+	return block
+}
+
+// Organisations returns the organisations registered by the block
+func (b *Block) Organisations() []*Organisation {
+	var orgs []*Organisation
+
+	for _, e := range b.Entries {
+		if e.Type == organisationEntry {
+			orgs = append(orgs, e.Organisation)
+		}
 	}
-	pow := NewProofOfWork(block)
-	nonce, hash := pow.Run()
 
-	block.Hash = hash[:]
-	block.Nonce = nonce
+	return orgs
+}
 
-	return block
+// Products returns the catalog entries of the block
+func (b *Block) Products() []*Product {
+	var products []*Product
+
+	for _, e := range b.Entries {
+		if e.Type == productEntry {
+			products = append(products, e.Product)
+		}
+	}
+
+	return products
+}
+
+// Transactions returns the item transfers of the block
+func (b *Block) Transactions() []*Transaction {
+	var txs []*Transaction
+
+	for _, e := range b.Entries {
+		if e.Type == transactionEntry {
+			txs = append(txs, e.Transaction)
+		}
+	}
+
+	return txs
 }
 
-// HashTransactionsOrProducts returns a hash of the transactions or products in the block
-func (b *Block) HashTransactionsOrProducts() []byte {
-	if b.Transactions != nil {
-		var transactions [][]byte
+// Recalls returns the recalls of the block
+func (b *Block) Recalls() []*Recall {
+	var recalls []*Recall
 
-		for _, tx := range b.Transactions {
-			transactions = append(transactions, tx.Serialize())
+	for _, e := range b.Entries {
+		if e.Type == recallEntry {
+			recalls = append(recalls, e.Recall)
 		}
-		mTree := NewMerkleTree(transactions)
+	}
 
-		return mTree.RootNode.Data
-	} else if b.Products != nil {
-		var products [][]byte
+	return recalls
+}
 
-		for _, p := range b.Products {
-			products = append(products, p.Serialize())
+// StatusChanges returns the organisation status changes of the block
+func (b *Block) StatusChanges() []*StatusChange {
+	var changes []*StatusChange
+
+	for _, e := range b.Entries {
+		if e.Type == statusEntry {
+			changes = append(changes, e.Status)
 		}
-		mTree := NewMerkleTree(products)
-
-		return mTree.RootNode.Data
-	} else {
-		var organisations [][]byte
-		organisations = append(organisations, b.Organisation.Serialize())
-		mTree := NewMerkleTree(organisations)
-		return mTree.RootNode.Data
 	}
+
+	return changes
+}
+
+// KeyRotations returns the key rotations of the block
+func (b *Block) KeyRotations() []*KeyRotation {
+	var rotations []*KeyRotation
+
+	for _, e := range b.Entries {
+		if e.Type == keyEntry {
+			rotations = append(rotations, e.Key)
+		}
+	}
+
+	return rotations
+}
+
+// RoleChanges returns the organisation role changes of the block
+func (b *Block) RoleChanges() []*RoleChange {
+	var changes []*RoleChange
+
+	for _, e := range b.Entries {
+		if e.Type == roleEntry {
+			changes = append(changes, e.Role)
+		}
+	}
+
+	return changes
+}
+
+// CatalogUpdates returns the catalog updates of the block
+func (b *Block) CatalogUpdates() []*CatalogUpdate {
+	var updates []*CatalogUpdate
+
+	for _, e := range b.Entries {
+		if e.Type == catalogEntry {
+			updates = append(updates, e.Catalog)
+		}
+	}
+
+	return updates
+}
+
+// ClaimAttempts returns the rejected claims of items recorded in the block
+func (b *Block) ClaimAttempts() []*ClaimAttempt {
+	var attempts []*ClaimAttempt
+
+	for _, e := range b.Entries {
+		if e.Type == claimAttemptEntry {
+			attempts = append(attempts, e.ClaimAttempt)
+		}
+	}
+
+	return attempts
+}
+
+// HashEntries returns the Merkle root of the entries in the block
+func (b *Block) HashEntries() []byte {
+	var entries [][]byte
+
+	for _, e := range b.Entries {
+		entries = append(entries, e.Hash())
+	}
+	mTree := NewMerkleTree(entries)
+
+	return mTree.RootNode.Data
 }
 
 // Serialize serializes the block
@@ -8,42 +8,48 @@ import (
 	"fmt"
 	"github.com/boltdb/bolt"
 	"log"
+	"math/big"
 	"os"
-	"strconv"
 	"strings"
 )
 
 const dbFile = "blockchain_%s.db"
-const transactionsBucket = "blocks"
-const productsBucket = "products"
-const organisationBucket = "organisations"
+const blocksBucket = "ledger"
+const chainworkBucket = "chainwork"
 const genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
 
 // Blockchain implements interactions with a DB
 type Blockchain struct {
-	tip1 []byte
-	tip2 []byte
-	tip3 []byte
-	db   *bolt.DB
+	tip       []byte
+	db        *bolt.DB
+	consensus ConsensusEngine
 }
 
-// CreateBlockchain creates a new blockchain DB
-func CreateBlockchain(name, pubKey, gstin, prefix string, nodeID string) {
+// CreateBlockchain creates a new blockchain DB sealed by the given consensus engine
+// and returns its genesis block. Transfers are restricted by policy unless it is nil,
+// and registry changes need the approvals required by governance unless it is nil.
+func CreateBlockchain(name, pubKey, gstin, prefix, consensus string, policy *TransferPolicy, governance *Governance, nodeID string) *Block {
 	dbFile := fmt.Sprintf(dbFile, nodeID)
-	if dbExists(nodeID) {
+	if dbExists(dbFile) {
 		fmt.Println("Blockchain already exists.")
 		os.Exit(1)
 	}
-	bc := NewBlockchain(nodeID)
-	var tip1 []byte
-	var tip2 []byte
-	var tip3 []byte
 
 	pub, _ := hex.DecodeString(pubKey)
 
 	org := &Organisation{nil, []byte(name), []byte(strings.ToUpper(gstin)), []byte(prefix), []byte("Admin"), nil, pub, nil}
 	org.ID = org.Hash()
-	userGenesis := NewBlock(nil, nil, org, nil, bc.GetBestHeight())
+	entries := []*Entry{NewOrganisationEntry(org)}
+	if policy != nil {
+		entries = append(entries, NewPolicyEntry(policy))
+	}
+	if governance != nil {
+		entries = append(entries, NewGovernanceEntry(governance))
+	}
+
+	userGenesis := NewBlock(entries, nil, 0)
+	// the genesis block is never relayed, so it is always mined
+	PoWEngine{}.Seal(userGenesis)
 
 	db, err := bolt.Open(dbFile, 0600, nil)
 	if err != nil {
@@ -51,36 +57,12 @@ func CreateBlockchain(name, pubKey, gstin, prefix string, nodeID string) {
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucket([]byte(utxoBucket))
-		if err != nil {
-			log.Panic(err)
-		}
-
-		b, err := tx.CreateBucket([]byte(transactionsBucket))
-		if err != nil {
-			log.Panic(err)
-		}
-
-		err = b.Put([]byte("l"), nil)
-		if err != nil {
-			log.Panic(err)
-		}
-
-		b, err = tx.CreateBucket([]byte(productsBucket))
-		if err != nil {
-			log.Panic(err)
-		}
-
-		err = b.Put([]byte("l"), nil)
-		if err != nil {
-			log.Panic(err)
-		}
-
-		b, err = tx.CreateBucket([]byte(organisationBucket))
+		err := createLedgerBuckets(tx, consensus)
 		if err != nil {
 			log.Panic(err)
 		}
 
+		b := tx.Bucket([]byte(blocksBucket))
 		err = b.Put(userGenesis.Hash, userGenesis.Serialize())
 		if err != nil {
 			log.Panic(err)
@@ -90,16 +72,33 @@ func CreateBlockchain(name, pubKey, gstin, prefix string, nodeID string) {
 		if err != nil {
 			log.Panic(err)
 		}
-		tip3 = userGenesis.Hash
 
-		tip1 = nil
-		tip2 = nil
 		return nil
 	})
 
 	if err != nil {
 		log.Panic(err)
 	}
+	db.Close()
+
+	return userGenesis
+}
+
+// createLedgerBuckets creates the buckets of an empty ledger
+func createLedgerBuckets(tx *bolt.Tx, consensus string) error {
+	for _, bucket := range []string{utxoBucket, undoBucket, chainworkBucket, blocksBucket} {
+		_, err := tx.CreateBucket([]byte(bucket))
+		if err != nil {
+			return err
+		}
+	}
+
+	b, err := tx.CreateBucket([]byte(configBucket))
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte("consensus"), []byte(consensus))
 }
 
 // Reset removes all blockchain data
@@ -107,70 +106,88 @@ func (bc *Blockchain) Reset() {
 
 }
 
-// AddBlock saves the block into the blockchain
-func (bc *Blockchain) AddBlock(block *Block) {
-	err := bc.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(transactionsBucket))
-		blockInDb := b.Get(block.Hash)
+// AddBlock saves a block whose parent is known. A block extending the tip is
+// stored only if its entries are valid. When the branch a block ends has more
+// cumulative work than the current tip, the blockchain switches to it. Blocks
+// left on a side branch have their entries validated once their branch
+// becomes the heaviest, as the UTXO set is only kept for the tip.
+func (bc *Blockchain) AddBlock(block *Block) error {
+	var work, tipWork *big.Int
 
-		if blockInDb != nil {
-			return nil
+	if _, err := bc.GetBlock(block.Hash); err == nil {
+		return nil
+	}
+
+	extendsTip := bytes.Equal(block.PrevBlockHash, bc.tip)
+	if extendsTip {
+		err := bc.checkEntries(block)
+		if err != nil {
+			return err
 		}
+	}
+
+	blockWork := bc.consensus.Work(block)
+
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
 		blockData := block.Serialize()
 		err := b.Put(block.Hash, blockData)
 		if err != nil {
 			log.Panic(err)
 		}
 
-		lastHash := b.Get([]byte("l"))
-		lastBlockData := b.Get(lastHash)
-		lastBlock := DeserializeBlock(lastBlockData)
-
-		if block.Height > lastBlock.Height {
-			err = b.Put([]byte("l"), block.Hash)
-			if err != nil {
-				log.Panic(err)
-			}
-			bc.tip1 = block.Hash
+		work = new(big.Int).Add(bc.getChainWork(tx, block.PrevBlockHash), blockWork)
+		err = tx.Bucket([]byte(chainworkBucket)).Put(block.Hash, work.Bytes())
+		if err != nil {
+			log.Panic(err)
 		}
+
+		tipWork = bc.getChainWork(tx, b.Get([]byte("l")))
 		return nil
 	})
 	if err != nil {
 		log.Panic(err)
 	}
+
+	if extendsTip {
+		bc.applyBlock(block)
+		return nil
+	}
+
+	if work.Cmp(tipWork) <= 0 {
+		return nil
+	}
+
+	return bc.reorganize(block)
 }
 
 // NewBlockchain creates a new Blockchain with genesis Block
 func NewBlockchain(nodeID string) *Blockchain {
 	dbFile := fmt.Sprintf(dbFile, nodeID)
-	if dbExists(nodeID) == false {
+	if dbExists(dbFile) == false {
 		fmt.Println("No existing blockchain found. Create one first.")
 		os.Exit(1)
 	}
 
-	var tip1 []byte
-	var tip2 []byte
-	var tip3 []byte
+	var tip []byte
+	consensus := powConsensus
 	db, err := bolt.Open(dbFile, 0600, nil)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(transactionsBucket))
-		tip1 = b.Get([]byte("l"))
-
-		return nil
-	})
-	err = db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(productsBucket))
-		tip2 = b.Get([]byte("l"))
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		if b == nil {
+			return nil
+		}
+		// values returned by bolt are only valid for the life of the transaction
+		tip = append([]byte{}, b.Get([]byte("l"))...)
 
-		return nil
-	})
-	err = db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(organisationBucket))
-		tip3 = b.Get([]byte("l"))
+		// databases created before the consensus engine was selectable use PoW
+		if c := tx.Bucket([]byte(configBucket)); c != nil && c.Get([]byte("consensus")) != nil {
+			consensus = string(c.Get([]byte("consensus")))
+		}
 
 		return nil
 	})
@@ -178,7 +195,19 @@ func NewBlockchain(nodeID string) *Blockchain {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{tip1, tip2, tip3, db}
+	if len(tip) == 0 {
+		db.Close()
+		fmt.Println("Blockchain uses the old three-chain format. Run migrate first.")
+		os.Exit(1)
+	}
+
+	bc := Blockchain{tip, db, nil}
+	bc.consensus, err = newConsensusEngine(consensus, &bc, nodeID)
+	if err != nil {
+		db.Close()
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	return &bc
 }
@@ -188,9 +217,9 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	bci := bc.Iterator()
 
 	for {
-		block := bci.Next1()
+		block := bci.Next()
 
-		for _, tx := range block.Transactions {
+		for _, tx := range block.Transactions() {
 			if bytes.Compare(tx.ID, ID) == 0 {
 				return *tx, nil
 			}
@@ -209,7 +238,7 @@ func (bc *Blockchain) GetBestHeight() int {
 	var lastBlock Block
 
 	err := bc.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(transactionsBucket))
+		b := tx.Bucket([]byte(blocksBucket))
 		lastHash := b.Get([]byte("l"))
 		blockData := b.Get(lastHash)
 		lastBlock = *DeserializeBlock(blockData)
@@ -228,7 +257,7 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 	var block Block
 
 	err := bc.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(transactionsBucket))
+		b := tx.Bucket([]byte(blocksBucket))
 
 		blockData := b.Get(blockHash)
 
@@ -253,45 +282,47 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 	spentTXOs := make(map[string][]int)
 	bci := bc.Iterator()
 
-	if len(bc.tip1) != 0 {
-		for {
-			block := bci.Next1()
+	for {
+		block := bci.Next()
 
-			for _, tx := range block.Transactions {
-				txID := hex.EncodeToString(tx.ID)
+		for _, tx := range block.Transactions() {
+			txID := hex.EncodeToString(tx.ID)
 
-			Outputs:
-				for outIdx, out := range tx.Vout {
-					// Was the output spent?
-					if spentTXOs[txID] != nil {
+		Outputs:
+			for outIdx, out := range tx.Vout {
+				// Was the output spent?
+				if spentTXOs[txID] != nil {
 
-						for _, spentOutIdx := range spentTXOs[txID] {
-							if spentOutIdx == outIdx {
-								continue Outputs
-							}
+					for _, spentOutIdx := range spentTXOs[txID] {
+						if spentOutIdx == outIdx {
+							continue Outputs
 						}
-
 					}
 
-					outs := UTXO[txID]
-					outs.Outputs = append(outs.Outputs, out)
-					UTXO[txID] = outs
 				}
 
-				if tx.IsCoinbase() == false {
+				if !out.IsSpendable() {
+					continue
+				}
 
-					for _, in := range tx.Vin {
-						inTxID := hex.EncodeToString(in.Txid)
-						spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Vout)
-					}
+				outs := UTXO[txID]
+				outs.Outputs = append(outs.Outputs, out)
+				UTXO[txID] = outs
+			}
 
+			if tx.IsCoinbase() == false {
+
+				for _, in := range tx.Vin {
+					inTxID := hex.EncodeToString(in.Txid)
+					spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Vout)
 				}
-			}
 
-			if len(block.PrevBlockHash) == 0 {
-				break
 			}
 		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
 	}
 
 	return UTXO
@@ -299,24 +330,18 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 
 // Iterator returns a BlockchainIterat
 func (bc *Blockchain) Iterator() *BlockchainIterator {
-	bci := &BlockchainIterator{bc.tip1, bc.tip2, bc.tip3, bc.db}
+	bci := &BlockchainIterator{bc.tip, bc.db}
 
 	return bci
 }
 
 // GetBlockHashes returns a list of hashes of all the blocks in the chain
-func (bc *Blockchain) GetBlockHashes(typ string) [][]byte {
+func (bc *Blockchain) GetBlockHashes() [][]byte {
 	var blocks [][]byte
 	bci := bc.Iterator()
 
 	for {
-		if typ == "t" {
-			block := bci.Next1()
-		} else if typ == "p" {
-			block := bci.Next2()
-		} else {
-			block := bci.Next3()
-		}
+		block := bci.Next()
 
 		blocks = append(blocks, block.Hash)
 
@@ -328,141 +353,25 @@ func (bc *Blockchain) GetBlockHashes(typ string) [][]byte {
 	return blocks
 }
 
-// MineBlock mines a new block with the provided transactions or products
-func (bc *Blockchain) MineBlock(transactions []*Transaction, products []*Product, organisation *Organisation, address string) *Block {
-	var lastHash []byte
-	var lastHeight int
-
-	if transactions != nil {
-		for _, tx := range transactions {
-			if bc.VerifyTransaction(tx) != true {
-				log.Panic("ERROR: Invalid transaction")
-			}
-		}
-
-		err := bc.db.View(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(transactionsBucket))
-			lastHash = b.Get([]byte("l"))
-			blockData := b.Get(lastHash)
-			block := DeserializeBlock(blockData)
-
-			lastHeight = block.Height
-			return nil
-		})
-		if err != nil {
-			log.Panic(err)
-		}
-
-		newBlock := NewBlock(transactions, nil, nil, lastHash, lastHeight+1)
-
-		err = bc.db.Update(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(transactionsBucket))
-			err := b.Put(newBlock.Hash, newBlock.Serialize())
-			if err != nil {
-				log.Panic(err)
-			}
-
-			err = b.Put([]byte("l"), newBlock.Hash)
-			if err != nil {
-				log.Panic(err)
-			}
-
-			bc.tip1 = newBlock.Hash
-
-			return nil
-		})
-		if err != nil {
-			log.Panic(err)
-		}
-
-		return newBlock
-	} else if products != nil {
-		for _, p := range products {
-			if p.Verify(Base58Decode([]byte(address))) != true {
-				log.Panic("ERROR: Invalid product")
-			}
-		}
-
-		err := bc.db.View(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(productsBucket))
-			lastHash = b.Get([]byte("l"))
-			blockData := b.Get(lastHash)
-			block := DeserializeBlock(blockData)
-
-			lastHeight = block.Height
-			return nil
-		})
-		if err != nil {
-			log.Panic(err)
-		}
-
-		newBlock := NewBlock(nil, products, nil, lastHash, lastHeight+1)
-
-		err = bc.db.Update(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(productsBucket))
-			err := b.Put(newBlock.Hash, newBlock.Serialize())
-			if err != nil {
-				log.Panic(err)
-			}
-
-			err = b.Put([]byte("l"), newBlock.Hash)
-			if err != nil {
-				log.Panic(err)
-			}
-
-			bc.tip2 = newBlock.Hash
-
-			return nil
-		})
-		if err != nil {
-			log.Panic(err)
-		}
-
-		return newBlock
-	} else {
-		for _, p := range products {
-			if p.Verify(Base58Decode([]byte(address))) != true {
-				log.Panic("ERROR: Invalid organisation")
-			}
-		}
-
-		err := bc.db.View(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(organisationBucket))
-			lastHash = b.Get([]byte("l"))
-			blockData := b.Get(lastHash)
-			block := DeserializeBlock(blockData)
-
-			lastHeight = block.Height
-			return nil
-		})
-		if err != nil {
-			log.Panic(err)
-		}
-
-		newBlock := NewBlock(nil, nil, organisation, lastHash, lastHeight+1)
-
-		err = bc.db.Update(func(tx *bolt.Tx) error {
-			b := tx.Bucket([]byte(organisationBucket))
-			err := b.Put(newBlock.Hash, newBlock.Serialize())
-			if err != nil {
-				log.Panic(err)
-			}
-
-			err = b.Put([]byte("l"), newBlock.Hash)
-			if err != nil {
-				log.Panic(err)
-			}
-
-			bc.tip3 = newBlock.Hash
+// MineBlock seals a new block with the provided entries and connects it
+func (bc *Blockchain) MineBlock(entries []*Entry) *Block {
+	lastBlock, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		log.Panic(err)
+	}
 
-			return nil
-		})
-		if err != nil {
-			log.Panic(err)
-		}
+	newBlock := NewBlock(entries, lastBlock.Hash, lastBlock.Height+1)
+	err = bc.consensus.Seal(newBlock)
+	if err != nil {
+		log.Panic("ERROR: Cannot seal block: ", err)
+	}
 
-		return newBlock
+	err = bc.AddBlock(newBlock)
+	if err != nil {
+		log.Panic("ERROR: Cannot mine block: ", err)
 	}
+
+	return newBlock
 }
 
 // SignTransaction signs inputs of a Transaction
@@ -507,26 +416,24 @@ func dbExists(dbFile string) bool {
 	return true
 }
 
-// FindProductByCode finds a product by its Code
+// FindProductByCode finds a product by its Code in the catalog of the
+// organisation holding address, under any of its keys
 func (bc *Blockchain) FindProductByCode(address string, Code int) (Product, error) {
+	addresses := bc.GetAddresses(address)
 	bci := bc.Iterator()
 
-	if len(bc.tip2) != 0 {
-
-		for {
-			block := bci.Next2()
-
-			for _, p := range block.Products {
-				if Code == p.Code && p.Verify(Base58Decode([]byte(address))) {
-					return *p, nil
-				}
-			}
+	for {
+		block := bci.Next()
 
-			if len(block.PrevBlockHash) == 0 {
-				break
+		for _, p := range block.Products() {
+			if Code == p.Code && p.VerifyOwner(addresses) {
+				return *p, nil
 			}
 		}
 
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
 	}
 
 	return Product{}, errors.New("Product is not found")
@@ -534,82 +441,108 @@ func (bc *Blockchain) FindProductByCode(address string, Code int) (Product, erro
 
 // FindProductByCode finds a product by its Code
 func (bc *Blockchain) GetNextProductCode(address string) int {
+	addresses := bc.GetAddresses(address)
 	bci := bc.Iterator()
 
-	if len(bc.tip2) != 0 {
-
-		for {
-			block := bci.Next2()
-
-			var code int
-			found := false
+	for {
+		block := bci.Next()
 
-			for _, p := range block.Products {
-				if p.Verify(Base58Decode([]byte(address))) {
-					found = true
-					code = p.Code + 1
-				}
-			}
+		var code int
+		found := false
 
-			if found {
-				return code
-			}
-			if len(block.PrevBlockHash) == 0 {
-				break
+		for _, p := range block.Products() {
+			if p.VerifyOwner(addresses) {
+				found = true
+				code = p.Code + 1
 			}
 		}
 
+		if found {
+			return code
+		}
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
 	}
+
 	return 1
 }
 
-// FindOrganisationByPublicKey finds a organisation by its pubKey
+// FindOrganisationByPublicKey finds a organisation by its pubKey, or by any
+// key it rotated to
 func (bc *Blockchain) FindOrganisationByPublicKey(pubKey []byte) (Organisation, error) {
-	bci := bc.Iterator()
+	pubKey = registeredKey(bc.GetKeyRotations(), pubKey)
 
-	for {
-		block := bci.Next3()
-		org := block.Organisation
+	for _, org := range bc.GetOrganisations() {
 		if bytes.Compare(pubKey, org.PubKey) == 0 {
-			return *org, nil
-		}
-
-		if len(block.PrevBlockHash) == 0 {
-			break
+			return org, nil
 		}
 	}
 
 	return Organisation{}, errors.New("Organisation not found")
 }
 
-// GetRole gives role of organisation by public key
-func (bc *Blockchain) GetRole(pubKey []byte) []byte {
+// FindOrganisationByAddress finds a organisation by its address, or by the
+// address of any key it rotated to
+func (bc *Blockchain) FindOrganisationByAddress(address string) (Organisation, error) {
+	org, ok := findOrganisationByAddress(address, bc.GetOrganisations(), bc.GetKeyRotations())
+	if !ok {
+		return Organisation{}, errors.New("Organisation not found")
+	}
+
+	return org, nil
+}
+
+// GetOrganisations returns all organisations in registration order, with the
+// role the last role change gave them
+func (bc *Blockchain) GetOrganisations() []Organisation {
+	var orgs []Organisation
 	bci := bc.Iterator()
 
 	for {
-		block := bci.Next3()
-		org := block.Organisation
-		if bytes.Compare(pubKey, org.PubKey) == 0 {
-			return org.Role
+		block := bci.Next()
+		blockOrgs := block.Organisations()
+
+		for i := len(blockOrgs) - 1; i >= 0; i-- {
+			orgs = append([]Organisation{*blockOrgs[i]}, orgs...)
 		}
 
 		if len(block.PrevBlockHash) == 0 {
 			break
 		}
 	}
+	applyRoleChanges(orgs, bc.GetRoleChanges())
+
+	return orgs
+}
+
+// GetRole gives role of organisation by its current public key. Keys an
+// organisation rotated away from have no role.
+func (bc *Blockchain) GetRole(pubKey []byte) []byte {
+	org, err := bc.FindOrganisationByPublicKey(pubKey)
+	if err != nil || bytes.Compare(bc.GetCurrentKey(org.PubKey), pubKey) != 0 {
+		return nil
+	}
 
-	return nil
+	return org.Role
 }
 
-// isOrgDuplicate checks if credentials of organisation are duplicate
+// isOrgDuplicate checks if credentials of organisation are duplicate. A key
+// is a duplicate if any organisation ever held it.
 func (bc *Blockchain) isOrgDuplicate(gstin, prefix, pubKey []byte) bool {
+	if _, err := bc.FindOrganisationByPublicKey(pubKey); err == nil {
+		return true
+	}
+
 	bci := bc.Iterator()
 
 	for {
-		block := bci.Next3()
-		org := block.Organisation
-		if bytes.Compare(gstin, org.GSTIN) == 0 || bytes.Compare(pubKey, org.PubKey) == 0 || bytes.Compare(prefix, org.Prefix) == 0 {
-			return true
+		block := bci.Next()
+
+		for _, org := range block.Organisations() {
+			if bytes.Compare(gstin, org.GSTIN) == 0 || bytes.Compare(pubKey, org.PubKey) == 0 || bytes.Compare(prefix, org.Prefix) == 0 {
+				return true
+			}
 		}
 
 		if len(block.PrevBlockHash) == 0 {
@@ -620,15 +553,17 @@ func (bc *Blockchain) isOrgDuplicate(gstin, prefix, pubKey []byte) bool {
 	return false
 }
 
-// GenerateSGTIN gives SGTIN code by manufacturer pubKey, product code
+// GenerateSGTIN gives SGTIN code by manufacturer pubKey, product code. Items
+// of discontinued products cannot be generated.
 func (bc *Blockchain) GenerateSGTIN(address string, pubKey []byte, code int) (string, error) {
-	bci := bc.Iterator()
-
-	_, err := bc.FindProductByCode(address, code)
+	product, err := bc.FindCatalogProduct(address, code)
 
 	if err != nil {
 		return "", err
 	}
+	if product.Discontinued() {
+		return "", fmt.Errorf("Product code %d is discontinued", code)
+	}
 
 	org, err := bc.FindOrganisationByPublicKey(pubKey)
 
@@ -636,34 +571,51 @@ func (bc *Blockchain) GenerateSGTIN(address string, pubKey []byte, code int) (st
 		return "", err
 	}
 
-	if len(bc.tip1) != 0 {
-		found := false
-		var serial int
-		for {
-			block := bci.Next1()
-
-			for _, tx := range block.Transactions {
-				if tx.IsCoinbase() {
-					for _, out := range tx.Vout {
-						codeArr := strings.Split(out.Item, ".")
-						i, _ := strconv.Atoi(string(codeArr[1]))
-						if bytes.Compare([]byte(codeArr[0]), org.Prefix) == 0 && i == code {
-							found = true
-							serial, _ = strconv.Atoi(string(codeArr[2]))
-						}
-					}
-					if found {
-						return string(org.Prefix) + "." + strconv.Itoa(code) + "." + strconv.Itoa(serial+1), nil
+	serial := 0
+
+	bc.forEachMintedOutput(func(out TXOutput) bool {
+		prefix, c, s, err := ParseSGTIN(out.Item)
+		if err == nil && prefix == string(org.Prefix) && c == code && s > serial {
+			serial = s
+		}
+		return true
+	})
+
+	return FormatSGTIN(string(org.Prefix), code, serial+1), nil
+}
+
+// IsMinted checks whether an item has already been minted by a coinbase transaction
+func (bc *Blockchain) IsMinted(item string) bool {
+	found := false
+
+	bc.forEachMintedOutput(func(out TXOutput) bool {
+		found = out.Item == item
+		return !found
+	})
+
+	return found
+}
+
+// forEachMintedOutput calls fn with every output of a coinbase transaction on
+// the blockchain, newest first, until fn returns false
+func (bc *Blockchain) forEachMintedOutput(fn func(out TXOutput) bool) {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions() {
+			if tx.IsCoinbase() {
+				for _, out := range tx.Vout {
+					if !fn(out) {
+						return
 					}
 				}
 			}
-
-			if len(block.PrevBlockHash) == 0 {
-				break
-			}
 		}
 
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
 	}
-
-	return string(org.Prefix) + "." + strconv.Itoa(code) + "." + "1", nil
 }
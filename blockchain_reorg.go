@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/boltdb/bolt"
+)
+
+// getChainWork returns the cumulative work of the branch ending at hash
+func (bc *Blockchain) getChainWork(tx *bolt.Tx, hash []byte) *big.Int {
+	if len(hash) == 0 {
+		return big.NewInt(0)
+	}
+
+	work := tx.Bucket([]byte(chainworkBucket)).Get(hash)
+	if work != nil {
+		return new(big.Int).SetBytes(work)
+	}
+
+	// blocks stored before chain work was tracked
+	block := DeserializeBlock(tx.Bucket([]byte(blocksBucket)).Get(hash))
+
+	return new(big.Int).Add(bc.getChainWork(tx, block.PrevBlockHash), bc.consensus.Work(block))
+}
+
+// parentBlock returns the parent of a block, or nil for the first block
+func (bc *Blockchain) parentBlock(block *Block) *Block {
+	if len(block.PrevBlockHash) == 0 {
+		return nil
+	}
+
+	parent, err := bc.GetBlock(block.PrevBlockHash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return &parent
+}
+
+// setTip moves the tip of the blockchain
+func (bc *Blockchain) setTip(hash []byte) {
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(blocksBucket)).Put([]byte("l"), hash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc.tip = hash
+}
+
+// checkEntries validates the entries of a block extending the tip. The entries
+// of blocks converted from the three-chain format are trusted as the migration
+// recorded them.
+func (bc *Blockchain) checkEntries(block *Block) error {
+	if bc.IsMigratedBlock(block) {
+		return nil
+	}
+
+	err := UTXOSet{bc}.ValidateEntries(block.Entries)
+	if err != nil {
+		return rejectBlock(RejectInvalidEntry, "%s", err)
+	}
+
+	return nil
+}
+
+// applyBlock applies the transactions of a block extending the tip to the
+// UTXO set and makes it the tip
+func (bc *Blockchain) applyBlock(block *Block) {
+	UTXOSet{bc}.Update(block)
+	bc.setTip(block.Hash)
+}
+
+// connectBlock validates the entries of a block extending the tip and applies
+// it
+func (bc *Blockchain) connectBlock(block *Block) error {
+	err := bc.checkEntries(block)
+	if err != nil {
+		return err
+	}
+
+	bc.applyBlock(block)
+
+	return nil
+}
+
+// disconnectBlock removes the tip block from the UTXO set
+func (bc *Blockchain) disconnectBlock(block *Block) {
+	UTXOSet := UTXOSet{bc}
+	bc.setTip(block.PrevBlockHash)
+
+	if !UTXOSet.Rollback(block) {
+		fmt.Printf("No undo data for block %x, reindexing UTXO set\n", block.Hash)
+		UTXOSet.Reindex()
+	}
+}
+
+// removeBlocks deletes blocks that turned out to be invalid together with
+// their stored descendants
+func (bc *Blockchain) removeBlocks(blocks []*Block) {
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		removed := make(map[string]bool)
+
+		for _, block := range blocks {
+			removed[hex.EncodeToString(block.Hash)] = true
+		}
+
+		// a descendant can only be found once its parent is marked, so scan
+		// until a pass marks nothing new
+		for found := true; found; {
+			found = false
+
+			err := b.ForEach(func(k, v []byte) error {
+				if bytes.Equal(k, []byte("l")) || removed[hex.EncodeToString(k)] {
+					return nil
+				}
+
+				if removed[hex.EncodeToString(DeserializeBlock(v).PrevBlockHash)] {
+					removed[hex.EncodeToString(k)] = true
+					found = true
+				}
+
+				return nil
+			})
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		for hash := range removed {
+			key, _ := hex.DecodeString(hash)
+
+			err := b.Delete(key)
+			if err != nil {
+				log.Panic(err)
+			}
+
+			err = tx.Bucket([]byte(chainworkBucket)).Delete(key)
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// reorganize makes newTip the tip of the blockchain. Blocks of the current
+// branch back to the fork point are disconnected from the UTXO set, then the
+// blocks of the new branch are validated and connected. If one of them is
+// invalid it is removed together with its descendants and the previous branch
+// is restored.
+func (bc *Blockchain) reorganize(newTip *Block) error {
+	disconnected, connected, err := bc.switchBranch(newTip)
+	if err == nil && disconnected > 0 {
+		fmt.Printf("Reorganized: disconnected %d blocks, connected %d blocks\n", disconnected, connected)
+	}
+
+	return err
+}
+
+// switchBranch moves the tip to newTip and returns the number of blocks
+// disconnected and connected
+func (bc *Blockchain) switchBranch(newTip *Block) (int, int, error) {
+	var disconnect, connect []*Block
+	var oldBlock *Block
+
+	tip, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		log.Panic(err)
+	}
+	oldBlock = &tip
+	newBlock := newTip
+
+	for newBlock != nil && (oldBlock == nil || newBlock.Height > oldBlock.Height) {
+		connect = append(connect, newBlock)
+		newBlock = bc.parentBlock(newBlock)
+	}
+
+	for oldBlock != nil && (newBlock == nil || oldBlock.Height > newBlock.Height) {
+		disconnect = append(disconnect, oldBlock)
+		oldBlock = bc.parentBlock(oldBlock)
+	}
+
+	for oldBlock != nil && newBlock != nil && bytes.Compare(oldBlock.Hash, newBlock.Hash) != 0 {
+		connect = append(connect, newBlock)
+		newBlock = bc.parentBlock(newBlock)
+		disconnect = append(disconnect, oldBlock)
+		oldBlock = bc.parentBlock(oldBlock)
+	}
+
+	// connect the new branch starting from the fork point
+	for i, j := 0, len(connect)-1; i < j; i, j = i+1, j-1 {
+		connect[i], connect[j] = connect[j], connect[i]
+	}
+
+	for _, block := range disconnect {
+		bc.disconnectBlock(block)
+	}
+
+	for i, block := range connect {
+		err := bc.connectBlock(block)
+		if err == nil {
+			continue
+		}
+
+		bc.removeBlocks(connect[i:])
+
+		for j := i - 1; j >= 0; j-- {
+			bc.disconnectBlock(connect[j])
+		}
+		for j := len(disconnect) - 1; j >= 0; j-- {
+			if bc.connectBlock(disconnect[j]) != nil {
+				log.Panic("ERROR: Cannot restore the previous branch")
+			}
+		}
+
+		return 0, 0, err
+	}
+
+	return len(disconnect), len(connect), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAddBlockBranches(t *testing.T) {
+	tests := []struct {
+		name string
+		// blocks returns the blocks to add after the fork point, where the
+		// main branch minted 0123.1.1
+		blocks  func(c *testChain, fork *Block, maker *Wallet) []*Block
+		valid   bool
+		tip     int // index of the block expected as tip, -1 for the main branch
+		unspent []string
+		stored  int // number of the blocks expected to be stored
+	}{
+		{"block extending the tip", func(c *testChain, fork *Block, maker *Wallet) []*Block {
+			return []*Block{c.block(mintEntry(maker, "0123.1.2"))}
+		}, true, 0, []string{"0123.1.1", "0123.1.2"}, 1},
+		{"invalid block extending the tip", func(c *testChain, fork *Block, maker *Wallet) []*Block {
+			return []*Block{c.block(mintEntry(maker, "0123.1.1"))}
+		}, false, -1, []string{"0123.1.1"}, 0},
+		{"side block", func(c *testChain, fork *Block, maker *Wallet) []*Block {
+			return []*Block{c.blockAfter(fork, mintEntry(maker, "0123.1.2"))}
+		}, true, -1, []string{"0123.1.1"}, 1},
+		{"heavier side branch", func(c *testChain, fork *Block, maker *Wallet) []*Block {
+			side := c.blockAfter(fork, mintEntry(maker, "0123.1.2"))
+			return []*Block{side, c.blockAfter(side, mintEntry(maker, "0123.1.3"))}
+		}, true, 1, []string{"0123.1.2", "0123.1.3"}, 2},
+		{"heavier side branch with an invalid block", func(c *testChain, fork *Block, maker *Wallet) []*Block {
+			side := c.blockAfter(fork, mintEntry(maker, "0123.1.2"))
+			return []*Block{side, c.blockAfter(side, mintEntry(maker, "0123.1.2"))}
+		}, false, -1, []string{"0123.1.1"}, 1},
+		{"heavier side branch on an invalid block", func(c *testChain, fork *Block, maker *Wallet) []*Block {
+			side := c.blockAfter(fork, mintEntry(maker, "0124.1.1"))
+			return []*Block{side, c.blockAfter(side, mintEntry(maker, "0123.1.3"))}
+		}, false, -1, []string{"0123.1.1"}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestChain(t, powConsensus, nil)
+			maker := c.register("Maker", "0123", "Manufacturer")
+			c.addProducts(maker, "soap")
+
+			fork, err := c.bc.GetBlock(c.bc.tip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.mint(maker, "0123.1.1")
+			mainTip := c.bc.tip
+
+			blocks := test.blocks(c, &fork, maker)
+
+			for _, block := range blocks {
+				err = c.bc.AddBlock(block)
+				if err != nil {
+					break
+				}
+			}
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("AddBlock returned %v, expected valid %t", err, test.valid)
+			}
+
+			tip := mainTip
+			if test.tip >= 0 {
+				tip = blocks[test.tip].Hash
+			}
+			if !bytes.Equal(c.bc.tip, tip) {
+				t.Fatalf("tip %x, expected %x", c.bc.tip, tip)
+			}
+
+			stored := 0
+			for _, block := range blocks {
+				if _, err := c.bc.GetBlock(block.Hash); err == nil {
+					stored++
+				}
+			}
+			if stored != test.stored {
+				t.Errorf("%d blocks stored, expected %d", stored, test.stored)
+			}
+
+			UTXOSet := UTXOSet{c.bc}
+			for _, item := range []string{"0123.1.1", "0123.1.2", "0123.1.3", "0124.1.1"} {
+				unspent := len(UTXOSet.FindItem(item)) > 0
+				if expected := containsAddress(test.unspent, item); unspent != expected {
+					t.Errorf("item %s unspent %t, expected %t", item, unspent, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestMineBlockConnects(t *testing.T) {
+	c := newTestChain(t, powConsensus, nil)
+	maker := c.register("Maker", "0123", "Manufacturer")
+	c.addProducts(maker, "soap")
+
+	block := c.bc.MineBlock([]*Entry{mintEntry(maker, "0123.1.1")})
+
+	UTXOSet := UTXOSet{c.bc}
+	if len(UTXOSet.FindItem("0123.1.1")) == 0 {
+		t.Fatal("item minted by a mined block is not unspent")
+	}
+	if !bytes.Equal(c.bc.tip, block.Hash) {
+		t.Fatalf("tip %x, expected the mined block %x", c.bc.tip, block.Hash)
+	}
+
+	c.bc.setTip(block.PrevBlockHash)
+	if !UTXOSet.Rollback(block) {
+		t.Fatal("no undo data recorded for a mined block")
+	}
+	if len(UTXOSet.FindItem("0123.1.1")) > 0 {
+		t.Fatal("item of a rolled back block is still unspent")
+	}
+}
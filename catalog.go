@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actions of the versions of a catalog entry
+const registerAction = "register"
+const updateAction = "update"
+const discontinueAction = "discontinue"
+
+// Statuses of a catalog entry
+const discontinuedStatus = "discontinued"
+
+// quantity matches a positive decimal net quantity such as 500 or 0.75
+var quantity = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// ProductAttributes are the structured attributes of a catalog entry: its
+// GTIN-14, description, brand, category, HS code, net quantity and unit, and
+// the SHA-256 of an image or spec sheet. Any field may be empty.
+type ProductAttributes struct {
+	GTIN        string
+	Description string
+	Brand       string
+	Category    string
+	HSCode      string
+	NetQuantity string
+	Unit        string
+	SpecHash    []byte
+}
+
+// NewProductAttributes builds the attributes of a catalog entry. GTIN-8,
+// GTIN-12 and GTIN-13 are padded to 14 digits and specHash is hex. It returns
+// nil if no field is set.
+func NewProductAttributes(gtin, description, brand, category, hsCode, netQuantity, unit, specHash string) (*ProductAttributes, error) {
+	if gtin == "" && description == "" && brand == "" && category == "" && hsCode == "" && netQuantity == "" && unit == "" && specHash == "" {
+		return nil, nil
+	}
+
+	if digits.MatchString(gtin) && len(gtin) < gtinLength {
+		gtin = strings.Repeat("0", gtinLength-len(gtin)) + gtin
+	}
+
+	hash, err := hex.DecodeString(specHash)
+	if err != nil {
+		return nil, errors.New("Spec hash is not hex")
+	}
+
+	attributes := &ProductAttributes{gtin, description, brand, category, hsCode, netQuantity, unit, hash}
+
+	return attributes, attributes.Validate()
+}
+
+// Validate checks that the GTIN has a valid check digit, the HS code has 6 to
+// 10 digits, the net quantity is positive and comes with a unit, and the spec
+// hash is a SHA-256
+func (a *ProductAttributes) Validate() error {
+	if a.GTIN != "" {
+		if !digits.MatchString(a.GTIN) || len(a.GTIN) != gtinLength {
+			return errors.New("GTIN must have 8, 12, 13 or 14 digits")
+		}
+		if strconv.Itoa(gtinCheckDigit(a.GTIN[:gtinLength-1])) != a.GTIN[gtinLength-1:] {
+			return fmt.Errorf("GTIN %s has a wrong check digit", a.GTIN)
+		}
+	}
+	if a.HSCode != "" && (!digits.MatchString(a.HSCode) || len(a.HSCode) < 6 || len(a.HSCode) > 10) {
+		return fmt.Errorf("HS code %s must have 6 to 10 digits", a.HSCode)
+	}
+	if a.NetQuantity != "" {
+		if !quantity.MatchString(a.NetQuantity) || strings.Trim(a.NetQuantity, "0.") == "" {
+			return fmt.Errorf("Net quantity %s is not a positive number", a.NetQuantity)
+		}
+	}
+	if (a.NetQuantity == "") != (a.Unit == "") {
+		return errors.New("Net quantity and unit must be given together")
+	}
+	if len(a.SpecHash) != 0 && len(a.SpecHash) != 32 {
+		return errors.New("Spec hash must be a SHA-256 of 32 bytes")
+	}
+
+	return nil
+}
+
+// Hash returns the hash of the attributes
+func (a *ProductAttributes) Hash() []byte {
+	return HashFields(
+		[]byte(a.GTIN),
+		[]byte(a.Description),
+		[]byte(a.Brand),
+		[]byte(a.Category),
+		[]byte(a.HSCode),
+		[]byte(a.NetQuantity),
+		[]byte(a.Unit),
+		a.SpecHash,
+	)
+}
+
+// mergeAttributes returns the attributes of current with the fields set in
+// changes replaced
+func mergeAttributes(current, changes *ProductAttributes) *ProductAttributes {
+	if changes == nil {
+		return current
+	}
+	if current == nil {
+		return changes
+	}
+
+	merged := *current
+	for _, field := range []struct{ to, from *string }{
+		{&merged.GTIN, &changes.GTIN},
+		{&merged.Description, &changes.Description},
+		{&merged.Brand, &changes.Brand},
+		{&merged.Category, &changes.Category},
+		{&merged.HSCode, &changes.HSCode},
+		{&merged.NetQuantity, &changes.NetQuantity},
+		{&merged.Unit, &changes.Unit},
+	} {
+		if *field.from != "" {
+			*field.to = *field.from
+		}
+	}
+	if len(changes.SpecHash) > 0 {
+		merged.SpecHash = changes.SpecHash
+	}
+
+	return &merged
+}
+
+// checkGTIN checks that the GTIN of attributes, if any, is the GTIN of product
+// code under prefix
+func checkGTIN(attributes *ProductAttributes, prefix []byte, code int) error {
+	if attributes == nil || attributes.GTIN == "" {
+		return nil
+	}
+
+	gtin, err := FormatGTIN(string(prefix), code)
+	if err != nil {
+		return err
+	}
+	if attributes.GTIN != gtin {
+		return fmt.Errorf("GTIN %s is not the GTIN %s of product code %d", attributes.GTIN, gtin, code)
+	}
+
+	return nil
+}
+
+// CatalogUpdate is a new version of the product Code of the Manufacturer with
+// prefix Prefix, signed by that manufacturer. An update replaces the name and
+// attributes of the product, and a discontinuation stops the minting of new
+// items for good. Version follows the current version of the product, which
+// is 1 when it is registered.
+type CatalogUpdate struct {
+	ID         []byte
+	Prefix     []byte
+	Code       int
+	Version    int
+	Action     []byte
+	Name       []byte
+	Attributes *ProductAttributes
+	Reason     []byte
+	Timestamp  int64
+	Signature  []byte
+	PubKey     []byte
+}
+
+// NewCatalogUpdate creates the next version of product code of the
+// manufacturer holding address. An update keeps the current name and the
+// current value of every attribute that name and attributes leave empty. A
+// discontinuation only records reason.
+func NewCatalogUpdate(address string, code int, action, name string, attributes *ProductAttributes, reason string, bc *Blockchain, nodeID string) (*CatalogUpdate, error) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("Address %s is not in the wallet of this node", address)
+	}
+
+	org, err := bc.FindOrganisationByPublicKey(wallet.PublicKey)
+	if err != nil {
+		return nil, errors.New("Organisation not found")
+	}
+
+	product, err := bc.FindCatalogProduct(address, code)
+	if err != nil {
+		return nil, fmt.Errorf("Product code %d is not in the catalog of %s", code, org.Name)
+	}
+	current := product.Current()
+
+	update := &CatalogUpdate{nil, org.Prefix, code, current.Version + 1, []byte(action), nil, nil, []byte(reason), time.Now().Unix(), nil, wallet.PublicKey}
+	if action == updateAction {
+		update.Name = current.Name
+		if name != "" {
+			update.Name = []byte(name)
+		}
+		update.Attributes = mergeAttributes(current.Attributes, attributes)
+	}
+	update.Sign(wallet.PrivateKey)
+
+	err = bc.ValidateCatalogUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+
+	return update, nil
+}
+
+// Hash returns the hash of the catalog update, excluding its ID
+func (u *CatalogUpdate) Hash() []byte {
+	var attributes []byte
+	if u.Attributes != nil {
+		attributes = u.Attributes.Hash()
+	}
+
+	return HashFields(
+		u.Prefix,
+		IntToHex(int64(u.Code)),
+		IntToHex(int64(u.Version)),
+		u.Action,
+		u.Name,
+		attributes,
+		u.Reason,
+		IntToHex(u.Timestamp),
+		u.Signature,
+		u.PubKey,
+	)
+}
+
+// Sign sets the ID of the catalog update and signs it
+func (u *CatalogUpdate) Sign(privKey ecdsa.PrivateKey) {
+	u.Signature = nil
+	u.ID = u.Hash()
+
+	u.Signature = signDigest(privKey, u.ID)
+}
+
+// Verify checks that the ID matches the catalog update and is signed by its PubKey
+func (u *CatalogUpdate) Verify() bool {
+	uCopy := *u
+	uCopy.Signature = nil
+	if bytes.Compare(uCopy.Hash(), u.ID) != 0 {
+		return false
+	}
+
+	return verifyDigest(u.PubKey, u.ID, u.Signature)
+}
+
+// String returns a human-readable representation of a catalog update
+func (u CatalogUpdate) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Catalog update %x:", u.ID))
+
+	lines = append(lines, fmt.Sprintf("       Prefix:       %s", u.Prefix))
+	lines = append(lines, fmt.Sprintf("       Code:       %d", u.Code))
+	lines = append(lines, fmt.Sprintf("       Version:       %d", u.Version))
+	lines = append(lines, fmt.Sprintf("       Action:       %s", u.Action))
+	if len(u.Name) > 0 {
+		lines = append(lines, fmt.Sprintf("       Name:       %s", u.Name))
+	}
+	if len(u.Reason) > 0 {
+		lines = append(lines, fmt.Sprintf("       Reason:       %s", u.Reason))
+	}
+	lines = append(lines, fmt.Sprintf("       Signature: %x", u.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %x", u.PubKey))
+	return strings.Join(lines, "\n")
+}
+
+// ProductVersion is a version of a catalog entry, recorded by its
+// registration or by a catalog update with ID ID at block height Height
+type ProductVersion struct {
+	ID         []byte
+	Version    int
+	Action     string
+	Name       []byte
+	Attributes *ProductAttributes
+	Reason     []byte
+	Height     int
+	Timestamp  int64
+}
+
+// CatalogProduct is a registered product with its versions, oldest first
+type CatalogProduct struct {
+	Product  Product
+	Versions []ProductVersion
+}
+
+// Current returns the latest version of the product
+func (p *CatalogProduct) Current() ProductVersion {
+	return p.Versions[len(p.Versions)-1]
+}
+
+// Discontinued reports whether the product was discontinued
+func (p *CatalogProduct) Discontinued() bool {
+	return p.Current().Action == discontinueAction
+}
+
+// apply adds the version recorded by a catalog update
+func (p *CatalogProduct) apply(update *CatalogUpdate, height int, timestamp int64) {
+	version := ProductVersion{update.ID, update.Version, string(update.Action), update.Name, update.Attributes, update.Reason, height, timestamp}
+
+	// a discontinuation keeps the last name and attributes
+	if version.Action == discontinueAction {
+		current := p.Current()
+		version.Name = current.Name
+		version.Attributes = current.Attributes
+	}
+
+	p.Versions = append(p.Versions, version)
+}
+
+// GetCatalog returns the catalog of a manufacturer as of block height height
+// in registration order, including products registered under its former keys,
+// each with the versions recorded up to that height
+func (bc *Blockchain) GetCatalog(address string, height int) []CatalogProduct {
+	var catalog []CatalogProduct
+	var prefix []byte
+	addresses := bc.GetAddresses(address)
+	if org, err := bc.FindOrganisationByAddress(address); err == nil {
+		prefix = org.Prefix
+	}
+
+	var blocks []*Block
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		if block.Height <= height {
+			blocks = append([]*Block{block}, blocks...)
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	for _, block := range blocks {
+		for _, p := range block.Products() {
+			if p.VerifyOwner(addresses) {
+				version := ProductVersion{p.ID, 1, registerAction, p.Name, p.Attributes, nil, block.Height, block.Timestamp}
+				catalog = append(catalog, CatalogProduct{*p, []ProductVersion{version}})
+			}
+		}
+
+		for _, update := range block.CatalogUpdates() {
+			if bytes.Compare(update.Prefix, prefix) != 0 {
+				continue
+			}
+			for i := range catalog {
+				if catalog[i].Product.Code == update.Code {
+					catalog[i].apply(update, block.Height, block.Timestamp)
+				}
+			}
+		}
+	}
+
+	return catalog
+}
+
+// FindCatalogProduct finds product code in the current catalog of the
+// organisation holding address, under any of its keys
+func (bc *Blockchain) FindCatalogProduct(address string, code int) (CatalogProduct, error) {
+	for _, p := range bc.GetCatalog(address, bc.GetBestHeight()) {
+		if p.Product.Code == code {
+			return p, nil
+		}
+	}
+
+	return CatalogProduct{}, errors.New("Product is not found")
+}
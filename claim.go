@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// claimCodeLength is the number of random bytes of a claim code
+const claimCodeLength = 10
+
+// NewClaimCode returns a random one-time claim code, Base58 encoded
+func NewClaimCode() string {
+	code := make([]byte, claimCodeLength)
+
+	_, err := rand.Read(code)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return string(Base58Encode(code))
+}
+
+// HashClaimCode returns the hash recorded on the ledger for the claim code of
+// an item
+func HashClaimCode(item, code string) []byte {
+	return HashFields([]byte(item), []byte(code))
+}
+
+// NewSaleTransaction creates the final transfer of items held by address to
+// consumers. The items stay locked to address until claimed, each with the
+// one-time claim code returned for it in the order of items. Only the hashes
+// of the codes are recorded.
+func NewSaleTransaction(wallet Wallet, address string, items []string, UTXOSet *UTXOSet) (*Transaction, []string, error) {
+	var codes []string
+
+	inputs, spent, err := UTXOSet.findOutputsOf(wallet, items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx := Transaction{nil, inputs, nil}
+	for i, out := range spent {
+		code := NewClaimCode()
+		codes = append(codes, code)
+
+		output := NewTXOutput(i, out.Item, address)
+		output.Contents = out.Contents
+		output.ClaimHash = HashClaimCode(out.Item, code)
+		tx.Vout = append(tx.Vout, *output)
+	}
+
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+
+	return &tx, codes, nil
+}
+
+// NewClaimTransaction creates the transaction by which the consumer holding
+// address takes ownership of an item sold with claim code code
+func NewClaimTransaction(wallet Wallet, address, item, code string, UTXOSet *UTXOSet) (*Transaction, error) {
+	var inputs []TXInput
+
+	for txid, out := range UTXOSet.FindItem(item) {
+		if out.Item != item || len(out.ClaimHash) == 0 {
+			continue
+		}
+
+		txID, err := hex.DecodeString(txid)
+		if err != nil {
+			log.Panic(err)
+		}
+		inputs = append(inputs, TXInput{txID, out.Index, nil, wallet.PublicKey, []byte(code)})
+	}
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("Item %s is not waiting to be claimed", item)
+	}
+
+	tx := Transaction{nil, inputs, []TXOutput{*NewTXOutput(0, item, address)}}
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+
+	return &tx, nil
+}
+
+// IsClaim checks whether the transaction claims an item sold to consumers
+func (tx *Transaction) IsClaim() bool {
+	for _, vin := range tx.Vin {
+		if len(vin.Code) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClaimAttempt records a consumer trying to claim an item that was already
+// claimed. The attempt is rejected, but it is signed by the claimant and kept
+// on the ledger as a sign that the item or its claim code was copied. Code is
+// the claim code given, revealed so that whether it is the code the item was
+// sold with can be checked against the claim hash of the sale.
+type ClaimAttempt struct {
+	ID        []byte
+	Item      []byte
+	Code      []byte
+	Timestamp int64
+	Signature []byte
+	PubKey    []byte
+}
+
+// NewClaimAttempt creates the record of a claim of item with code by the
+// consumer holding address, once the item has been claimed
+func NewClaimAttempt(address, item, code string, bc *Blockchain, nodeID string) (*ClaimAttempt, error) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("Address %s is not in the wallet of this node", address)
+	}
+
+	attempt := &ClaimAttempt{nil, []byte(item), []byte(code), time.Now().Unix(), nil, wallet.PublicKey}
+	attempt.Sign(wallet.PrivateKey)
+
+	err = bc.ValidateClaimAttempt(attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+// Hash returns the hash of the claim attempt, excluding its ID
+func (a *ClaimAttempt) Hash() []byte {
+	return HashFields(
+		a.Item,
+		a.Code,
+		IntToHex(a.Timestamp),
+		a.Signature,
+		a.PubKey,
+	)
+}
+
+// Sign sets the ID of the claim attempt and signs it
+func (a *ClaimAttempt) Sign(privKey ecdsa.PrivateKey) {
+	a.Signature = nil
+	a.ID = a.Hash()
+
+	a.Signature = signDigest(privKey, a.ID)
+}
+
+// IsCopy checks whether the attempt gives the claim code of sale, the output
+// the item was claimed from
+func (a *ClaimAttempt) IsCopy(sale TXOutput) bool {
+	return bytes.Compare(HashClaimCode(string(a.Item), string(a.Code)), sale.ClaimHash) == 0
+}
+
+// Verify checks that the ID matches the claim attempt and is signed by its PubKey
+func (a *ClaimAttempt) Verify() bool {
+	aCopy := *a
+	aCopy.Signature = nil
+	if bytes.Compare(aCopy.Hash(), a.ID) != 0 {
+		return false
+	}
+
+	return verifyDigest(a.PubKey, a.ID, a.Signature)
+}
+
+// String returns a human-readable representation of a claim attempt
+func (a ClaimAttempt) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Claim attempt %x:", a.ID))
+
+	lines = append(lines, fmt.Sprintf("       Item:       %s", a.Item))
+	lines = append(lines, fmt.Sprintf("       Claimant:       %s", GetAddressFromPubKey(a.PubKey)))
+	lines = append(lines, fmt.Sprintf("       Code:       %s", a.Code))
+	lines = append(lines, fmt.Sprintf("       Signature: %x", a.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %x", a.PubKey))
+	return strings.Join(lines, "\n")
+}
+
+// FindClaim returns the last claim of item and the sale output it spent
+func (bc *Blockchain) FindClaim(item string) (*Transaction, TXOutput, error) {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		txs := block.Transactions()
+
+		for i := len(txs) - 1; i >= 0; i-- {
+			if !txs[i].IsClaim() {
+				continue
+			}
+
+			for _, vin := range txs[i].Vin {
+				sale, err := bc.FindTransaction(vin.Txid)
+				if err != nil {
+					continue
+				}
+				if out := sale.Vout[vin.Vout]; out.Item == item {
+					return txs[i], out, nil
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			return nil, TXOutput{}, errors.New("Item was never claimed")
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSaleChain returns a ledger on which Maker sold 0123.1.1 to consumers with
+// the claim code returned, and holds 0123.1.2
+func newSaleChain(t *testing.T) (*testChain, *Wallet, string) {
+	c := newTestChain(t, powConsensus, nil)
+	maker := c.register("Maker", "0123", "Manufacturer")
+	c.addProducts(maker, "soap")
+	c.mint(maker, items("0123", 1, 2)...)
+
+	UTXOSet := UTXOSet{c.bc}
+	tx, codes, err := NewSaleTransaction(*maker, addressOf(maker), []string{"0123.1.1"}, &UTXOSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mustMine(NewTransactionEntries([]*Transaction{tx})...)
+
+	return c, maker, codes[0]
+}
+
+func TestClaim(t *testing.T) {
+	tests := []struct {
+		name string
+		item string
+		// code returns the code to claim with given the code of the sale
+		code     func(code string) string
+		claimant func(maker *Wallet) *Wallet
+		valid    bool
+	}{
+		{"consumer with the code", "0123.1.1", func(code string) string { return code }, func(*Wallet) *Wallet { return NewWallet() }, true},
+		{"consumer with a wrong code", "0123.1.1", func(string) string { return NewClaimCode() }, func(*Wallet) *Wallet { return NewWallet() }, false},
+		{"organisation with the code", "0123.1.1", func(code string) string { return code }, func(maker *Wallet) *Wallet { return maker }, false},
+		{"item not sold", "0123.1.2", func(code string) string { return code }, func(*Wallet) *Wallet { return NewWallet() }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, maker, code := newSaleChain(t)
+			claimant := test.claimant(maker)
+
+			UTXOSet := UTXOSet{c.bc}
+			tx, err := NewClaimTransaction(*claimant, addressOf(claimant), test.item, test.code(code), &UTXOSet)
+			if err == nil {
+				err = c.mine(NewTransactionEntries([]*Transaction{tx})...)
+			}
+
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("claim returned %v, expected valid %t", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestClaimAttempt(t *testing.T) {
+	tests := []struct {
+		name string
+		item string
+		// attempt returns the attempt to record given the code of the sale,
+		// the consumer who claimed the item and the manufacturer
+		attempt func(code string, consumer, maker *Wallet) *ClaimAttempt
+		valid   bool
+		issue   string
+	}{
+		{"copy of the code", "0123.1.1", func(code string, consumer, maker *Wallet) *ClaimAttempt {
+			return signedAttempt(NewWallet(), "0123.1.1", code)
+		}, true, "with a copy of its claim code"},
+		{"wrong code", "0123.1.1", func(code string, consumer, maker *Wallet) *ClaimAttempt {
+			return signedAttempt(NewWallet(), "0123.1.1", NewClaimCode())
+		}, true, "with a wrong claim code"},
+		{"no code", "0123.1.1", func(code string, consumer, maker *Wallet) *ClaimAttempt {
+			return signedAttempt(NewWallet(), "0123.1.1", "")
+		}, false, ""},
+		{"code changed after signing", "0123.1.1", func(code string, consumer, maker *Wallet) *ClaimAttempt {
+			attempt := signedAttempt(NewWallet(), "0123.1.1", NewClaimCode())
+			attempt.Code = []byte(code)
+			return attempt
+		}, false, ""},
+		{"by the consumer who claimed it", "0123.1.1", func(code string, consumer, maker *Wallet) *ClaimAttempt {
+			return signedAttempt(consumer, "0123.1.1", code)
+		}, false, ""},
+		{"by an organisation", "0123.1.1", func(code string, consumer, maker *Wallet) *ClaimAttempt {
+			return signedAttempt(maker, "0123.1.1", code)
+		}, false, ""},
+		{"item never claimed", "0123.1.2", func(code string, consumer, maker *Wallet) *ClaimAttempt {
+			return signedAttempt(NewWallet(), "0123.1.2", code)
+		}, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, maker, code := newSaleChain(t)
+
+			consumer := NewWallet()
+			UTXOSet := UTXOSet{c.bc}
+			tx, err := NewClaimTransaction(*consumer, addressOf(consumer), "0123.1.1", code, &UTXOSet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.mustMine(NewTransactionEntries([]*Transaction{tx})...)
+
+			err = c.mine(NewClaimAttemptEntry(test.attempt(code, consumer, maker)))
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("attempt returned %v, expected valid %t", err, test.valid)
+			}
+			if !test.valid {
+				return
+			}
+
+			history := c.bc.GetItemHistory(test.item)
+			last := history[len(history)-1]
+			if last.Action != rejectedClaimAction || len(last.Issues) != 1 || !strings.HasSuffix(last.Issues[0], test.issue) {
+				t.Fatalf("history ends with %s %v, expected a rejected claim %s", last.Action, last.Issues, test.issue)
+			}
+			if verification := c.bc.VerifyItem(test.item); verification.Conflicts == 0 {
+				t.Error("rejected claim is not counted as a conflict")
+			}
+		})
+	}
+}
+
+// signedAttempt returns an attempt to claim item with code signed by wallet
+func signedAttempt(wallet *Wallet, item, code string) *ClaimAttempt {
+	attempt := &ClaimAttempt{nil, []byte(item), []byte(code), time.Now().Unix(), nil, wallet.PublicKey}
+	attempt.Sign(wallet.PrivateKey)
+
+	return attempt
+}
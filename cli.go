@@ -3,35 +3,67 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 )
 
 // CLI responsible for processing command line arguments
-type CLI struct{}
+type CLI struct {
+	output string
+}
 
 func (cli *CLI) printUsage() {
-	fmt.Println("Usage:")
-	fmt.Println("  createblockchain -name NAME -publickey KEY -gstin GSTIN -prefix PREFIX - Create blockchains")
+	fmt.Println("Usage: [-output json|text|csv] COMMAND")
+	fmt.Println("  -output json|text|csv - Format of the printed results, text by default")
+	fmt.Println("Items are given as SGTIN (prefix.code.serial), EPC URI, GS1 element string or GS1 Digital Link, logistic units as SSCC (prefix.serial)")
+	fmt.Println("Commands:")
+	fmt.Println("  createblockchain -name NAME -publickey KEY -gstin GSTIN -prefix PREFIX -consensus pow|poa -policy FROM>TO,... -threshold M - Create blockchains sealed by proof of work or by validator organisations, with the role-to-role transfers allowed and the number of admins that must approve registry changes")
 	fmt.Println("  createwallet - Generates a new key-pair and saves it into the wallet file")
 	fmt.Println("  createorg -address ADDRESS -name NAME -publickey KEY -gstin GSTIN -prefix PREFIX -role ROLE - Add a organisation")
-	fmt.Println("  listorg - Print all organisations")
-	fmt.Println("  inventory -address ADDRESS - Inventory of ADDRESS")
+	fmt.Println("  listorg - Print all organisations and their status")
+	fmt.Println("  suspendorg -address ADMIN -org ADDRESS -reason REASON -height HEIGHT - Suspend the organisation of ADDRESS from HEIGHT, the next block by default")
+	fmt.Println("  reinstateorg -address ADMIN -org ADDRESS -reason REASON -height HEIGHT - Reinstate a suspended organisation from HEIGHT, the next block by default")
+	fmt.Println("  revokeorg -address ADMIN -org ADDRESS -reason REASON -height HEIGHT - Revoke an organisation for good from HEIGHT, the next block by default")
+	fmt.Println("  rotatekey -address SIGNER -org ADDRESS -newaddress ADDRESS - Replace the key of the organisation of ADDRESS, signed by its key or an admin and by the new key")
+	fmt.Println("  changerole -address ADMIN -org ADDRESS -role ROLE - Give the organisation of ADDRESS a new role")
+	fmt.Println("  listproposals - Print the registry changes waiting for the approval of admins")
+	fmt.Println("  approve -address ADMIN -proposal ID - Approve a registry change, recording it once enough admins approved it")
+	fmt.Println("  policy - Print the role-to-role transfers allowed by the genesis block")
+	fmt.Println("  inventory -address ADDRESS - Inventory of ADDRESS, marking expired items and items expiring within 30 days")
 	fmt.Println("  listaddresses - Lists all addresses from the wallet")
-	fmt.Println("  printchain - Print all the blocks of the transaction chain")
+	fmt.Println("  printchain - Print all the blocks of the blockchain")
 	fmt.Println("  reindexutxo - Rebuilds the UTXO set")
+	fmt.Println("  migrate - Converts a blockchain of the three-chain format into a single ledger, keeping the original file as a backup")
 	fmt.Println("  send -from FROM -to TO -products PRODUCT -mine - Send PRODUCT from FROM address to TO. Mine on the same node, when -mine is set.")
-	fmt.Println("  addproducts -address ADDRESS -names NAMES -Add products")
-	fmt.Println("  listproducts -address ADDRESS -Get products of address")
-	fmt.Println("  produceproducts -address ADDRESS -codes CODES -Produce product")
-	fmt.Println("  getitemdetails -item ITEM -Get item history")
-	fmt.Println("  startnode - Start a node with ID specified in NODE_ID env. var")
+	fmt.Println("  sell -from FROM -products PRODUCT -mine - Sell PRODUCT from FROM address to consumers and print the one-time claim code of each item. Mine on the same node, when -mine is set.")
+	fmt.Println("  claim -address ADDRESS -item ITEM -code CODE - Take ownership of ITEM, sold to consumers, for the consumer address ADDRESS with its claim code")
+	fmt.Println("  decommission -from FROM -products PRODUCT -reason REASON -mine - Take PRODUCT held by FROM out of circulation for REASON: sold-to-consumer, destroyed, expired, stolen, lost or sample. Mine on the same node, when -mine is set.")
+	fmt.Println("  offer -from FROM -to TO -products PRODUCT -expiry HEIGHT -mine - Offer PRODUCT from FROM address to TO, which keeps FROM the owner until TO accepts it. FROM may reclaim it after block HEIGHT, when -expiry is set. Mine on the same node, when -mine is set.")
+	fmt.Println("  accept -address ADDRESS -products PRODUCT -mine - Accept PRODUCT offered to ADDRESS, taking ownership of it. Mine on the same node, when -mine is set.")
+	fmt.Println("  reject -address ADDRESS -products PRODUCT -mine - Reject PRODUCT offered to ADDRESS, returning it to its sender. Mine on the same node, when -mine is set.")
+	fmt.Println("  reclaim -address ADDRESS -products PRODUCT -mine - Take back PRODUCT offered by ADDRESS once the offer expired. Mine on the same node, when -mine is set.")
+	fmt.Println("  addproducts -address ADDRESS -names NAMES -gtin GTIN -description TEXT -brand BRAND -category CATEGORY -hscode CODE -quantity QUANTITY -unit UNIT -spec FILE -Add products, with optional attributes shared by all of them")
+	fmt.Println("  updateproduct -address ADDRESS -code CODE -name NAME -gtin GTIN -description TEXT -brand BRAND -category CATEGORY -hscode CODE -quantity QUANTITY -unit UNIT -spec FILE - Record a new version of a product, keeping the name and attributes not given")
+	fmt.Println("  discontinueproduct -address ADDRESS -code CODE -reason REASON - Discontinue a product for good, so that no more items of it can be produced")
+	fmt.Println("  listproducts -address ADDRESS -height HEIGHT -Get products of address at their current version, or as of block HEIGHT")
+	fmt.Println("  produceproducts -address ADDRESS -codes CODES -lot LOT -mfg DATE -expiry DATE -Produce product, with an optional lot number and manufacturing and expiry dates as YYMMDD or YYYY-MM-DD")
+	fmt.Println("  pack -address ADDRESS -items ITEMS - Pack items or logistic units of ADDRESS into a new logistic unit (SSCC)")
+	fmt.Println("  unpack -address ADDRESS -unit SSCC - Unpack a logistic unit of ADDRESS into the items packed in it")
+	fmt.Println("  getlot -lot LOT - Print the items minted with lot number LOT and their owners")
+	fmt.Println("  getitemdetails -item ITEM - Print the chain of custody and the GS1 identifiers of ITEM, an SGTIN or the SSCC of a logistic unit")
+	fmt.Println("  verifyitem -item ITEM - Check that ITEM, an SGTIN, is genuine: minted by the manufacturer of its prefix, in its catalog and with an unbroken chain of custody")
+	fmt.Println("  exportepcis -item ITEM - Print the events of the ledger as an EPCIS 2.0 document. Print only the events of ITEM, when -item is set.")
+	fmt.Println("  importepcis -address ADDRESS -file FILE - Record the events of the EPCIS 2.0 document FILE as transactions signed by ADDRESS")
+	fmt.Println("  recall -address ADDRESS -code CODE -from SERIAL -to SERIAL -items ITEMS -reason REASON - Recall a product code, its serials FROM to TO, or the listed ITEMS")
+	fmt.Println("  recalls -holders - Print all recalls. Print the addresses holding recalled items, when -holders is set.")
+	fmt.Println("  startnode -api ADDRESS - Start a node with ID specified in NODE_ID env. var. Serve the HTTP API on ADDRESS, when -api is set.")
 }
 
 func (cli *CLI) validateArgs() {
 	if len(os.Args) < 2 {
 		cli.printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -39,10 +71,34 @@ func (cli *CLI) validateArgs() {
 func (cli *CLI) Run() {
 	cli.validateArgs()
 
+	globalCmd := flag.NewFlagSet("blockchain", flag.ExitOnError)
+	globalCmd.Usage = cli.printUsage
+	globalCmd.StringVar(&cli.output, "output", textOutput, "Format of the printed results, json, text or csv")
+	globalCmd.Parse(os.Args[1:])
+
+	args := globalCmd.Args()
+	if len(args) == 0 {
+		cli.printUsage()
+		os.Exit(exitUsage)
+	}
+	if cli.output != textOutput && cli.output != jsonOutput && cli.output != csvOutput {
+		cli.exit(exitUsage, "Output must be json, text or csv")
+	}
+
 	nodeID := os.Getenv("NODE_ID")
 	if nodeID == "" {
-		fmt.Printf("NODE_ID env. var is not set!")
-		os.Exit(1)
+		cli.exit(exitUsage, "NODE_ID env. var is not set!")
+	}
+
+	// Commands report failures through exit; the log of a panic is replaced
+	// by its message and the node keeps its log
+	if args[0] != "startnode" {
+		log.SetOutput(ioutil.Discard)
+		defer func() {
+			if r := recover(); r != nil {
+				cli.exit(exitFailure, fmt.Sprint(r))
+			}
+		}()
 	}
 
 	inventoryCmd := flag.NewFlagSet("inventory", flag.ExitOnError)
@@ -50,14 +106,40 @@ func (cli *CLI) Run() {
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	createOrgCmd := flag.NewFlagSet("createorg", flag.ExitOnError)
 	listOrgCmd := flag.NewFlagSet("listorg", flag.ExitOnError)
+	suspendOrgCmd := flag.NewFlagSet("suspendorg", flag.ExitOnError)
+	reinstateOrgCmd := flag.NewFlagSet("reinstateorg", flag.ExitOnError)
+	revokeOrgCmd := flag.NewFlagSet("revokeorg", flag.ExitOnError)
+	rotateKeyCmd := flag.NewFlagSet("rotatekey", flag.ExitOnError)
+	changeRoleCmd := flag.NewFlagSet("changerole", flag.ExitOnError)
+	listProposalsCmd := flag.NewFlagSet("listproposals", flag.ExitOnError)
+	approveCmd := flag.NewFlagSet("approve", flag.ExitOnError)
+	policyCmd := flag.NewFlagSet("policy", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
+	migrateCmd := flag.NewFlagSet("migrate", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sellCmd := flag.NewFlagSet("sell", flag.ExitOnError)
+	claimCmd := flag.NewFlagSet("claim", flag.ExitOnError)
+	decommissionCmd := flag.NewFlagSet("decommission", flag.ExitOnError)
+	offerCmd := flag.NewFlagSet("offer", flag.ExitOnError)
+	acceptCmd := flag.NewFlagSet(acceptAction, flag.ExitOnError)
+	rejectCmd := flag.NewFlagSet(rejectAction, flag.ExitOnError)
+	reclaimCmd := flag.NewFlagSet(reclaimAction, flag.ExitOnError)
 	addProductsCmd := flag.NewFlagSet("addproducts", flag.ExitOnError)
+	updateProductCmd := flag.NewFlagSet("updateproduct", flag.ExitOnError)
+	discontinueProductCmd := flag.NewFlagSet("discontinueproduct", flag.ExitOnError)
 	listProductsCmd := flag.NewFlagSet("listproducts", flag.ExitOnError)
 	produceProductsCmd := flag.NewFlagSet("produceproducts", flag.ExitOnError)
 	getItemDetailsCmd := flag.NewFlagSet("getitemdetails", flag.ExitOnError)
+	verifyItemCmd := flag.NewFlagSet("verifyitem", flag.ExitOnError)
+	getLotCmd := flag.NewFlagSet("getlot", flag.ExitOnError)
+	packCmd := flag.NewFlagSet("pack", flag.ExitOnError)
+	unpackCmd := flag.NewFlagSet("unpack", flag.ExitOnError)
+	exportEPCISCmd := flag.NewFlagSet("exportepcis", flag.ExitOnError)
+	importEPCISCmd := flag.NewFlagSet("importepcis", flag.ExitOnError)
+	recallCmd := flag.NewFlagSet("recall", flag.ExitOnError)
+	recallsCmd := flag.NewFlagSet("recalls", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
 
 	inventoryAddress := inventoryCmd.String("address", "", "The address to get inventory for")
@@ -65,6 +147,9 @@ func (cli *CLI) Run() {
 	createBlockchainPublicKey := createBlockchainCmd.String("publickey", "", "PublicKey of the organisation")
 	createBlockchainGSTIN := createBlockchainCmd.String("gstin", "", "GSTIN of the organisation")
 	createBlockchainPrefix := createBlockchainCmd.String("prefix", "", "Prefix of the organisation")
+	createBlockchainConsensus := createBlockchainCmd.String("consensus", powConsensus, "Consensus engine, pow or poa")
+	createBlockchainPolicy := createBlockchainCmd.String("policy", defaultTransferPolicy, "Allowed transfers between roles as FROM>TO,..., empty to allow any")
+	createBlockchainThreshold := createBlockchainCmd.Int("threshold", 1, "Number of admins that must approve registry changes, 1 for any admin alone")
 	produceProductsAddress := produceProductsCmd.String("address", "", "The address to send produced product to")
 	createOrgAdminAddr := createOrgCmd.String("address", "", "Address of the admin")
 	createOrgName := createOrgCmd.String("name", "", "Name of the organisation")
@@ -72,96 +157,298 @@ func (cli *CLI) Run() {
 	createOrgGSTIN := createOrgCmd.String("gstin", "", "GSTIN of the organisation")
 	createOrgPrefix := createOrgCmd.String("prefix", "", "Prefix of the organisation")
 	createOrgRole := createOrgCmd.String("role", "", "Role of the organisation")
+	suspendOrgAdmin := suspendOrgCmd.String("address", "", "Address of the admin")
+	suspendOrgOrg := suspendOrgCmd.String("org", "", "Address of the organisation to suspend")
+	suspendOrgReason := suspendOrgCmd.String("reason", "", "Reason of the suspension")
+	suspendOrgHeight := suspendOrgCmd.Int("height", 0, "Block height the suspension takes effect at, 0 for the next block")
+	reinstateOrgAdmin := reinstateOrgCmd.String("address", "", "Address of the admin")
+	reinstateOrgOrg := reinstateOrgCmd.String("org", "", "Address of the organisation to reinstate")
+	reinstateOrgReason := reinstateOrgCmd.String("reason", "", "Reason of the reinstatement")
+	reinstateOrgHeight := reinstateOrgCmd.Int("height", 0, "Block height the reinstatement takes effect at, 0 for the next block")
+	revokeOrgAdmin := revokeOrgCmd.String("address", "", "Address of the admin")
+	revokeOrgOrg := revokeOrgCmd.String("org", "", "Address of the organisation to revoke")
+	revokeOrgReason := revokeOrgCmd.String("reason", "", "Reason of the revocation")
+	revokeOrgHeight := revokeOrgCmd.Int("height", 0, "Block height the revocation takes effect at, 0 for the next block")
+	rotateKeySigner := rotateKeyCmd.String("address", "", "Address of the current key of the organisation or of an admin")
+	rotateKeyOrg := rotateKeyCmd.String("org", "", "Address of the organisation, the signer by default")
+	rotateKeyNew := rotateKeyCmd.String("newaddress", "", "Address of the new key")
+	changeRoleAdmin := changeRoleCmd.String("address", "", "Address of the admin")
+	changeRoleOrg := changeRoleCmd.String("org", "", "Address of the organisation")
+	changeRoleRole := changeRoleCmd.String("role", "", "New role of the organisation")
+	approveAdmin := approveCmd.String("address", "", "Address of the admin")
+	approveProposal := approveCmd.String("proposal", "", "ID of the proposal to approve")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
 	sendProduct := sendCmd.String("products", "", "Item to send")
 	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+	sellFrom := sellCmd.String("from", "", "Address of the seller")
+	sellProduct := sellCmd.String("products", "", "Items to sell")
+	sellMine := sellCmd.Bool("mine", false, "Mine immediately on the same node")
+	claimAddress := claimCmd.String("address", "", "Consumer address taking ownership of the item")
+	claimItem := claimCmd.String("item", "", "SGTIN, EPC URI, GS1 element string or Digital Link of the item to claim")
+	claimCode := claimCmd.String("code", "", "Claim code given by the seller")
+	decommissionFrom := decommissionCmd.String("from", "", "Address holding the items")
+	decommissionProduct := decommissionCmd.String("products", "", "Items to decommission")
+	decommissionReason := decommissionCmd.String("reason", "", "Reason code: sold-to-consumer, destroyed, expired, stolen, lost or sample")
+	decommissionMine := decommissionCmd.Bool("mine", false, "Mine immediately on the same node")
+	offerFrom := offerCmd.String("from", "", "Address of the sender")
+	offerTo := offerCmd.String("to", "", "Address the items are offered to")
+	offerProduct := offerCmd.String("products", "", "Items to offer")
+	offerExpiry := offerCmd.Int("expiry", 0, "Height after which the sender may reclaim items not accepted")
+	offerMine := offerCmd.Bool("mine", false, "Mine immediately on the same node")
+	answerAddress := make(map[string]*string)
+	answerProduct := make(map[string]*string)
+	answerMine := make(map[string]*bool)
+	for _, cmd := range []*flag.FlagSet{acceptCmd, rejectCmd, reclaimCmd} {
+		answerAddress[cmd.Name()] = cmd.String("address", "", "Address answering the offers")
+		answerProduct[cmd.Name()] = cmd.String("products", "", "Offered items")
+		answerMine[cmd.Name()] = cmd.Bool("mine", false, "Mine immediately on the same node")
+	}
 	addProductAddress := addProductsCmd.String("address", "", "Product name")
 	addProductsName := addProductsCmd.String("names", "", "Product names")
+	addProductsAttributes := newProductFlags(addProductsCmd)
+	updateProductAddress := updateProductCmd.String("address", "", "Address of the manufacturer")
+	updateProductCode := updateProductCmd.Int("code", 0, "Code of the product to update")
+	updateProductName := updateProductCmd.String("name", "", "New name of the product")
+	updateProductAttributes := newProductFlags(updateProductCmd)
+	discontinueProductAddress := discontinueProductCmd.String("address", "", "Address of the manufacturer")
+	discontinueProductCode := discontinueProductCmd.Int("code", 0, "Code of the product to discontinue")
+	discontinueProductReason := discontinueProductCmd.String("reason", "", "Reason of the discontinuation")
 	listProductsAddress := listProductsCmd.String("address", "", "Source Wallet Address")
+	listProductsHeight := listProductsCmd.Int("height", -1, "Block height to list the catalog as of, the tip by default")
 	cProducts := produceProductsCmd.String("codes", "", "Code of products to produce")
-	cItem := getItemDetailsCmd.String("product", "", "Item to find detail of")
+	produceProductsLot := produceProductsCmd.String("lot", "", "Batch or lot number of the items, GS1 AI 10")
+	produceProductsMfg := produceProductsCmd.String("mfg", "", "Manufacturing date of the items, GS1 AI 11")
+	produceProductsExpiry := produceProductsCmd.String("expiry", "", "Expiry date of the items, GS1 AI 17")
+	cItem := getItemDetailsCmd.String("item", "", "SGTIN, EPC URI, GS1 element string or Digital Link of the item, or SSCC of the logistic unit to trace")
+	verifyItemItem := verifyItemCmd.String("item", "", "SGTIN, EPC URI, GS1 element string or Digital Link of the item to verify")
+	getLotLot := getLotCmd.String("lot", "", "Lot number to list")
+	packAddress := packCmd.String("address", "", "Address holding the items")
+	packItems := packCmd.String("items", "", "Items or logistic units to pack")
+	unpackAddress := unpackCmd.String("address", "", "Address holding the logistic unit")
+	unpackUnit := unpackCmd.String("unit", "", "SSCC of the logistic unit to unpack")
+	exportEPCISItem := exportEPCISCmd.String("item", "", "SGTIN, SSCC, EPC URI, GS1 element string or Digital Link of the item to export")
+	importEPCISAddress := importEPCISCmd.String("address", "", "Address recording the events")
+	importEPCISFile := importEPCISCmd.String("file", "", "EPCIS 2.0 JSON document to import")
+	recallAddress := recallCmd.String("address", "", "Address of the manufacturer")
+	recallCode := recallCmd.Int("code", 0, "Product code to recall")
+	recallFrom := recallCmd.Int("from", 0, "First serial to recall")
+	recallTo := recallCmd.Int("to", 0, "Last serial to recall")
+	recallItems := recallCmd.String("items", "", "Items to recall")
+	recallReason := recallCmd.String("reason", "", "Reason of the recall")
+	recallsHolders := recallsCmd.Bool("holders", false, "List the addresses holding recalled items")
+	startNodeAPI := startNodeCmd.String("api", "", "Address of the HTTP API, e.g. localhost:8080")
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "inventory":
-		err := inventoryCmd.Parse(os.Args[2:])
+		err := inventoryCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createblockchain":
-		err := createBlockchainCmd.Parse(os.Args[2:])
+		err := createBlockchainCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createwallet":
-		err := createWalletCmd.Parse(os.Args[2:])
+		err := createWalletCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createorg":
-		err := createOrgCmd.Parse(os.Args[2:])
+		err := createOrgCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "listorg":
-		err := listOrgCmd.Parse(os.Args[2:])
+		err := listOrgCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "suspendorg":
+		err := suspendOrgCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "reinstateorg":
+		err := reinstateOrgCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "revokeorg":
+		err := revokeOrgCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "rotatekey":
+		err := rotateKeyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "changerole":
+		err := changeRoleCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listproposals":
+		err := listProposalsCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "approve":
+		err := approveCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "policy":
+		err := policyCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "listaddresses":
-		err := listAddressesCmd.Parse(os.Args[2:])
+		err := listAddressesCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "printchain":
-		err := printChainCmd.Parse(os.Args[2:])
+		err := printChainCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "send":
-		err := sendCmd.Parse(os.Args[2:])
+		err := sendCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sell":
+		err := sellCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "claim":
+		err := claimCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "decommission":
+		err := decommissionCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "offer":
+		err := offerCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case acceptAction:
+		err := acceptCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case rejectAction:
+		err := rejectCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case reclaimAction:
+		err := reclaimCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "reindexutxo":
-		err := reindexUTXOCmd.Parse(os.Args[2:])
+		err := reindexUTXOCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "migrate":
+		err := migrateCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "addproducts":
-		err := addProductsCmd.Parse(os.Args[2:])
+		err := addProductsCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "updateproduct":
+		err := updateProductCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "discontinueproduct":
+		err := discontinueProductCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "listproducts":
-		err := listProductsCmd.Parse(os.Args[2:])
+		err := listProductsCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "produceproducts":
-		err := produceProductsCmd.Parse(os.Args[2:])
+		err := produceProductsCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "getitemdetails":
-		err := getItemDetailsCmd.Parse(os.Args[2:])
+		err := getItemDetailsCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifyitem":
+		err := verifyItemCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getlot":
+		err := getLotCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "pack":
+		err := packCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "unpack":
+		err := unpackCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "exportepcis":
+		err := exportEPCISCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importepcis":
+		err := importEPCISCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "recall":
+		err := recallCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "recalls":
+		err := recallsCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "startnode":
-		err := startNodeCmd.Parse(os.Args[2:])
+		err := startNodeCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	default:
 		cli.printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	if inventoryCmd.Parsed() {
 		if *inventoryAddress == "" {
 			inventoryCmd.Usage()
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
 		cli.getInventory(*inventoryAddress, nodeID)
 	}
@@ -169,9 +456,9 @@ func (cli *CLI) Run() {
 	if createBlockchainCmd.Parsed() {
 		if *createBlockchainName == "" || *createBlockchainPublicKey == "" || *createBlockchainGSTIN == "" || *createBlockchainPrefix == "" {
 			createBlockchainCmd.Usage()
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
-		cli.createBlockchain(*createBlockchainName, *createBlockchainPublicKey, *createBlockchainGSTIN, *createBlockchainPrefix, nodeID)
+		cli.createBlockchain(*createBlockchainName, *createBlockchainPublicKey, *createBlockchainGSTIN, *createBlockchainPrefix, *createBlockchainConsensus, *createBlockchainPolicy, *createBlockchainThreshold, nodeID)
 	}
 
 	if createWalletCmd.Parsed() {
@@ -181,7 +468,7 @@ func (cli *CLI) Run() {
 	if createOrgCmd.Parsed() {
 		if *createOrgAdminAddr == "" || *createOrgName == "" || *createOrgPublicKey == "" || *createOrgGSTIN == "" || *createOrgPrefix == "" || *createOrgRole == "" {
 			createOrgCmd.Usage()
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
 
 		cli.createOrg(*createOrgAdminAddr, *createOrgName, *createOrgPublicKey, *createOrgGSTIN, *createOrgPrefix, *createOrgRole, nodeID)
@@ -191,63 +478,261 @@ func (cli *CLI) Run() {
 		cli.listOrganisations(nodeID)
 	}
 
+	if suspendOrgCmd.Parsed() {
+		if *suspendOrgAdmin == "" || *suspendOrgOrg == "" || *suspendOrgReason == "" {
+			suspendOrgCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.changeOrgStatus(suspendAction, *suspendOrgAdmin, *suspendOrgOrg, *suspendOrgReason, *suspendOrgHeight, nodeID)
+	}
+
+	if reinstateOrgCmd.Parsed() {
+		if *reinstateOrgAdmin == "" || *reinstateOrgOrg == "" || *reinstateOrgReason == "" {
+			reinstateOrgCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.changeOrgStatus(reinstateAction, *reinstateOrgAdmin, *reinstateOrgOrg, *reinstateOrgReason, *reinstateOrgHeight, nodeID)
+	}
+
+	if revokeOrgCmd.Parsed() {
+		if *revokeOrgAdmin == "" || *revokeOrgOrg == "" || *revokeOrgReason == "" {
+			revokeOrgCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.changeOrgStatus(revokeAction, *revokeOrgAdmin, *revokeOrgOrg, *revokeOrgReason, *revokeOrgHeight, nodeID)
+	}
+
+	if rotateKeyCmd.Parsed() {
+		if *rotateKeySigner == "" || *rotateKeyNew == "" {
+			rotateKeyCmd.Usage()
+			os.Exit(exitUsage)
+		}
+		if *rotateKeyOrg == "" {
+			*rotateKeyOrg = *rotateKeySigner
+		}
+
+		cli.rotateKey(*rotateKeySigner, *rotateKeyOrg, *rotateKeyNew, nodeID)
+	}
+
+	if changeRoleCmd.Parsed() {
+		if *changeRoleAdmin == "" || *changeRoleOrg == "" || *changeRoleRole == "" {
+			changeRoleCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.changeRole(*changeRoleAdmin, *changeRoleOrg, *changeRoleRole, nodeID)
+	}
+
+	if listProposalsCmd.Parsed() {
+		cli.listProposals(nodeID)
+	}
+
+	if approveCmd.Parsed() {
+		if *approveAdmin == "" || *approveProposal == "" {
+			approveCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.approve(*approveAdmin, *approveProposal, nodeID)
+	}
+
+	if policyCmd.Parsed() {
+		cli.printPolicy(nodeID)
+	}
+
 	if listAddressesCmd.Parsed() {
 		cli.listAddresses(nodeID)
 	}
 
 	if printChainCmd.Parsed() {
-		cli.printTransactionChain(nodeID)
+		cli.printChain(nodeID)
 	}
 
 	if reindexUTXOCmd.Parsed() {
 		cli.reindexUTXO(nodeID)
 	}
 
+	if migrateCmd.Parsed() {
+		cli.migrate(nodeID)
+	}
+
 	if sendCmd.Parsed() {
 		if *sendFrom == "" || *sendTo == "" || *sendProduct == "" {
 			sendCmd.Usage()
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
 
 		cli.send(*sendFrom, *sendTo, *sendProduct, nodeID, *sendMine)
 	}
 
+	if sellCmd.Parsed() {
+		if *sellFrom == "" || *sellProduct == "" {
+			sellCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.sell(*sellFrom, *sellProduct, nodeID, *sellMine)
+	}
+
+	if claimCmd.Parsed() {
+		if *claimAddress == "" || *claimItem == "" || *claimCode == "" {
+			claimCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.claim(*claimAddress, *claimItem, *claimCode, nodeID)
+	}
+
+	if decommissionCmd.Parsed() {
+		if *decommissionFrom == "" || *decommissionProduct == "" || *decommissionReason == "" {
+			decommissionCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.decommission(*decommissionFrom, *decommissionProduct, *decommissionReason, nodeID, *decommissionMine)
+	}
+
+	if offerCmd.Parsed() {
+		if *offerFrom == "" || *offerTo == "" || *offerProduct == "" {
+			offerCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.offer(*offerFrom, *offerTo, *offerProduct, *offerExpiry, nodeID, *offerMine)
+	}
+
+	for _, cmd := range []*flag.FlagSet{acceptCmd, rejectCmd, reclaimCmd} {
+		if !cmd.Parsed() {
+			continue
+		}
+		if *answerAddress[cmd.Name()] == "" || *answerProduct[cmd.Name()] == "" {
+			cmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.answerOffers(*answerAddress[cmd.Name()], *answerProduct[cmd.Name()], cmd.Name(), nodeID, *answerMine[cmd.Name()])
+	}
+
 	if addProductsCmd.Parsed() {
 		if *addProductAddress == "" || *addProductsName == "" {
 			addProductsCmd.Usage()
-			os.Exit(1)
+			os.Exit(exitUsage)
+		}
+		cli.addProducts(*addProductAddress, *addProductsName, cli.productAttributes(addProductsAttributes), nodeID)
+	}
+
+	if updateProductCmd.Parsed() {
+		if *updateProductAddress == "" || *updateProductCode == 0 {
+			updateProductCmd.Usage()
+			os.Exit(exitUsage)
+		}
+		cli.updateProduct(*updateProductAddress, *updateProductCode, *updateProductName, cli.productAttributes(updateProductAttributes), nodeID)
+	}
+
+	if discontinueProductCmd.Parsed() {
+		if *discontinueProductAddress == "" || *discontinueProductCode == 0 || *discontinueProductReason == "" {
+			discontinueProductCmd.Usage()
+			os.Exit(exitUsage)
 		}
-		cli.addProducts(*addProductAddress, *addProductsName, nodeID)
+		cli.discontinueProduct(*discontinueProductAddress, *discontinueProductCode, *discontinueProductReason, nodeID)
 	}
 
 	if listProductsCmd.Parsed() {
 		if *listProductsAddress == "" {
 			listProductsCmd.Usage()
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
-		cli.listProducts(*listProductsAddress, nodeID)
+		cli.listProducts(*listProductsAddress, *listProductsHeight, nodeID)
 	}
 
 	if produceProductsCmd.Parsed() {
 		if *produceProductsAddress == "" || *cProducts == "" {
 			produceProductsCmd.Usage()
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
 
-		cli.produceProducts(*produceProductsAddress, *cProducts, nodeID)
+		cli.produceProducts(*produceProductsAddress, *cProducts, *produceProductsLot, *produceProductsMfg, *produceProductsExpiry, nodeID)
 	}
 
 	if getItemDetailsCmd.Parsed() {
 		if *cItem == "" {
 			getItemDetailsCmd.Usage()
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
 
 		cli.getItemDetails(*cItem, nodeID)
 	}
 
+	if verifyItemCmd.Parsed() {
+		if *verifyItemItem == "" {
+			verifyItemCmd.Usage()
+			os.Exit(exitUsage)
+		}
+		cli.verifyItem(*verifyItemItem, nodeID)
+	}
+
+	if getLotCmd.Parsed() {
+		if *getLotLot == "" {
+			getLotCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.getLot(*getLotLot, nodeID)
+	}
+
+	if packCmd.Parsed() {
+		if *packAddress == "" || *packItems == "" {
+			packCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.pack(*packAddress, *packItems, nodeID)
+	}
+
+	if unpackCmd.Parsed() {
+		if *unpackAddress == "" || *unpackUnit == "" {
+			unpackCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.unpack(*unpackAddress, *unpackUnit, nodeID)
+	}
+
+	if exportEPCISCmd.Parsed() {
+		cli.exportEPCIS(*exportEPCISItem, nodeID)
+	}
+
+	if importEPCISCmd.Parsed() {
+		if *importEPCISAddress == "" || *importEPCISFile == "" {
+			importEPCISCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.importEPCIS(*importEPCISAddress, *importEPCISFile, nodeID)
+	}
+
+	if recallCmd.Parsed() {
+		if *recallAddress == "" || *recallReason == "" || (*recallCode == 0) == (*recallItems == "") {
+			recallCmd.Usage()
+			os.Exit(exitUsage)
+		}
+
+		cli.recall(*recallAddress, *recallCode, *recallItems, *recallFrom, *recallTo, *recallReason, nodeID)
+	}
+
+	if recallsCmd.Parsed() {
+		if *recallsHolders {
+			cli.listRecallHolders(nodeID)
+		} else {
+			cli.listRecalls(nodeID)
+		}
+	}
+
 	if startNodeCmd.Parsed() {
-		cli.startNode(nodeID)
+		cli.startNode(nodeID, *startNodeAPI)
 	}
 
 }
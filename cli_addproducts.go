@@ -1,25 +1,74 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
 	"fmt"
-	"log"
+	"io/ioutil"
 	"strings"
 )
 
-func (cli *CLI) addProducts(address string, name string, nodeID string) {
+// productFlags are the flags setting the attributes of catalog entries
+type productFlags struct {
+	gtin        *string
+	description *string
+	brand       *string
+	category    *string
+	hsCode      *string
+	quantity    *string
+	unit        *string
+	spec        *string
+}
+
+// newProductFlags defines the flags setting the attributes of catalog entries on cmd
+func newProductFlags(cmd *flag.FlagSet) productFlags {
+	return productFlags{
+		cmd.String("gtin", "", "GTIN-8, GTIN-12, GTIN-13 or GTIN-14 of the product"),
+		cmd.String("description", "", "Description of the product"),
+		cmd.String("brand", "", "Brand of the product"),
+		cmd.String("category", "", "Category of the product"),
+		cmd.String("hscode", "", "HS code of the product, 6 to 10 digits"),
+		cmd.String("quantity", "", "Net quantity of the product, in -unit"),
+		cmd.String("unit", "", "Unit of the net quantity, e.g. g or ml"),
+		cmd.String("spec", "", "Image or spec sheet of the product, recorded as its SHA-256"),
+	}
+}
+
+// productAttributes returns the attributes set by flags, nil if none is set
+func (cli *CLI) productAttributes(flags productFlags) *ProductAttributesView {
+	attributes := ProductAttributesView{*flags.gtin, *flags.description, *flags.brand, *flags.category, *flags.hsCode, *flags.quantity, *flags.unit, ""}
+
+	if *flags.spec != "" {
+		data, err := ioutil.ReadFile(*flags.spec)
+		if err != nil {
+			cli.exit(exitUsage, "ERROR: "+err.Error())
+		}
+		hash := sha256.Sum256(data)
+		attributes.SpecHash = hex.EncodeToString(hash[:])
+	}
+
+	if attributes == (ProductAttributesView{}) {
+		return nil
+	}
+
+	return &attributes
+}
+
+func (cli *CLI) addProducts(address string, name string, attributes *ProductAttributesView, nodeID string) {
 	products := strings.Split(name, ",")
 	if !ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+		cli.exit(exitUsage, "ERROR: Address is not valid")
 	}
 
-	
-	productArr, err := NewProducts(address, products, nodeID)
-	if err != nil {
-		fmt.Println(err)
-	} else {
-		bc := NewBlockchain(nodeID)
-		defer bc.db.Close()
-		bc.MineBlock(nil, productArr, nil, address)
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var productArr ProductViews
+	err := client.Post("/control/products", addProductsRequest{address, products, attributes}, &productArr)
+	cli.check(err)
+
+	cli.print(productArr, func() {
 		fmt.Println("Success!")
-	}
+	})
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (cli *CLI) approve(address, proposal, nodeID string) {
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var view ProposalView
+	err := client.Post("/control/proposals/approve", approveRequest{address, proposal}, &view)
+	cli.check(err)
+
+	cli.printProposal(view)
+}
+
+// printProposal prints a registry change waiting for approvals, or recorded
+// with the last one
+func (cli *CLI) printProposal(proposal ProposalView) {
+	cli.print(proposal, func() {
+		if proposal.Recorded {
+			fmt.Printf("Recorded %s of %s approved by %d admins\n", proposal.Type, proposal.Organisation, len(proposal.Approvers))
+			return
+		}
+
+		fmt.Printf("Proposed %s of %s: %d of %d approvals, approve with -proposal %s\n", proposal.Type, proposal.Organisation, len(proposal.Approvers), proposal.Required, proposal.ID)
+	})
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (cli *CLI) changeRole(address, org, role, nodeID string) {
+	if !ValidateAddress(address) || !ValidateAddress(org) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var change RoleChangeView
+	proposal, err := client.Propose("/control/organisations/role", roleChangeRequest{address, org, role}, &change)
+	cli.check(err)
+	if proposal != nil {
+		cli.printProposal(*proposal)
+		return
+	}
+
+	cli.print(change, func() {
+		fmt.Printf("Changed role of %s to %s\n", change.Organisation, change.Role)
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (cli *CLI) sell(from string, productstring string, nodeID string, mineNow bool) {
+	products := strings.Split(productstring, ",")
+	if !ValidateAddress(from) {
+		cli.exit(exitUsage, "ERROR: Seller address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var sale SaleView
+	err := client.Post("/control/sell", saleRequest{from, products, mineNow}, &sale)
+	cli.check(err)
+
+	cli.print(sale, func() {
+		fmt.Println("Success! Give each buyer the claim code of their item, it is shown only once:")
+		for _, code := range sale.Codes {
+			fmt.Printf("  %s  %s\n", code.Item, code.Code)
+		}
+	})
+}
+
+func (cli *CLI) claim(address, item, code string, nodeID string) {
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var tx TransactionView
+	err := client.Post("/control/claim", claimRequest{address, item, code}, &tx)
+	cli.check(err)
+
+	cli.print(tx, func() {
+		fmt.Printf("Claimed %s for %s\n", tx.Outputs[0].Item, address)
+	})
+}
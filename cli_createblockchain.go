@@ -4,7 +4,35 @@ import (
 	"fmt"
 )
 
-func (cli *CLI) createBlockchain(name, pubKey, gstin, prefix string, nodeID string) {
-	CreateBlockchain(name, pubKey, gstin, prefix, nodeID)
-	fmt.Println("Done!")
+func (cli *CLI) createBlockchain(name, pubKey, gstin, prefix, consensus, rules string, threshold int, nodeID string) {
+	var policy *TransferPolicy
+	var governance *Governance
+
+	if rules != "" {
+		var err error
+		policy, err = ParseTransferPolicy(rules)
+		if err != nil {
+			cli.exit(exitUsage, err.Error())
+		}
+	}
+
+	if consensus != powConsensus && consensus != poaConsensus {
+		cli.exit(exitUsage, fmt.Sprintf("ERROR: Unknown consensus engine %q, expected %s or %s", consensus, powConsensus, poaConsensus))
+	}
+
+	if threshold < 0 {
+		cli.exit(exitUsage, "ERROR: Threshold must not be negative")
+	}
+	if threshold > 1 {
+		governance = NewGovernance(threshold)
+	}
+
+	genesis := CreateBlockchain(name, pubKey, gstin, prefix, consensus, policy, governance, nodeID)
+
+	view := NewBlockView(genesis)
+	view.Sealed = true
+
+	cli.print(view, func() {
+		fmt.Println("Done!")
+	})
 }
@@ -2,22 +2,25 @@ package main
 
 import (
 	"fmt"
-	"log"
 )
 
 func (cli *CLI) createOrg(address, name, publicKey, gstin, prefix, role, nodeID string) {
 	if !ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+		cli.exit(exitUsage, "ERROR: Address is not valid")
 	}
 
-	bc := NewBlockchain(nodeID)
-	OrganisationCache := OrganisationCacheSet{bc}
-	defer bc.db.Close()
-	org, err := NewOrganisation(address, name, publicKey, gstin, prefix, role, &OrganisationCache)
-	if err != nil {
-		fmt.Println(err)
-	} else {
-		bc.MineBlock(nil, nil, org, address)
-		fmt.Println("Success!")
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var org OrganisationView
+	proposal, err := client.Propose("/control/organisations", createOrgRequest{address, name, publicKey, gstin, prefix, role}, &org)
+	cli.check(err)
+	if proposal != nil {
+		cli.printProposal(*proposal)
+		return
 	}
+
+	cli.print(org, func() {
+		fmt.Println("Success!")
+	})
 }
@@ -1,13 +1,20 @@
 package main
 
-import "fmt"
+import (
+	"encoding/hex"
+	"fmt"
+)
 
 func (cli *CLI) createWallet(nodeID string) {
 	wallets, _ := NewWallets(nodeID)
 	address := wallets.CreateWallet()
 	wallets.SaveToFile(nodeID)
 
-	fmt.Printf("Your new address: %s\n", address)
+	wallet := wallets.GetWallet(address)
+
+	cli.print(AddressView{address, hex.EncodeToString(wallet.PublicKey)}, func() {
+		fmt.Printf("Your new address: %s\n", address)
+	})
 	//pubKeyHash := Base58Decode([]byte(address))
 	//pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
 	//fmt.Println(fmt.Sprintf("Inside Lock: %x", pubKeyHash))
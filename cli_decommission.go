@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (cli *CLI) decommission(from string, productstring string, reason string, nodeID string, mineNow bool) {
+	products := strings.Split(productstring, ",")
+	if !ValidateAddress(from) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+	if !IsDecommissionReason(reason) {
+		cli.exit(exitUsage, fmt.Sprintf("ERROR: Reason must be one of %s", strings.Join(decommissionReasons, ", ")))
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var tx TransactionView
+	err := client.Post("/control/decommission", decommissionRequest{from, products, reason, mineNow}, &tx)
+	cli.check(err)
+
+	cli.print(tx, func() {
+		fmt.Printf("Decommissioned %d items as %s\n", len(tx.Outputs), reason)
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+)
+
+func (cli *CLI) exportEPCIS(item string, nodeID string) {
+	// a link does not fit in the path of the request
+	if isDigitalLink(item) {
+		element, err := DigitalLinkElementString(item)
+		if err != nil {
+			cli.exit(exitUsage, "ERROR: "+err.Error())
+		}
+		item = element
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	path := "/epcis"
+	if item != "" {
+		path += "/" + url.PathEscape(item)
+	}
+
+	var doc EPCISDocument
+	err := client.Get(path, &doc)
+	cli.check(err)
+
+	cli.print(doc, func() {
+		// an EPCIS document is JSON-LD, so it is printed as JSON
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		cli.check(encoder.Encode(doc))
+	})
+}
+
+func (cli *CLI) importEPCIS(address string, file string, nodeID string) {
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		cli.exit(exitUsage, "ERROR: "+err.Error())
+	}
+
+	var doc EPCISDocument
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		cli.exit(exitUsage, fmt.Sprintf("ERROR: %s is not an EPCIS JSON document: %s", file, err))
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var txs TransactionViews
+	err = client.Post("/control/epcis", epcisImportRequest{address, doc}, &txs)
+	cli.check(err)
+
+	cli.print(txs, func() {
+		fmt.Printf("Recorded %d EPCIS events\n", len(txs))
+	})
+}
@@ -2,29 +2,62 @@ package main
 
 import (
 	"fmt"
-	"log"
 )
 
 func (cli *CLI) getInventory(address string, nodeID string) {
 	if !ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+		cli.exit(exitUsage, "ERROR: Address is not valid")
 	}
-	bc := NewBlockchain(nodeID)
-	UTXOSet := UTXOSet{bc}
-	//fmt.Printf("%+v\n", UTXOSet.Blockchain)
-	defer bc.db.Close()
-
-	pubKeyHash := Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
-	if len(UTXOs) == 0 {
-		fmt.Printf("Empty Inventory of '%s'\n", address)
-	} else {
-		fmt.Printf("Items in Inventory of '%s'\n", address)
-
-		for count, out := range UTXOs {
-			fmt.Printf("Item %d : %s ", count+1, out.Item)
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var inventory InventoryView
+	err := client.Get("/inventory/"+address, &inventory)
+	cli.check(err)
+
+	cli.print(inventory, func() {
+		if len(inventory.Items) == 0 {
+			fmt.Printf("Empty Inventory of '%s'\n", address)
+		} else {
+			fmt.Printf("Items in Inventory of '%s'\n", address)
+
+			for count, out := range inventory.Items {
+				item := out.Item
+				if out.Recalled {
+					item += " (RECALLED)"
+				}
+				if out.ClaimHash != "" {
+					item += " (SOLD, NOT CLAIMED)"
+				}
+				item += out.Batch.Label()
+				if len(out.Contents) > 0 {
+					item += fmt.Sprintf(" [%s]", formatPackedItemViews(out.Contents))
+				}
+
+				fmt.Printf("Item %d : %s ", count+1, item)
+			}
 		}
-	}
 
+		if len(inventory.Incoming) > 0 {
+			fmt.Println("\nIncoming offers:")
+			for _, out := range inventory.Incoming {
+				fmt.Printf("  %s from %s%s\n", out.Item, out.Address, offerExpiryLabel(out))
+			}
+		}
+		if len(inventory.Outgoing) > 0 {
+			fmt.Println("\nOutgoing offers:")
+			for _, out := range inventory.Outgoing {
+				fmt.Printf("  %s to %s%s\n", out.Item, out.OfferTo, offerExpiryLabel(out))
+			}
+		}
+	})
+}
+
+// offerExpiryLabel describes the expiry of an offer in the text output
+func offerExpiryLabel(out OutputView) string {
+	if out.OfferExpiry == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", expires after height %d", out.OfferExpiry)
 }
@@ -2,40 +2,118 @@ package main
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 )
 
+// eventKinds name the actions of a chain of custody in the text output
+var eventKinds = map[string]string{
+	mintAction:          "Mint",
+	transferAction:      "Transfer",
+	packAction:          "Pack",
+	unpackAction:        "Unpack",
+	sellAction:          "Sale",
+	claimAction:         "Claim",
+	rejectedClaimAction: "REJECTED CLAIM",
+	decommissionAction:  "Decommission",
+	offerAction:         "Offer",
+	acceptAction:        "Accept",
+	rejectAction:        "Reject",
+	reclaimAction:       "Reclaim",
+}
+
 func (cli *CLI) getItemDetails(item string, nodeID string) {
-	bc := NewBlockchain(nodeID)
-	defer bc.db.Close()
-
-	if len(bc.tip1) == 0 {
-		fmt.Printf("Transaction Blockchain Empty")
-	} else {
-		bci := bc.Iterator()
-
-		for {
-			block := bci.Next1()
-
-			//fmt.Printf("============ Block %x ============\n", block.Hash)
-			//fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
-			//pow := NewProofOfWork(block)
-			//fmt.Printf("PoW: %s\n\n", strconv.FormatBool(pow.Validate()))
-
-			for _, tx := range block.Transactions {
-
-				for _, out := range tx.Vout {
-					//fmt.Println("out" + out.Item)
-					//fmt.Println(item)
-					if out.Item == item {
-						fmt.Println(fmt.Sprintf("%s", Base58Encode(out.PubKeyHash)))
-					}
+	// a link does not fit in the path of the request
+	if isDigitalLink(item) {
+		element, err := DigitalLinkElementString(item)
+		if err != nil {
+			cli.exit(exitUsage, "ERROR: "+err.Error())
+		}
+		item = element
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var history ItemHistoryView
+	err := client.Get("/items/"+url.PathEscape(item), &history)
+	cli.check(err)
+
+	cli.print(history, func() {
+		fmt.Printf("Chain of custody of %s\n", history.Item)
+		if history.GS1 != nil {
+			fmt.Printf("EPC:          %s\n", history.GS1.EPC)
+			fmt.Printf("GTIN:         %s\n", history.GS1.GTIN)
+			fmt.Printf("GS1:          %s\n", history.GS1.ElementString)
+			fmt.Printf("Digital Link: %s\n", history.GS1.DigitalLink)
+		}
+		if history.Batch != nil {
+			fmt.Printf("Batch: %s%s\n", history.Batch, history.Batch.Label())
+		}
+		if history.Recall != nil {
+			fmt.Printf("RECALLED: %s (recall %s)\n", history.Recall.Reason, history.Recall.ID)
+		}
+
+		var containers []string
+		seen := make(map[string]bool)
+
+		for i, event := range history.Events {
+			fmt.Printf("\n#%d %s at height %d, %s\n", i+1, eventKinds[event.Action], event.Height, time.Unix(event.Timestamp, 0).UTC().Format(time.RFC3339))
+			fmt.Printf("    TX:   %s:%d\n", event.Txid, event.Index)
+			if len(event.Containers) > 0 {
+				fmt.Printf("    In:   %s\n", strings.Join(event.Containers, " > "))
+			}
+			if event.Action == rejectedClaimAction {
+				fmt.Printf("    By:   %s\n", event.Sender)
+			} else {
+				if event.Sender != nil {
+					fmt.Printf("    From: %s\n", event.Sender)
 				}
+				fmt.Printf("    To:   %s\n", event.Receiver)
+			}
+			if event.Reason != "" {
+				fmt.Printf("    Reason: %s\n", event.Reason)
+			}
+			if event.OfferedTo != nil {
+				fmt.Printf("    Offered to: %s\n", event.OfferedTo)
+			}
 
+			for _, issue := range event.Issues {
+				fmt.Printf("    ISSUE: %s\n", issue)
 			}
 
-			if len(block.PrevBlockHash) == 0 {
-				break
+			for _, container := range event.Containers {
+				if !seen[container] {
+					seen[container] = true
+					containers = append(containers, container)
+				}
 			}
 		}
-	}
+
+		if len(containers) > 0 {
+			fmt.Printf("\nPacked in: %s\n", strings.Join(containers, ", "))
+		}
+
+		if history.Owner != nil {
+			fmt.Printf("\nCurrent owner: %s\n", history.Owner)
+		} else {
+			fmt.Printf("\nCurrent owner: none\n")
+		}
+		if history.Intact {
+			fmt.Println("Chain of custody is intact")
+		} else {
+			fmt.Println("Chain of custody has gaps or broken links")
+		}
+
+		rejected := 0
+		for _, event := range history.Events {
+			if event.Action == rejectedClaimAction {
+				rejected++
+			}
+		}
+		if rejected > 0 {
+			fmt.Printf("%d claims were rejected, the item or its claim code may have been copied\n", rejected)
+		}
+	})
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+func (cli *CLI) getLot(lot string, nodeID string) {
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var view LotView
+	err := client.Get("/lots/"+url.PathEscape(lot), &view)
+	cli.check(err)
+
+	cli.print(view, func() {
+		fmt.Printf("Items of lot %s\n", view.Lot)
+
+		for _, entry := range view.Items {
+			item := entry.Item
+			if entry.Recalled {
+				item += " (RECALLED)"
+			}
+			item += entry.Batch.Label()
+
+			fmt.Printf("\n%s\n", item)
+			fmt.Printf("    Batch: %s\n", entry.Batch)
+			fmt.Printf("    Owner: %s\n", entry.Owner)
+			if len(entry.Containers) > 0 {
+				fmt.Printf("    In:    %s\n", strings.Join(entry.Containers, " > "))
+			}
+		}
+	})
+}
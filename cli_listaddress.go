@@ -1,21 +1,28 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
-	"log"
+	"sort"
 )
 
 func (cli *CLI) listAddresses(nodeID string) {
 	wallets, err := NewWallets(nodeID)
-	if err != nil {
-		log.Panic(err)
-	}
+	cli.check(err)
+
 	addresses := wallets.GetAddresses()
+	sort.Strings(addresses)
 
+	var views AddressViews
 	for _, address := range addresses {
-		fmt.Printf("Address: %s\n", address)
 		wallet := wallets.GetWallet(address)
-		fmt.Printf(fmt.Sprintf("PubKey: %x \n", wallet.PublicKey))
+		views = append(views, AddressView{address, hex.EncodeToString(wallet.PublicKey)})
 	}
 
+	cli.print(views, func() {
+		for _, view := range views {
+			fmt.Printf("Address: %s\n", view.Address)
+			fmt.Printf("PubKey: %s \n", view.PubKey)
+		}
+	})
 }
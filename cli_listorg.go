@@ -5,24 +5,16 @@ import (
 )
 
 func (cli *CLI) listOrganisations(nodeID string) {
-	bc := NewBlockchain(nodeID)
-	defer bc.db.Close()
+	client := NewNodeClient(nodeID)
+	defer client.Close()
 
-	if len(bc.tip3) == 0 {
-		fmt.Printf("Organisation Blockchain Empty")
-	} else {
-		bci := bc.Iterator()
+	var orgs OrganisationViews
+	err := client.Get("/organisations", &orgs)
+	cli.check(err)
 
-		for {
-			block := bci.Next3()
-			org := block.Organisation
+	cli.print(orgs, func() {
+		for _, org := range orgs {
 			fmt.Printf("%s\n", org)
-
-			if len(block.PrevBlockHash) == 0 {
-				break
-			}
 		}
-
-	}
-
+	})
 }
@@ -4,29 +4,34 @@ import (
 	"fmt"
 )
 
-func (cli *CLI) listProducts(address string, nodeID string) {
-	bc := NewBlockchain(nodeID)
-	defer bc.db.Close()
+func (cli *CLI) listProducts(address string, height int, nodeID string) {
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
 
-	if len(bc.tip2) == 0 {
-		fmt.Printf("Product Blockchain Empty")
-	} else {
-		bci := bc.Iterator()
+	path := "/products/" + address
+	if height >= 0 {
+		path = fmt.Sprintf("%s?height=%d", path, height)
+	}
 
-		for {
-			block := bci.Next2()
+	client := NewNodeClient(nodeID)
+	defer client.Close()
 
-			for _, p := range block.Products {
-				if p.Verify(Base58Decode([]byte(address))) {
-					fmt.Printf("Name: %s\n", p.Name)
-					fmt.Printf("Code: %d\n", p.Code)
-				}
-			}
+	var products ProductViews
+	err := client.Get(path, &products)
+	cli.check(err)
 
-			if len(block.PrevBlockHash) == 0 {
-				break
+	cli.print(products, func() {
+		for _, p := range products {
+			fmt.Printf("Name: %s\n", p.Name)
+			fmt.Printf("Code: %d\n", p.Code)
+			fmt.Printf("Version: %d\n", p.Version)
+			if p.Status == discontinuedStatus {
+				fmt.Printf("Status: %s (%s)\n", p.Status, p.Versions[len(p.Versions)-1].Reason)
+			}
+			for _, field := range p.Attributes.fields() {
+				fmt.Printf("%s: %s\n", field[0], field[1])
 			}
 		}
-	}
-
+	})
 }
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (cli *CLI) listProposals(nodeID string) {
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var proposals ProposalViews
+	err := client.Get("/proposals", &proposals)
+	cli.check(err)
+
+	cli.print(proposals, func() {
+		for _, proposal := range proposals {
+			fmt.Printf("%s\n", proposal)
+		}
+	})
+}
@@ -0,0 +1,5 @@
+package main
+
+func (cli *CLI) migrate(nodeID string) {
+	MigrateBlockchain(nodeID)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (cli *CLI) offer(from, to string, productstring string, expiry int, nodeID string, mineNow bool) {
+	products := strings.Split(productstring, ",")
+	if !ValidateAddress(from) {
+		cli.exit(exitUsage, "ERROR: Sender address is not valid")
+	}
+	if !ValidateAddress(to) {
+		cli.exit(exitUsage, "ERROR: Recipient address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var tx TransactionView
+	err := client.Post("/control/offers", offerRequest{from, to, products, expiry, mineNow}, &tx)
+	cli.check(err)
+
+	cli.print(tx, func() {
+		if expiry > 0 {
+			fmt.Printf("Offered %d items to %s until height %d\n", len(tx.Outputs), to, expiry)
+		} else {
+			fmt.Printf("Offered %d items to %s\n", len(tx.Outputs), to)
+		}
+	})
+}
+
+func (cli *CLI) answerOffers(address string, productstring string, action string, nodeID string, mineNow bool) {
+	products := strings.Split(productstring, ",")
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var tx TransactionView
+	err := client.Post("/control/offers/"+action, offerAnswerRequest{address, products, mineNow}, &tx)
+	cli.check(err)
+
+	cli.print(tx, func() {
+		for _, out := range tx.Outputs {
+			switch action {
+			case acceptAction:
+				fmt.Printf("Accepted %s\n", out.Item)
+			case rejectAction:
+				fmt.Printf("Rejected %s, returned to %s\n", out.Item, out.Address)
+			case reclaimAction:
+				fmt.Printf("Reclaimed %s\n", out.Item)
+			}
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+)
+
+// statusChangeMessages are the text outputs of the status change commands
+var statusChangeMessages = map[string]string{
+	suspendAction:   "Suspended %s from height %d\n",
+	reinstateAction: "Reinstated %s from height %d\n",
+	revokeAction:    "Revoked %s from height %d\n",
+}
+
+func (cli *CLI) changeOrgStatus(action, address, org, reason string, height int, nodeID string) {
+	if !ValidateAddress(address) || !ValidateAddress(org) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+	if height < 0 {
+		cli.exit(exitUsage, "ERROR: Height must not be negative")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var change StatusChangeView
+	proposal, err := client.Propose("/control/organisations/status", statusChangeRequest{address, org, action, reason, height}, &change)
+	cli.check(err)
+	if proposal != nil {
+		cli.printProposal(*proposal)
+		return
+	}
+
+	cli.print(change, func() {
+		fmt.Printf(statusChangeMessages[action], change.Organisation, change.Height)
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (cli *CLI) pack(address string, items string, nodeID string) {
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var tx TransactionView
+	err := client.Post("/control/pack", packRequest{address, strings.Split(items, ",")}, &tx)
+	cli.check(err)
+
+	cli.print(tx, func() {
+		fmt.Printf("Packed %d items into %s\n", len(tx.Outputs[0].Contents), tx.Outputs[0].Item)
+	})
+}
+
+func (cli *CLI) unpack(address string, unit string, nodeID string) {
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var tx TransactionView
+	err := client.Post("/control/unpack", unpackRequest{address, unit}, &tx)
+	cli.check(err)
+
+	cli.print(tx, func() {
+		fmt.Printf("Unpacked %s into %d items\n", unit, len(tx.Outputs))
+	})
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (cli *CLI) printPolicy(nodeID string) {
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var policy PolicyView
+	err := client.Get("/policy", &policy)
+	cli.check(err)
+
+	cli.print(policy, func() {
+		fmt.Printf("%s\n", policy)
+	})
+}
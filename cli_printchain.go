@@ -5,33 +5,26 @@ import (
 	"strconv"
 )
 
-func (cli *CLI) printTransactionChain(nodeID string) {
-	bc := NewBlockchain(nodeID)
-	defer bc.db.Close()
+func (cli *CLI) printChain(nodeID string) {
+	client := NewNodeClient(nodeID)
+	defer client.Close()
 
-	if len(bc.tip1) == 0 {
-		fmt.Printf("Transaction Blockchain Empty")
-	} else {
-		bci := bc.Iterator()
+	var blocks BlockViews
+	err := client.Get("/blocks", &blocks)
+	cli.check(err)
 
-		for {
-			block := bci.Next1()
-
-			fmt.Printf("============ Block %x ============\n", block.Hash)
+	cli.print(blocks, func() {
+		for _, block := range blocks {
+			fmt.Printf("============ Block %s ============\n", block.Hash)
 			fmt.Printf("Height: %d\n", block.Height)
-			fmt.Printf("Prev. block: %x\n", block.PrevBlockHash)
-			pow := NewProofOfWork(block)
-			fmt.Printf("PoW: %s\n\n", strconv.FormatBool(pow.Validate()))
+			fmt.Printf("Prev. block: %s\n", block.PrevHash)
+			fmt.Printf("Seal: %s\n\n", strconv.FormatBool(block.Sealed))
 
-			for _, tx := range block.Transactions {
-				fmt.Println(tx)
+			for _, e := range block.Entries {
+				fmt.Println(e)
 			}
 
 			fmt.Printf("\n\n")
-
-			if len(block.PrevBlockHash) == 0 {
-				break
-			}
 		}
-	}
+	})
 }
@@ -2,26 +2,26 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"strings"
 )
 
-func (cli *CLI) produceProducts(address string, productcodes string, nodeID string) {
+func (cli *CLI) produceProducts(address string, productcodes string, lot, manufactured, expiry string, nodeID string) {
 	codes := strings.Split(productcodes, ",")
 	if !ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+		cli.exit(exitUsage, "ERROR: Address is not valid")
 	}
+	if _, err := NewBatch(lot, manufactured, expiry); err != nil {
+		cli.exit(exitUsage, "ERROR: "+err.Error())
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
 
-	bc := NewBlockchain(nodeID)
-	UTXOSet := UTXOSet{bc}
-	defer bc.db.Close()
-	cbTx, err := NewCoinbaseTX(&UTXOSet, address, codes, nodeID)
-	if err != nil {
-		fmt.Println(err)
-	} else {
-		txs := []*Transaction{cbTx}
-		newBlock := bc.MineBlock(txs, nil, nil, "")
-		UTXOSet.Update(newBlock)
+	var cbTx TransactionView
+	err := client.Post("/control/produce", produceRequest{address, codes, lot, manufactured, expiry}, &cbTx)
+	cli.check(err)
+
+	cli.print(cbTx, func() {
 		fmt.Println("Success!")
-	}
+	})
 }
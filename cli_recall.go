@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (cli *CLI) recall(address string, code int, itemstring string, fromSerial, toSerial int, reason string, nodeID string) {
+	var items []string
+	if itemstring != "" {
+		items = strings.Split(itemstring, ",")
+	}
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var recall RecallView
+	err := client.Post("/control/recalls", recallRequest{address, code, items, fromSerial, toSerial, reason}, &recall)
+	cli.check(err)
+
+	cli.print(recall, func() {
+		fmt.Printf("Recalled %s\n", recall.Scope())
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (cli *CLI) listRecalls(nodeID string) {
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var recalls RecallViews
+	err := client.Get("/recalls", &recalls)
+	cli.check(err)
+
+	cli.print(recalls, func() {
+		for _, recall := range recalls {
+			fmt.Printf("%s\n", recall)
+		}
+	})
+}
+
+func (cli *CLI) listRecallHolders(nodeID string) {
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var holders RecallHolderViews
+	err := client.Get("/recalls/holders", &holders)
+	cli.check(err)
+
+	cli.print(holders, func() {
+		if len(holders) == 0 {
+			fmt.Println("No recalled items are held")
+		}
+
+		for _, holder := range holders {
+			fmt.Printf("%s holds %d recalled items: %s\n", holder.Address, len(holder.Items), strings.Join(holder.Items, ", "))
+		}
+	})
+}
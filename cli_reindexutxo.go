@@ -3,10 +3,14 @@ package main
 import "fmt"
 
 func (cli *CLI) reindexUTXO(nodeID string) {
-	bc := NewBlockchain(nodeID)
-	UTXOSet := UTXOSet{bc}
-	UTXOSet.Reindex()
+	client := NewNodeClient(nodeID)
+	defer client.Close()
 
-	count := UTXOSet.CountTransactions()
-	fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
+	var utxoSet UTXOSetView
+	err := client.Post("/control/reindex", nil, &utxoSet)
+	cli.check(err)
+
+	cli.print(utxoSet, func() {
+		fmt.Printf("Done! There are %d transactions in the UTXO set.\n", utxoSet.Transactions)
+	})
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (cli *CLI) rotateKey(address, org, newAddress, nodeID string) {
+	if !ValidateAddress(address) || !ValidateAddress(org) || !ValidateAddress(newAddress) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var rotation KeyRotationView
+	proposal, err := client.Propose("/control/organisations/keys", keyRotationRequest{address, org, newAddress}, &rotation)
+	cli.check(err)
+	if proposal != nil {
+		cli.printProposal(*proposal)
+		return
+	}
+
+	cli.print(rotation, func() {
+		fmt.Printf("Rotated key of %s to %s\n", rotation.From, rotation.To)
+	})
+}
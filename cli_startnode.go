@@ -2,7 +2,7 @@ package main
 
 import "fmt"
 
-func (cli *CLI) startNode(nodeID string) {
+func (cli *CLI) startNode(nodeID, apiAddress string) {
 	fmt.Printf("Starting node %s\n", nodeID)
-	StartServer(nodeID)
+	StartServer(nodeID, apiAddress)
 }
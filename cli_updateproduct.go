@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (cli *CLI) updateProduct(address string, code int, name string, attributes *ProductAttributesView, nodeID string) {
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var update CatalogUpdateView
+	err := client.Post("/control/products/update", updateProductRequest{address, code, name, attributes}, &update)
+	cli.check(err)
+
+	cli.print(update, func() {
+		fmt.Printf("Updated product %d to version %d\n", update.Code, update.Version)
+	})
+}
+
+func (cli *CLI) discontinueProduct(address string, code int, reason string, nodeID string) {
+	if !ValidateAddress(address) {
+		cli.exit(exitUsage, "ERROR: Address is not valid")
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var update CatalogUpdateView
+	err := client.Post("/control/products/discontinue", discontinueProductRequest{address, code, reason}, &update)
+	cli.check(err)
+
+	cli.print(update, func() {
+		fmt.Printf("Discontinued product %d at version %d\n", update.Code, update.Version)
+	})
+}
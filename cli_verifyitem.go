@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+)
+
+func (cli *CLI) verifyItem(item string, nodeID string) {
+	// a link does not fit in the path of the request
+	if isDigitalLink(item) {
+		element, err := DigitalLinkElementString(item)
+		if err != nil {
+			cli.exit(exitUsage, "ERROR: "+err.Error())
+		}
+		item = element
+	}
+
+	client := NewNodeClient(nodeID)
+	defer client.Close()
+
+	var verification ItemVerificationView
+	err := client.Get("/verify/"+url.PathEscape(item), &verification)
+	cli.check(err)
+
+	cli.print(verification, func() {
+		if verification.Genuine {
+			fmt.Printf("%s is GENUINE\n", verification.Item)
+		} else {
+			fmt.Printf("%s is NOT GENUINE\n", verification.Item)
+		}
+
+		for _, check := range verification.Checks {
+			result := "ok  "
+			if !check.Passed {
+				result = "FAIL"
+			}
+			fmt.Printf("  [%s] %s: %s\n", result, check.Name, check.Detail)
+		}
+
+		if verification.Owner != nil {
+			fmt.Printf("Current owner: %s\n", verification.Owner)
+		}
+		fmt.Printf("Conflicting transactions: %d\n", verification.Conflicts)
+	})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+)
+
+// NodeClient sends the requests of CLI commands to the control socket of the
+// running node. When no node is running, it serves them from the database.
+type NodeClient struct {
+	http *http.Client
+	bc   *Blockchain
+}
+
+// handlerTransport serves requests in process instead of sending them
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// responseBuffer is the http.ResponseWriter of requests served in process
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header returns the header of the response
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+// Write appends data to the body of the response
+func (b *responseBuffer) Write(data []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+
+	return b.body.Write(data)
+}
+
+// WriteHeader sets the status of the response once
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// RoundTrip passes the request to the handler and returns its response
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	buffer := &responseBuffer{header: make(http.Header)}
+	t.handler.ServeHTTP(buffer, r)
+
+	if buffer.status == 0 {
+		buffer.status = http.StatusOK
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", buffer.status, http.StatusText(buffer.status)),
+		StatusCode:    buffer.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        buffer.header,
+		Body:          ioutil.NopCloser(&buffer.body),
+		ContentLength: int64(buffer.body.Len()),
+		Request:       r,
+	}, nil
+}
+
+// NewNodeClient connects to the node of nodeID, or opens its database
+func NewNodeClient(nodeID string) *NodeClient {
+	socket := fmt.Sprintf(controlSocket, nodeID)
+
+	conn, err := net.Dial("unix", socket)
+	if err == nil {
+		conn.Close()
+
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+
+		return &NodeClient{&http.Client{Transport: transport}, nil}
+	}
+
+	bc := NewBlockchain(nodeID)
+	transport := handlerTransport{newControlHandler(bc, nodeID)}
+
+	return &NodeClient{&http.Client{Transport: transport}, bc}
+}
+
+// Close releases the database when it was opened by the client
+func (c *NodeClient) Close() {
+	if c.bc != nil {
+		c.bc.db.Close()
+	}
+}
+
+// Get decodes the response of a GET request into out
+func (c *NodeClient) Get(path string, out interface{}) error {
+	return c.Do(http.MethodGet, path, nil, out)
+}
+
+// Post sends body as JSON and decodes the response into out
+func (c *NodeClient) Post(path string, body, out interface{}) error {
+	return c.Do(http.MethodPost, path, body, out)
+}
+
+// Propose sends a registry change as JSON and decodes the response into out
+// when the node records it. When admins have yet to approve the change, it
+// returns the proposal holding it instead.
+func (c *NodeClient) Propose(path string, body, out interface{}) (*ProposalView, error) {
+	var raw json.RawMessage
+
+	status, err := c.send(http.MethodPost, path, body, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == http.StatusAccepted {
+		var proposal ProposalView
+		return &proposal, json.Unmarshal(raw, &proposal)
+	}
+
+	return nil, json.Unmarshal(raw, out)
+}
+
+// Do sends a request to the node, returning the error message of a failed request
+func (c *NodeClient) Do(method, path string, body, out interface{}) error {
+	_, err := c.send(method, path, body, out)
+	return err
+}
+
+// send sends a request to the node and returns the status of its response
+func (c *NodeClient) send(method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			log.Panic(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, "http://node"+path, reader)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var apiErr apiError
+
+		err := json.NewDecoder(resp.Body).Decode(&apiErr)
+		if err != nil || apiErr.Error == "" {
+			return resp.StatusCode, fmt.Errorf("Node answered %s", resp.Status)
+		}
+
+		return resp.StatusCode, errors.New(apiErr.Error)
+	}
+
+	if out == nil {
+		return resp.StatusCode, nil
+	}
+
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
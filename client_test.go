@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHandlerTransport(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIResponse(w, http.StatusOK, map[string]string{"path": r.URL.Path})
+	})
+	handler.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusConflict, "Item was already claimed")
+	})
+	client := &NodeClient{&http.Client{Transport: handlerTransport{handler}}, nil}
+
+	var out map[string]string
+	if err := client.Get("/ok", &out); err != nil || out["path"] != "/ok" {
+		t.Errorf("GET /ok returned %v and %v", out, err)
+	}
+
+	status, err := client.send(http.MethodGet, "/fail", nil, nil)
+	if status != http.StatusConflict || err == nil || err.Error() != "Item was already claimed" {
+		t.Errorf("GET /fail returned status %d and error %v", status, err)
+	}
+
+	status, err = client.send(http.MethodGet, "/missing", nil, nil)
+	if status != http.StatusNotFound || err == nil {
+		t.Errorf("GET /missing returned status %d and error %v", status, err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+)
+
+const configBucket = "config"
+
+// Consensus engines selectable when creating the blockchain
+const powConsensus = "pow"
+const poaConsensus = "poa"
+
+// ConsensusEngine seals new blocks and checks the seal of received ones
+type ConsensusEngine interface {
+	// Seal sets the hash of a new block and proves the right to append it
+	Seal(block *Block) error
+	// Verify checks the hash and seal of a block, returning a *BlockRejection
+	Verify(block *Block) error
+	// Work returns the weight a block adds to its branch
+	Work(block *Block) *big.Int
+	// CanSeal reports whether this node is able to seal blocks
+	CanSeal() bool
+}
+
+// newConsensusEngine returns the engine stored in the blockchain config
+func newConsensusEngine(name string, bc *Blockchain, nodeID string) (ConsensusEngine, error) {
+	switch name {
+	case powConsensus:
+		return PoWEngine{}, nil
+	case poaConsensus:
+		return &PoAEngine{bc, nodeID, make(map[string][][]byte)}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown consensus engine %q, expected %s or %s", name, powConsensus, poaConsensus)
+}
+
+// verifySeal checks the seal of a stored block. The genesis block and the
+// blocks converted from the three-chain format are always mined.
+func (bc *Blockchain) verifySeal(block *Block) error {
+	if len(block.PrevBlockHash) == 0 || bc.IsMigratedBlock(block) {
+		return PoWEngine{}.Verify(block)
+	}
+
+	return bc.consensus.Verify(block)
+}
+
+// PoWEngine seals blocks with a proof of work
+type PoWEngine struct{}
+
+// Seal mines the block
+func (PoWEngine) Seal(block *Block) error {
+	pow := NewProofOfWork(block)
+	nonce, hash := pow.Run()
+
+	block.Hash = hash[:]
+	block.Nonce = nonce
+
+	return nil
+}
+
+// Verify checks the block hash and that it is under the target
+func (PoWEngine) Verify(block *Block) error {
+	pow := NewProofOfWork(block)
+	if bytes.Compare(pow.Hash(), block.Hash) != 0 {
+		return rejectBlock(RejectInvalidHash, "expected %x", pow.Hash())
+	}
+	if !pow.Validate() {
+		return rejectBlock(RejectInvalidProofOfWork, "hash %x is above the target", block.Hash)
+	}
+
+	return nil
+}
+
+// Work returns the expected number of hashes needed to mine the block
+func (PoWEngine) Work(block *Block) *big.Int {
+	return NewProofOfWork(block).Work()
+}
+
+// CanSeal always holds since anyone can mine
+func (PoWEngine) CanSeal() bool {
+	return true
+}
@@ -0,0 +1,1020 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+)
+
+const controlSocket = "node_%s.sock"
+
+// sendRequest asks the node to transfer items from one of its wallets
+type sendRequest struct {
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	Items []string `json:"items"`
+	Mine  bool     `json:"mine"`
+}
+
+// saleRequest asks the node to sell items held by one of its wallets to
+// consumers, who claim them with the claim codes of the response
+type saleRequest struct {
+	From  string   `json:"from"`
+	Items []string `json:"items"`
+	Mine  bool     `json:"mine"`
+}
+
+// claimRequest asks the node to claim an item sold to consumers for one of
+// its wallets
+type claimRequest struct {
+	Address string `json:"address"`
+	Item    string `json:"item"`
+	Code    string `json:"code"`
+}
+
+// decommissionRequest asks the node to take items held by one of its wallets
+// out of circulation for a reason code
+type decommissionRequest struct {
+	From   string   `json:"from"`
+	Items  []string `json:"items"`
+	Reason string   `json:"reason"`
+	Mine   bool     `json:"mine"`
+}
+
+// offerRequest asks the node to offer items held by one of its wallets to
+// another address, until height Expiry if set
+type offerRequest struct {
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Items  []string `json:"items"`
+	Expiry int      `json:"expiry"`
+	Mine   bool     `json:"mine"`
+}
+
+// offerAnswerRequest asks the node to accept, reject or reclaim offered items
+// for one of its wallets
+type offerAnswerRequest struct {
+	Address string   `json:"address"`
+	Items   []string `json:"items"`
+	Mine    bool     `json:"mine"`
+}
+
+// produceRequest asks the node to mint items of the given product codes, with
+// an optional lot number and manufacturing and expiry dates
+type produceRequest struct {
+	Address      string   `json:"address"`
+	Codes        []string `json:"codes"`
+	Lot          string   `json:"lot,omitempty"`
+	Manufactured string   `json:"manufactured,omitempty"`
+	Expiry       string   `json:"expiry,omitempty"`
+}
+
+// packRequest asks the node to pack items into a new logistic unit
+type packRequest struct {
+	Address string   `json:"address"`
+	Items   []string `json:"items"`
+}
+
+// unpackRequest asks the node to unpack a logistic unit
+type unpackRequest struct {
+	Address string `json:"address"`
+	Unit    string `json:"unit"`
+}
+
+// addProductsRequest asks the node to register products in a catalog, all
+// with the same optional attributes
+type addProductsRequest struct {
+	Address    string                 `json:"address"`
+	Names      []string               `json:"names"`
+	Attributes *ProductAttributesView `json:"attributes,omitempty"`
+}
+
+// updateProductRequest asks the node to record a new version of a product of
+// a catalog. An empty name or attribute keeps its current value.
+type updateProductRequest struct {
+	Address    string                 `json:"address"`
+	Code       int                    `json:"code"`
+	Name       string                 `json:"name"`
+	Attributes *ProductAttributesView `json:"attributes,omitempty"`
+}
+
+// discontinueProductRequest asks the node to discontinue a product of a catalog
+type discontinueProductRequest struct {
+	Address string `json:"address"`
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+}
+
+// recallRequest asks the node to publish a recall signed by a manufacturer
+type recallRequest struct {
+	Address    string   `json:"address"`
+	Code       int      `json:"code"`
+	Items      []string `json:"items"`
+	FromSerial int      `json:"from_serial"`
+	ToSerial   int      `json:"to_serial"`
+	Reason     string   `json:"reason"`
+}
+
+// createOrgRequest asks the node to register an organisation signed by an admin
+type createOrgRequest struct {
+	Admin  string `json:"admin"`
+	Name   string `json:"name"`
+	PubKey string `json:"pubkey"`
+	GSTIN  string `json:"gstin"`
+	Prefix string `json:"prefix"`
+	Role   string `json:"role"`
+}
+
+// statusChangeRequest asks the node to suspend, reinstate or revoke an
+// organisation with a change signed by an admin, effective at Height or at
+// the next block if it is 0
+type statusChangeRequest struct {
+	Admin        string `json:"admin"`
+	Organisation string `json:"organisation"`
+	Action       string `json:"action"`
+	Reason       string `json:"reason"`
+	Height       int    `json:"height"`
+}
+
+// keyRotationRequest asks the node to rotate the key of an organisation to
+// the key of NewAddress, signed by Signer, the current key of the
+// organisation or an admin, and by NewAddress. Both keys must be in its wallet.
+type keyRotationRequest struct {
+	Signer       string `json:"signer"`
+	Organisation string `json:"organisation"`
+	NewAddress   string `json:"new_address"`
+}
+
+// roleChangeRequest asks the node to give an organisation a new role with a
+// change signed by an admin
+type roleChangeRequest struct {
+	Admin        string `json:"admin"`
+	Organisation string `json:"organisation"`
+	Role         string `json:"role"`
+}
+
+// approveRequest asks the node to approve a pending registry change with the
+// key of an admin
+type approveRequest struct {
+	Admin    string `json:"admin"`
+	Proposal string `json:"proposal"`
+}
+
+// epcisImportRequest asks the node to record the events of an EPCIS document
+// with one of its wallets
+type epcisImportRequest struct {
+	Address  string        `json:"address"`
+	Document EPCISDocument `json:"document"`
+}
+
+// StartControl serves the control API of the node on a unix socket, so that
+// CLI commands can run while the node holds the database
+func StartControl(nodeID string, bc *Blockchain) {
+	socket := fmt.Sprintf(controlSocket, nodeID)
+
+	// a socket left by a node that did not shut down cleanly
+	os.Remove(socket)
+
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = os.Chmod(socket, 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = http.Serve(ln, newControlHandler(bc, nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// newControlHandler routes the HTTP API plus the endpoints that use the
+// wallets of the node:
+//
+//	POST /control/send                   transfer items
+//	POST /control/produce                mint items
+//	POST /control/pack                   pack items into a logistic unit
+//	POST /control/unpack                 unpack a logistic unit
+//	POST /control/products               register products
+//	POST /control/products/update        record a new version of a product
+//	POST /control/products/discontinue   discontinue a product
+//	POST /control/organisations          register an organisation
+//	POST /control/recalls                publish a recall
+//	POST /control/organisations/status   suspend, reinstate or revoke an organisation
+//	POST /control/organisations/keys     rotate the key of an organisation
+//	POST /control/organisations/role     change the role of an organisation
+//	POST /control/proposals/approve      approve a registry change
+//	POST /control/epcis                  record the events of an EPCIS document
+//	POST /control/reindex                rebuild the UTXO set
+//
+// Registry changes signed by an admin are answered with 202 Accepted and the
+// proposal holding them when other admins have to approve them first.
+func newControlHandler(bc *Blockchain, nodeID string) http.Handler {
+	mux := newAPIMux(bc)
+
+	mux.HandleFunc("/control/send", controlPost(bc, nodeID, handleControlSend))
+	mux.HandleFunc("/control/sell", controlPost(bc, nodeID, handleControlSell))
+	mux.HandleFunc("/control/claim", controlPost(bc, nodeID, handleControlClaim))
+	mux.HandleFunc("/control/decommission", controlPost(bc, nodeID, handleControlDecommission))
+	mux.HandleFunc("/control/offers", controlPost(bc, nodeID, handleControlOffer))
+	mux.HandleFunc("/control/offers/accept", controlPost(bc, nodeID, handleControlAnswerOffers(acceptAction)))
+	mux.HandleFunc("/control/offers/reject", controlPost(bc, nodeID, handleControlAnswerOffers(rejectAction)))
+	mux.HandleFunc("/control/offers/reclaim", controlPost(bc, nodeID, handleControlAnswerOffers(reclaimAction)))
+	mux.HandleFunc("/control/produce", controlPost(bc, nodeID, handleControlProduce))
+	mux.HandleFunc("/control/pack", controlPost(bc, nodeID, handleControlPack))
+	mux.HandleFunc("/control/unpack", controlPost(bc, nodeID, handleControlUnpack))
+	mux.HandleFunc("/control/products", controlPost(bc, nodeID, handleControlAddProducts))
+	mux.HandleFunc("/control/products/update", controlPost(bc, nodeID, handleControlUpdateProduct))
+	mux.HandleFunc("/control/products/discontinue", controlPost(bc, nodeID, handleControlDiscontinueProduct))
+	mux.HandleFunc("/control/organisations", controlPost(bc, nodeID, handleControlCreateOrg))
+	mux.HandleFunc("/control/recalls", controlPost(bc, nodeID, handleControlRecall))
+	mux.HandleFunc("/control/organisations/status", controlPost(bc, nodeID, handleControlStatusChange))
+	mux.HandleFunc("/control/organisations/keys", controlPost(bc, nodeID, handleControlRotateKey))
+	mux.HandleFunc("/control/organisations/role", controlPost(bc, nodeID, handleControlChangeRole))
+	mux.HandleFunc("/control/proposals/approve", controlPost(bc, nodeID, handleControlApprove))
+	mux.HandleFunc("/control/epcis", controlPost(bc, nodeID, handleControlImportEPCIS))
+	mux.HandleFunc("/control/reindex", controlPost(bc, nodeID, handleControlReindex))
+
+	return mux
+}
+
+// controlPost wraps a handler that changes the blockchain
+func controlPost(bc *Blockchain, nodeID string, handler func(http.ResponseWriter, *http.Request, *Blockchain, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeAPIError(w, http.StatusMethodNotAllowed, "Use POST")
+			return
+		}
+
+		nodeLock.Lock()
+		defer nodeLock.Unlock()
+		defer recoverAPIError(w)
+
+		handler(w, r, bc, nodeID)
+	}
+}
+
+// decodeControlRequest reads the JSON body of a request, answering with an
+// error if it cannot
+func decodeControlRequest(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Body is not a valid JSON request")
+		return false
+	}
+
+	return true
+}
+
+// controlWallet returns the wallet of the node holding address
+func controlWallet(w http.ResponseWriter, address, nodeID string) *Wallet {
+	if !ValidateAddress(address) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return nil
+	}
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("Address %s is not in the wallet of this node", address))
+		return nil
+	}
+
+	return wallet
+}
+
+// resolveControlItems returns the SGTIN or SSCC of items given in any form
+// accepted by ResolveItem, answering with an error if one is not
+func resolveControlItems(w http.ResponseWriter, bc *Blockchain, items []string) ([]string, bool) {
+	resolved, err := bc.ResolveItems(items)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	return resolved, true
+}
+
+// checkCirculating writes an error and returns false if any of items was
+// decommissioned
+func checkCirculating(w http.ResponseWriter, bc *Blockchain, items []string) bool {
+	for _, item := range items {
+		if out, ok := bc.FindDecommission(item); ok {
+			writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Item %s was decommissioned as %s", item, out.Decommission))
+			return false
+		}
+	}
+
+	return true
+}
+
+// announceBlock sends the hash of a block mined from the control API to the
+// other nodes. Nothing is sent when the node is not running.
+func announceBlock(block *Block) {
+	if nodeAddress == "" {
+		return
+	}
+
+	for _, node := range knownNodes {
+		if node != nodeAddress {
+			sendInv(node, "block", [][]byte{block.Hash})
+		}
+	}
+}
+
+// announceProposal sends a proposal to the other nodes, so that their admins
+// can approve it. Nothing is sent when the node is not running.
+func announceProposal(proposal *Proposal) {
+	if nodeAddress == "" {
+		return
+	}
+
+	for _, node := range knownNodes {
+		if node != nodeAddress {
+			sendProposal(node, proposal)
+		}
+	}
+}
+
+// mineRegistryChange mines a registry change, or keeps it as a proposal and
+// answers with it when other admins have to approve it first. It reports
+// whether the change was mined.
+func mineRegistryChange(w http.ResponseWriter, bc *Blockchain, entry *Entry) bool {
+	required := bc.RequiredApprovals()
+
+	if entry.Proposer() == nil || required <= 1 {
+		newBlock := bc.MineBlock([]*Entry{entry})
+		announceBlock(newBlock)
+		return true
+	}
+
+	proposal := NewProposal(entry)
+	bc.SaveProposal(proposal)
+	announceProposal(proposal)
+
+	writeAPIResponse(w, http.StatusAccepted, NewProposalView(proposal, required))
+	return false
+}
+
+// mineProposal mines a registry change with the approvals collected for it
+func mineProposal(bc *Blockchain, proposal *Proposal) error {
+	err := UTXOSet{bc}.ValidateEntries(proposal.Entries())
+	if err != nil {
+		return err
+	}
+
+	newBlock := bc.MineBlock(proposal.Entries())
+	bc.DeleteProposal(proposal.ID())
+	announceBlock(newBlock)
+
+	return nil
+}
+
+func handleControlSend(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request sendRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	wallet := controlWallet(w, request.From, nodeID)
+	if wallet == nil {
+		return
+	}
+	if !ValidateAddress(request.To) {
+		writeAPIError(w, http.StatusBadRequest, "Recipient address is not valid")
+		return
+	}
+	items, ok := resolveControlItems(w, bc, request.Items)
+	if !ok || !checkCirculating(w, bc, items) {
+		return
+	}
+
+	UTXOSet := UTXOSet{bc}
+	tx := NewUTXOTransaction(*wallet, request.From, request.To, items, &UTXOSet)
+
+	err := bc.ValidateTransfer(tx)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if request.Mine {
+		newBlock := bc.MineBlock(NewTransactionEntries([]*Transaction{tx}))
+		announceBlock(newBlock)
+	} else {
+		sendTx(knownNodes[0], tx)
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewTransactionView(tx))
+}
+
+func handleControlSell(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request saleRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	wallet := controlWallet(w, request.From, nodeID)
+	if wallet == nil {
+		return
+	}
+	items, ok := resolveControlItems(w, bc, request.Items)
+	if !ok || !checkCirculating(w, bc, items) {
+		return
+	}
+
+	UTXOSet := UTXOSet{bc}
+	tx, codes, err := NewSaleTransaction(*wallet, request.From, items, &UTXOSet)
+	if err == nil {
+		err = UTXOSet.ValidateTransaction(tx)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if request.Mine {
+		newBlock := bc.MineBlock(NewTransactionEntries([]*Transaction{tx}))
+		announceBlock(newBlock)
+	} else {
+		sendTx(knownNodes[0], tx)
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewSaleView(tx, codes))
+}
+
+func handleControlClaim(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request claimRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	wallet := controlWallet(w, request.Address, nodeID)
+	if wallet == nil {
+		return
+	}
+	items, ok := resolveControlItems(w, bc, []string{request.Item})
+	if !ok {
+		return
+	}
+
+	UTXOSet := UTXOSet{bc}
+	tx, err := NewClaimTransaction(*wallet, request.Address, items[0], request.Code, &UTXOSet)
+	if err != nil {
+		if _, _, findErr := bc.FindClaim(items[0]); findErr == nil {
+			recordClaimAttempt(w, bc, request.Address, items[0], request.Code, nodeID)
+			return
+		}
+	}
+	if err == nil {
+		err = UTXOSet.ValidateTransaction(tx)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	// a node that cannot seal leaves the claim to the nodes it sends it to
+	if bc.consensus.CanSeal() {
+		newBlock := bc.MineBlock(NewTransactionEntries([]*Transaction{tx}))
+		announceBlock(newBlock)
+	} else {
+		sendTx(knownNodes[0], tx)
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewTransactionView(tx))
+}
+
+// recordClaimAttempt mines the attempt to claim an item that was already
+// claimed and answers that the claim is rejected
+func recordClaimAttempt(w http.ResponseWriter, bc *Blockchain, address, item, code, nodeID string) {
+	attempt, err := NewClaimAttempt(address, item, code, bc, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if !bc.consensus.CanSeal() {
+		writeAPIError(w, http.StatusConflict, fmt.Sprintf("Item %s was already claimed; this node cannot seal blocks to record the attempt", item))
+		return
+	}
+
+	newBlock := bc.MineBlock([]*Entry{NewClaimAttemptEntry(attempt)})
+	announceBlock(newBlock)
+
+	given := "a wrong claim code"
+	if _, sale, err := bc.FindClaim(item); err == nil && attempt.IsCopy(sale) {
+		given = "a copy of its claim code"
+	}
+
+	writeAPIError(w, http.StatusConflict, fmt.Sprintf("Item %s was already claimed; this attempt with %s is recorded in its history as a sign of counterfeiting", item, given))
+}
+
+func handleControlDecommission(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request decommissionRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	wallet := controlWallet(w, request.From, nodeID)
+	if wallet == nil {
+		return
+	}
+	items, ok := resolveControlItems(w, bc, request.Items)
+	if !ok || !checkCirculating(w, bc, items) {
+		return
+	}
+
+	UTXOSet := UTXOSet{bc}
+	tx, err := NewDecommissionTransaction(*wallet, request.From, items, request.Reason, &UTXOSet)
+	if err == nil {
+		err = UTXOSet.ValidateTransaction(tx)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if request.Mine {
+		newBlock := bc.MineBlock(NewTransactionEntries([]*Transaction{tx}))
+		announceBlock(newBlock)
+	} else {
+		sendTx(knownNodes[0], tx)
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewTransactionView(tx))
+}
+
+func handleControlOffer(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request offerRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	wallet := controlWallet(w, request.From, nodeID)
+	if wallet == nil {
+		return
+	}
+	if !ValidateAddress(request.To) {
+		writeAPIError(w, http.StatusBadRequest, "Recipient address is not valid")
+		return
+	}
+	items, ok := resolveControlItems(w, bc, request.Items)
+	if !ok || !checkCirculating(w, bc, items) {
+		return
+	}
+
+	UTXOSet := UTXOSet{bc}
+	tx, err := NewOfferTransaction(*wallet, request.From, request.To, items, request.Expiry, &UTXOSet)
+	if err == nil {
+		err = UTXOSet.ValidateTransaction(tx)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if request.Mine {
+		newBlock := bc.MineBlock(NewTransactionEntries([]*Transaction{tx}))
+		announceBlock(newBlock)
+	} else {
+		sendTx(knownNodes[0], tx)
+	}
+
+	writeAPIResponse(w, http.StatusOK, NewTransactionView(tx))
+}
+
+func handleControlAnswerOffers(action string) func(http.ResponseWriter, *http.Request, *Blockchain, string) {
+	return func(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+		var request offerAnswerRequest
+		if !decodeControlRequest(w, r, &request) {
+			return
+		}
+
+		wallet := controlWallet(w, request.Address, nodeID)
+		if wallet == nil {
+			return
+		}
+		items, ok := resolveControlItems(w, bc, request.Items)
+		if !ok {
+			return
+		}
+
+		UTXOSet := UTXOSet{bc}
+		tx, err := NewOfferAnswerTransaction(*wallet, request.Address, items, action, &UTXOSet)
+		if err == nil {
+			err = UTXOSet.ValidateTransaction(tx)
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		if request.Mine {
+			newBlock := bc.MineBlock(NewTransactionEntries([]*Transaction{tx}))
+			announceBlock(newBlock)
+		} else {
+			sendTx(knownNodes[0], tx)
+		}
+
+		writeAPIResponse(w, http.StatusOK, NewTransactionView(tx))
+	}
+}
+
+func handleControlProduce(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request produceRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Address) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	batch, err := NewBatch(request.Lot, request.Manufactured, request.Expiry)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	UTXOSet := UTXOSet{bc}
+	cbTx, err := NewCoinbaseTX(&UTXOSet, request.Address, request.Codes, batch, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	newBlock := bc.MineBlock(NewTransactionEntries([]*Transaction{cbTx}))
+	announceBlock(newBlock)
+
+	writeAPIResponse(w, http.StatusOK, NewTransactionView(cbTx))
+}
+
+func handleControlPack(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request packRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	wallet := controlWallet(w, request.Address, nodeID)
+	if wallet == nil {
+		return
+	}
+
+	items, ok := resolveControlItems(w, bc, request.Items)
+	if !ok {
+		return
+	}
+
+	UTXOSet := UTXOSet{bc}
+	tx, err := NewPackTransaction(*wallet, request.Address, items, &UTXOSet)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	minePacking(w, bc, tx)
+}
+
+func handleControlUnpack(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request unpackRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	wallet := controlWallet(w, request.Address, nodeID)
+	if wallet == nil {
+		return
+	}
+
+	units, ok := resolveControlItems(w, bc, []string{request.Unit})
+	if !ok {
+		return
+	}
+
+	UTXOSet := UTXOSet{bc}
+	tx, err := NewUnpackTransaction(*wallet, request.Address, units[0], &UTXOSet)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	minePacking(w, bc, tx)
+}
+
+// minePacking mines a pack or unpack transaction once it is valid
+func minePacking(w http.ResponseWriter, bc *Blockchain, tx *Transaction) {
+	UTXOSet := UTXOSet{bc}
+
+	err := UTXOSet.ValidateTransaction(tx)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	newBlock := bc.MineBlock(NewTransactionEntries([]*Transaction{tx}))
+	announceBlock(newBlock)
+
+	writeAPIResponse(w, http.StatusOK, NewTransactionView(tx))
+}
+
+func handleControlAddProducts(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request addProductsRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Address) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	attributes, err := request.Attributes.Attributes()
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ProductCache := ProductCacheSet{bc}
+	productArr, err := NewProducts(request.Address, request.Names, attributes, &ProductCache, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	newBlock := bc.MineBlock(NewProductEntries(productArr))
+	announceBlock(newBlock)
+
+	products := ProductViews{}
+	for _, p := range productArr {
+		products = append(products, NewProductView(p))
+	}
+
+	writeAPIResponse(w, http.StatusOK, products)
+}
+
+func handleControlUpdateProduct(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request updateProductRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Address) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	attributes, err := request.Attributes.Attributes()
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	update, err := NewCatalogUpdate(request.Address, request.Code, updateAction, request.Name, attributes, "", bc, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	newBlock := bc.MineBlock([]*Entry{NewCatalogEntry(update)})
+	announceBlock(newBlock)
+
+	writeAPIResponse(w, http.StatusOK, NewCatalogUpdateView(update))
+}
+
+func handleControlDiscontinueProduct(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request discontinueProductRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Address) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	update, err := NewCatalogUpdate(request.Address, request.Code, discontinueAction, "", nil, request.Reason, bc, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	newBlock := bc.MineBlock([]*Entry{NewCatalogEntry(update)})
+	announceBlock(newBlock)
+
+	writeAPIResponse(w, http.StatusOK, NewCatalogUpdateView(update))
+}
+
+func handleControlCreateOrg(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request createOrgRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Admin) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	OrganisationCache := OrganisationCacheSet{bc}
+	org, err := NewOrganisation(request.Admin, request.Name, request.PubKey, request.GSTIN, request.Prefix, request.Role, &OrganisationCache, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if mineRegistryChange(w, bc, NewOrganisationEntry(org)) {
+		writeAPIResponse(w, http.StatusOK, NewOrganisationView(org))
+	}
+}
+
+func handleControlRecall(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request recallRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Address) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+	items, ok := resolveControlItems(w, bc, request.Items)
+	if !ok {
+		return
+	}
+
+	recall, err := NewRecall(request.Address, request.Code, items, request.FromSerial, request.ToSerial, request.Reason, bc, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	newBlock := bc.MineBlock([]*Entry{NewRecallEntry(recall)})
+	announceBlock(newBlock)
+
+	writeAPIResponse(w, http.StatusOK, NewRecallView(recall))
+}
+
+func handleControlStatusChange(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request statusChangeRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Admin) || !ValidateAddress(request.Organisation) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	change, err := NewStatusChange(request.Admin, request.Organisation, request.Action, request.Reason, request.Height, bc, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if mineRegistryChange(w, bc, NewStatusEntry(change)) {
+		writeAPIResponse(w, http.StatusOK, NewStatusChangeView(change))
+	}
+}
+
+func handleControlRotateKey(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request keyRotationRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Signer) || !ValidateAddress(request.Organisation) || !ValidateAddress(request.NewAddress) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	rotation, err := NewKeyRotation(request.Signer, request.Organisation, request.NewAddress, bc, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if mineRegistryChange(w, bc, NewKeyEntry(rotation)) {
+		writeAPIResponse(w, http.StatusOK, NewKeyRotationView(rotation))
+	}
+}
+
+func handleControlChangeRole(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request roleChangeRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Admin) || !ValidateAddress(request.Organisation) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	change, err := NewRoleChange(request.Admin, request.Organisation, request.Role, bc, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if mineRegistryChange(w, bc, NewRoleEntry(change)) {
+		writeAPIResponse(w, http.StatusOK, NewRoleChangeView(change))
+	}
+}
+
+func handleControlApprove(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request approveRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+	if !ValidateAddress(request.Admin) {
+		writeAPIError(w, http.StatusBadRequest, "Address is not valid")
+		return
+	}
+
+	id, err := hex.DecodeString(request.Proposal)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Proposal is not a hex ID")
+		return
+	}
+	proposal := bc.FindProposal(id)
+	if proposal == nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Proposal %s not found", request.Proposal))
+		return
+	}
+
+	approval, err := NewApproval(request.Admin, proposal, bc, nodeID)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	proposal.Approvals = append(proposal.Approvals, approval)
+
+	required := bc.RequiredApprovals()
+	view := NewProposalView(proposal, required)
+
+	// a node that cannot seal leaves the change to the nodes it sends the
+	// proposal to
+	if len(proposal.Approvers()) >= required && bc.consensus.CanSeal() {
+		err = mineProposal(bc, proposal)
+		if err != nil {
+			writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		view.Recorded = true
+	} else {
+		bc.SaveProposal(proposal)
+		announceProposal(proposal)
+	}
+
+	writeAPIResponse(w, http.StatusOK, view)
+}
+
+func handleControlImportEPCIS(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	var request epcisImportRequest
+	if !decodeControlRequest(w, r, &request) {
+		return
+	}
+
+	wallet := controlWallet(w, request.Address, nodeID)
+	if wallet == nil {
+		return
+	}
+
+	err := bc.ValidateEPCIS(request.Document)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// every event is mined in its own block, as later events may spend the
+	// items of earlier ones
+	UTXOSet := UTXOSet{bc}
+	txs := TransactionViews{}
+
+	for i, event := range request.Document.Body.EventList {
+		tx, err := NewEPCISTransaction(*wallet, request.Address, event, &UTXOSet)
+		if err == nil {
+			err = UTXOSet.ValidateTransaction(tx)
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Event %d: %s (%d events recorded)", i+1, err, len(txs)))
+			return
+		}
+
+		newBlock := bc.MineBlock(NewTransactionEntries([]*Transaction{tx}))
+		announceBlock(newBlock)
+
+		txs = append(txs, NewTransactionView(tx))
+	}
+
+	writeAPIResponse(w, http.StatusOK, txs)
+}
+
+func handleControlReindex(w http.ResponseWriter, r *http.Request, bc *Blockchain, nodeID string) {
+	UTXOSet := UTXOSet{bc}
+	UTXOSet.Reindex()
+
+	writeAPIResponse(w, http.StatusOK, UTXOSetView{UTXOSet.CountTransactions()})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reason codes of a decommissioning
+const soldToConsumerReason = "sold-to-consumer"
+const destroyedReason = "destroyed"
+const expiredReason = "expired"
+const stolenReason = "stolen"
+const lostReason = "lost"
+const sampleReason = "sample"
+
+// decommissionReasons are the reason codes an item can be taken out of
+// circulation with
+var decommissionReasons = []string{soldToConsumerReason, destroyedReason, expiredReason, stolenReason, lostReason, sampleReason}
+
+// IsDecommissionReason checks whether reason is a reason code of a
+// decommissioning
+func IsDecommissionReason(reason string) bool {
+	for _, r := range decommissionReasons {
+		if r == reason {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewDecommissionTransaction creates the transaction taking items held by
+// address out of circulation for reason. Its outputs record the items with
+// the items packed in them and cannot be spent.
+func NewDecommissionTransaction(wallet Wallet, address string, items []string, reason string, UTXOSet *UTXOSet) (*Transaction, error) {
+	if !IsDecommissionReason(reason) {
+		return nil, fmt.Errorf("Reason %s is not one of %s", reason, strings.Join(decommissionReasons, ", "))
+	}
+
+	inputs, spent, err := UTXOSet.findOutputsOf(wallet, items)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := Transaction{nil, inputs, nil}
+	for i, out := range spent {
+		output := NewTXOutput(i, out.Item, address)
+		output.Contents = out.Contents
+		output.Decommission = []byte(reason)
+		tx.Vout = append(tx.Vout, *output)
+	}
+
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+
+	return &tx, nil
+}
+
+// IsDecommission checks whether the transaction takes items out of circulation
+func (tx *Transaction) IsDecommission() bool {
+	for _, out := range tx.Vout {
+		if !out.IsSpendable() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindDecommission returns the output that took item out of circulation, on
+// its own or packed in a logistic unit
+func (bc *Blockchain) FindDecommission(item string) (TXOutput, bool) {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions() {
+			for _, out := range tx.Vout {
+				if _, ok := out.Carries(item); ok && !out.IsSpendable() {
+					return out, true
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			return TXOutput{}, false
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDecommission(t *testing.T) {
+	// parties are the organisations registered on the ledger by role
+	type parties struct {
+		maker, dist, shop *Wallet
+	}
+
+	tests := []struct {
+		name string
+		// decommission returns the transaction to validate, given the parties
+		// where the manufacturer holds 0123.1.1 and 0123.1.2
+		decommission func(c *testChain, p parties) (*Transaction, error)
+		valid        bool
+	}{
+		{"destroyed", func(c *testChain, p parties) (*Transaction, error) {
+			return NewDecommissionTransaction(*p.maker, addressOf(p.maker), []string{"0123.1.1", "0123.1.2"}, destroyedReason, &UTXOSet{c.bc})
+		}, true},
+		{"unit with the items packed in it", func(c *testChain, p parties) (*Transaction, error) {
+			c.pack(p.maker, "0123.1.1", "0123.1.2")
+			return NewDecommissionTransaction(*p.maker, addressOf(p.maker), []string{"0123.1"}, expiredReason, &UTXOSet{c.bc})
+		}, true},
+		{"sold to consumer by a retailer", func(c *testChain, p parties) (*Transaction, error) {
+			c.mustMine(NewTransactionEntries([]*Transaction{c.send(p.maker, addressOf(p.dist), "0123.1.1")})...)
+			c.mustMine(NewTransactionEntries([]*Transaction{c.send(p.dist, addressOf(p.shop), "0123.1.1")})...)
+			return NewDecommissionTransaction(*p.shop, addressOf(p.shop), []string{"0123.1.1"}, soldToConsumerReason, &UTXOSet{c.bc})
+		}, true},
+		{"sold to consumer by a manufacturer", func(c *testChain, p parties) (*Transaction, error) {
+			return NewDecommissionTransaction(*p.maker, addressOf(p.maker), []string{"0123.1.1"}, soldToConsumerReason, &UTXOSet{c.bc})
+		}, false},
+		{"unknown reason", func(c *testChain, p parties) (*Transaction, error) {
+			tx, err := NewDecommissionTransaction(*p.maker, addressOf(p.maker), []string{"0123.1.1"}, destroyedReason, &UTXOSet{c.bc})
+			if err != nil {
+				return nil, err
+			}
+			tx.Vout[0].Decommission = []byte("melted")
+			c.resign(tx, p.maker)
+			return tx, nil
+		}, false},
+		{"sent to another address", func(c *testChain, p parties) (*Transaction, error) {
+			tx, err := NewDecommissionTransaction(*p.maker, addressOf(p.maker), []string{"0123.1.1"}, destroyedReason, &UTXOSet{c.bc})
+			if err != nil {
+				return nil, err
+			}
+			tx.Vout[0].PubKeyHash = HashPubKey(p.dist.PublicKey)
+			c.resign(tx, p.maker)
+			return tx, nil
+		}, false},
+		{"with an item left in circulation", func(c *testChain, p parties) (*Transaction, error) {
+			tx, err := NewDecommissionTransaction(*p.maker, addressOf(p.maker), []string{"0123.1.1", "0123.1.2"}, destroyedReason, &UTXOSet{c.bc})
+			if err != nil {
+				return nil, err
+			}
+			tx.Vout[1].Decommission = nil
+			c.resign(tx, p.maker)
+			return tx, nil
+		}, false},
+		{"already decommissioned", func(c *testChain, p parties) (*Transaction, error) {
+			tx, err := NewDecommissionTransaction(*p.maker, addressOf(p.maker), []string{"0123.1.1"}, lostReason, &UTXOSet{c.bc})
+			if err != nil {
+				return nil, err
+			}
+			c.mustMine(NewTransactionEntries([]*Transaction{tx})...)
+
+			output := NewTXOutput(0, "0123.1.1", addressOf(p.maker))
+			output.Decommission = []byte(destroyedReason)
+			again := &Transaction{nil, []TXInput{{tx.ID, 0, nil, p.maker.PublicKey, nil}}, []TXOutput{*output}}
+			c.resign(again, p.maker)
+			return again, nil
+		}, false},
+	}
+
+	policy, err := ParseTransferPolicy("Manufacturer>Distributor,Distributor>Retailer,Retailer>Consumer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestChain(t, powConsensus, policy)
+			p := parties{
+				c.register("Maker", "0123", "Manufacturer"),
+				c.register("Dist", "0124", "Distributor"),
+				c.register("Shop", "0125", "Retailer"),
+			}
+			c.addProducts(p.maker, "soap")
+			c.mint(p.maker, items("0123", 1, 2)...)
+
+			tx, err := test.decommission(c, p)
+			if err == nil {
+				err = c.mine(NewTransactionEntries([]*Transaction{tx})...)
+			}
+
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("decommissioning returned %v, expected valid %t", err, test.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Kinds of entries recorded on the ledger
+const organisationEntry = "organisation"
+const productEntry = "product"
+const transactionEntry = "transaction"
+const recallEntry = "recall"
+const policyEntry = "policy"
+const statusEntry = "status"
+const keyEntry = "key"
+const governanceEntry = "governance"
+const roleEntry = "role"
+const approvalEntry = "approval"
+const catalogEntry = "catalog"
+const claimAttemptEntry = "claim_attempt"
+const migrationEntry = "migration"
+
+// Entry is a typed record of a block. Only the payload matching Type is set.
+type Entry struct {
+	Type         string
+	Organisation *Organisation
+	Product      *Product
+	Transaction  *Transaction
+	Recall       *Recall
+	Policy       *TransferPolicy
+	Status       *StatusChange
+	Key          *KeyRotation
+	Governance   *Governance
+	Role         *RoleChange
+	Approval     *Approval
+	Catalog      *CatalogUpdate
+	ClaimAttempt *ClaimAttempt
+	Migration    *Migration
+}
+
+// NewOrganisationEntry records the registration of an organisation
+func NewOrganisationEntry(org *Organisation) *Entry {
+	return &Entry{Type: organisationEntry, Organisation: org}
+}
+
+// NewProductEntries records catalog entries
+func NewProductEntries(products []*Product) []*Entry {
+	var entries []*Entry
+
+	for _, p := range products {
+		entries = append(entries, &Entry{Type: productEntry, Product: p})
+	}
+
+	return entries
+}
+
+// NewTransactionEntries records item transfers
+func NewTransactionEntries(txs []*Transaction) []*Entry {
+	var entries []*Entry
+
+	for _, tx := range txs {
+		entries = append(entries, &Entry{Type: transactionEntry, Transaction: tx})
+	}
+
+	return entries
+}
+
+// NewRecallEntry records a recall
+func NewRecallEntry(recall *Recall) *Entry {
+	return &Entry{Type: recallEntry, Recall: recall}
+}
+
+// NewPolicyEntry records the transfer policy of a genesis block
+func NewPolicyEntry(policy *TransferPolicy) *Entry {
+	return &Entry{Type: policyEntry, Policy: policy}
+}
+
+// NewStatusEntry records a change of the status of an organisation
+func NewStatusEntry(change *StatusChange) *Entry {
+	return &Entry{Type: statusEntry, Status: change}
+}
+
+// NewKeyEntry records the rotation of the key of an organisation
+func NewKeyEntry(rotation *KeyRotation) *Entry {
+	return &Entry{Type: keyEntry, Key: rotation}
+}
+
+// NewGovernanceEntry records the governance of a genesis block
+func NewGovernanceEntry(governance *Governance) *Entry {
+	return &Entry{Type: governanceEntry, Governance: governance}
+}
+
+// NewRoleEntry records a change of the role of an organisation
+func NewRoleEntry(change *RoleChange) *Entry {
+	return &Entry{Type: roleEntry, Role: change}
+}
+
+// NewApprovalEntry records the approval of a registry change by an admin
+func NewApprovalEntry(approval *Approval) *Entry {
+	return &Entry{Type: approvalEntry, Approval: approval}
+}
+
+// NewCatalogEntry records a new version of a catalog entry
+func NewCatalogEntry(update *CatalogUpdate) *Entry {
+	return &Entry{Type: catalogEntry, Catalog: update}
+}
+
+// NewClaimAttemptEntry records a rejected claim of an item
+func NewClaimAttemptEntry(attempt *ClaimAttempt) *Entry {
+	return &Entry{Type: claimAttemptEntry, ClaimAttempt: attempt}
+}
+
+// NewMigrationEntry records the blocks converted from the three-chain format
+// in a genesis block
+func NewMigrationEntry(migration *Migration) *Entry {
+	return &Entry{Type: migrationEntry, Migration: migration}
+}
+
+// ID returns the ID of the payload
+func (e *Entry) ID() []byte {
+	switch e.Type {
+	case organisationEntry:
+		return e.Organisation.ID
+	case productEntry:
+		return e.Product.ID
+	case transactionEntry:
+		return e.Transaction.ID
+	case recallEntry:
+		return e.Recall.ID
+	case policyEntry:
+		return e.Policy.ID
+	case statusEntry:
+		return e.Status.ID
+	case keyEntry:
+		return e.Key.ID
+	case governanceEntry:
+		return e.Governance.ID
+	case roleEntry:
+		return e.Role.ID
+	case approvalEntry:
+		return e.Approval.ID
+	case catalogEntry:
+		return e.Catalog.ID
+	case claimAttemptEntry:
+		return e.ClaimAttempt.ID
+	case migrationEntry:
+		return e.Migration.ID
+	}
+
+	return nil
+}
+
+// Hash returns the Merkle leaf of the entry, committing to its type, ID and contents
+func (e *Entry) Hash() []byte {
+	var contents []byte
+
+	switch e.Type {
+	case organisationEntry:
+		contents = e.Organisation.Hash()
+	case productEntry:
+		contents = e.Product.Hash()
+	case transactionEntry:
+		contents = e.Transaction.Hash()
+	case recallEntry:
+		contents = e.Recall.Hash()
+	case policyEntry:
+		contents = e.Policy.Hash()
+	case statusEntry:
+		contents = e.Status.Hash()
+	case keyEntry:
+		contents = e.Key.Hash()
+	case governanceEntry:
+		contents = e.Governance.Hash()
+	case roleEntry:
+		contents = e.Role.Hash()
+	case approvalEntry:
+		contents = e.Approval.Hash()
+	case catalogEntry:
+		contents = e.Catalog.Hash()
+	case claimAttemptEntry:
+		contents = e.ClaimAttempt.Hash()
+	case migrationEntry:
+		contents = e.Migration.Hash()
+	}
+
+	return bytes.Join([][]byte{[]byte(e.Type), e.ID(), contents}, []byte{})
+}
+
+// IsWellFormed checks that exactly the payload matching the type is set
+func (e *Entry) IsWellFormed() bool {
+	var set bool
+
+	switch e.Type {
+	case organisationEntry:
+		set = e.Organisation != nil
+	case productEntry:
+		set = e.Product != nil
+	case transactionEntry:
+		set = e.Transaction != nil
+	case recallEntry:
+		set = e.Recall != nil
+	case policyEntry:
+		set = e.Policy != nil
+	case statusEntry:
+		set = e.Status != nil
+	case keyEntry:
+		set = e.Key != nil
+	case governanceEntry:
+		set = e.Governance != nil
+	case roleEntry:
+		set = e.Role != nil
+	case approvalEntry:
+		set = e.Approval != nil
+	case catalogEntry:
+		set = e.Catalog != nil
+	case claimAttemptEntry:
+		set = e.ClaimAttempt != nil
+	case migrationEntry:
+		set = e.Migration != nil
+	}
+
+	return set && e.payloads() == 1
+}
+
+// payloads counts the payloads set on the entry
+func (e *Entry) payloads() int {
+	count := 0
+
+	for _, set := range []bool{e.Organisation != nil, e.Product != nil, e.Transaction != nil, e.Recall != nil, e.Policy != nil, e.Status != nil, e.Key != nil, e.Governance != nil, e.Role != nil, e.Approval != nil, e.Catalog != nil, e.ClaimAttempt != nil, e.Migration != nil} {
+		if set {
+			count++
+		}
+	}
+
+	return count
+}
+
+// String returns a human-readable representation of an entry
+func (e Entry) String() string {
+	switch e.Type {
+	case organisationEntry:
+		return e.Organisation.String()
+	case productEntry:
+		return e.Product.String()
+	case transactionEntry:
+		return e.Transaction.String()
+	case recallEntry:
+		return e.Recall.String()
+	case policyEntry:
+		return e.Policy.String()
+	case statusEntry:
+		return e.Status.String()
+	case keyEntry:
+		return e.Key.String()
+	case governanceEntry:
+		return e.Governance.String()
+	case roleEntry:
+		return e.Role.String()
+	case approvalEntry:
+		return e.Approval.String()
+	case catalogEntry:
+		return e.Catalog.String()
+	case claimAttemptEntry:
+		return e.ClaimAttempt.String()
+	case migrationEntry:
+		return e.Migration.String()
+	}
+
+	return fmt.Sprintf("--- Unknown entry %s", e.Type)
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EPCIS 2.0 JSON-LD document
+const epcisContext = "https://ref.gs1.org/standards/epcis/epcis-context.jsonld"
+const epcisDocumentType = "EPCISDocument"
+const epcisSchemaVersion = "2.0"
+
+// EPCIS event types and actions
+const objectEvent = "ObjectEvent"
+const aggregationEvent = "AggregationEvent"
+const addAction = "ADD"
+const observeAction = "OBSERVE"
+const deleteAction = "DELETE"
+
+// CBV business steps and dispositions of the events of the ledger
+const commissioningStep = "commissioning"
+const shippingStep = "shipping"
+const packingStep = "packing"
+const unpackingStep = "unpacking"
+const decommissioningStep = "decommissioning"
+const acceptingStep = "accepting"
+const voidShippingStep = "void_shipping"
+const activeDisposition = "active"
+const inTransitDisposition = "in_transit"
+const inProgressDisposition = "in_progress"
+
+// decommissionDispositions map the reason codes of a decommissioning to CBV
+// dispositions
+var decommissionDispositions = map[string]string{
+	soldToConsumerReason: "retail_sold",
+	destroyedReason:      "destroyed",
+	expiredReason:        "expired",
+	stolenReason:         "stolen",
+	lostReason:           "unknown",
+	sampleReason:         "inactive",
+}
+
+// cbvPrefix starts the full URIs of CBV vocabulary, e.g. BizStep-shipping
+const cbvPrefix = "https://ref.gs1.org/cbv/"
+
+// owningParty is the CBV source and destination type of the owners of items
+const owningParty = "owning_party"
+
+// gstinPartyScheme identifies an organisation whose prefix is not digits by
+// its GSTIN
+const gstinPartyScheme = "urn:gstin:"
+
+// addressPartyScheme identifies an address that belongs to no organisation
+const addressPartyScheme = "urn:x-address:"
+
+// EPCISDocument is an EPCIS 2.0 document in JSON-LD
+type EPCISDocument struct {
+	Context       []interface{} `json:"@context"`
+	Type          string        `json:"type"`
+	SchemaVersion string        `json:"schemaVersion"`
+	CreationDate  string        `json:"creationDate"`
+	Body          EPCISBody     `json:"epcisBody"`
+}
+
+// EPCISBody holds the events of an EPCIS document
+type EPCISBody struct {
+	EventList []EPCISEvent `json:"eventList"`
+}
+
+// EPCISEvent is an ObjectEvent or an AggregationEvent. EPCList is only set on
+// ObjectEvents, ParentID and ChildEPCs on AggregationEvents.
+type EPCISEvent struct {
+	Type                string             `json:"type"`
+	EventTime           string             `json:"eventTime"`
+	EventTimeZoneOffset string             `json:"eventTimeZoneOffset"`
+	EPCList             []string           `json:"epcList,omitempty"`
+	ParentID            string             `json:"parentID,omitempty"`
+	ChildEPCs           []string           `json:"childEPCs,omitempty"`
+	Action              string             `json:"action"`
+	BizStep             string             `json:"bizStep,omitempty"`
+	Disposition         string             `json:"disposition,omitempty"`
+	SourceList          []EPCISSource      `json:"sourceList,omitempty"`
+	DestinationList     []EPCISDestination `json:"destinationList,omitempty"`
+	ILMD                *EPCISILMD         `json:"ilmd,omitempty"`
+}
+
+// EPCISSource is the party an event moves items from
+type EPCISSource struct {
+	Type   string `json:"type"`
+	Source string `json:"source"`
+}
+
+// EPCISDestination is the party an event moves items to
+type EPCISDestination struct {
+	Type        string `json:"type"`
+	Destination string `json:"destination"`
+}
+
+// EPCISILMD is the instance master data of commissioned items, from their
+// batch. Dates are YYYY-MM-DD.
+type EPCISILMD struct {
+	LotNumber          string `json:"cbvmda:lotNumber,omitempty"`
+	ProductionDate     string `json:"cbvmda:productionDate,omitempty"`
+	ItemExpirationDate string `json:"cbvmda:itemExpirationDate,omitempty"`
+}
+
+// NewEPCISDocument wraps events in an EPCIS document created at now
+func NewEPCISDocument(events []EPCISEvent, now time.Time) EPCISDocument {
+	if events == nil {
+		events = []EPCISEvent{}
+	}
+
+	return EPCISDocument{
+		[]interface{}{epcisContext},
+		epcisDocumentType,
+		epcisSchemaVersion,
+		now.UTC().Format(time.RFC3339),
+		EPCISBody{events},
+	}
+}
+
+// ExportEPCIS returns the events of the ledger as EPCIS events, oldest first:
+// mints as commissioning ObjectEvents, transfers as shipping ObjectEvents,
+// packing and unpacking as AggregationEvents and decommissionings as
+// decommissioning ObjectEvents. Offers are shipped to the address they are
+// made to, and accepted or void once answered. If item is set, only the
+// transactions carrying or spending it are exported.
+func (bc *Blockchain) ExportEPCIS(item string) ([]EPCISEvent, error) {
+	var blocks []*Block
+	var events []EPCISEvent
+
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+		blocks = append([]*Block{block}, blocks...)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	orgs := bc.GetOrganisations()
+	rotations := bc.GetKeyRotations()
+	outputs := make(map[string]TXOutput)
+
+	for _, block := range blocks {
+		for _, tx := range block.Transactions() {
+			var spent []TXOutput
+			for _, vin := range tx.Vin {
+				if out, ok := outputs[outpoint(vin.Txid, vin.Vout)]; ok {
+					spent = append(spent, out)
+				}
+			}
+			for _, out := range tx.Vout {
+				outputs[outpoint(tx.ID, out.Index)] = out
+			}
+
+			if item != "" && !carriesItem(spent, item) && !carriesItem(tx.Vout, item) {
+				continue
+			}
+
+			txEvents, err := transactionEvents(tx, spent, block.Timestamp, orgs, rotations)
+			if err != nil {
+				return nil, fmt.Errorf("Transaction %x: %s", tx.ID, err)
+			}
+			events = append(events, txEvents...)
+		}
+	}
+
+	return events, nil
+}
+
+// carriesItem checks whether any of outs carries item
+func carriesItem(outs []TXOutput, item string) bool {
+	for _, out := range outs {
+		if _, ok := out.Carries(item); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// transactionEvents maps a transaction spending the outputs spent to EPCIS
+// events. A logistic unit spent but not sent on was unpacked, a unit sent but
+// not spent was packed and the other items sent to another owner were shipped.
+func transactionEvents(tx *Transaction, spent []TXOutput, timestamp int64, orgs []Organisation, rotations []KeyRotation) ([]EPCISEvent, error) {
+	var events []EPCISEvent
+	eventTime := time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+
+	if tx.IsCoinbase() {
+		owner := string(Base58Encode(tx.Vout[0].PubKeyHash))
+		epcs, err := encodeEPCs(tx.Vout)
+		if err != nil {
+			return nil, err
+		}
+
+		event := EPCISEvent{objectEvent, eventTime, "+00:00", epcs, "", nil, addAction, commissioningStep, activeDisposition, nil, nil, NewEPCISILMD(tx.Vout[0].Batch)}
+		event.DestinationList = []EPCISDestination{{owningParty, epcisParty(owner, orgs, rotations)}}
+
+		return []EPCISEvent{event}, nil
+	}
+
+	sender := string(GetAddressFromPubKey(tx.Vin[0].PubKey))
+	if tx.IsDecommission() {
+		return decommissionEvents(tx, sender, eventTime, orgs, rotations)
+	}
+	if len(spent) > 0 && spent[0].IsOffered() {
+		return offerAnswerEvents(tx, spent, eventTime, orgs, rotations)
+	}
+
+	spentItems := make(map[string]bool)
+	sentItems := make(map[string]bool)
+	for _, out := range spent {
+		spentItems[out.Item] = true
+	}
+	for _, out := range tx.Vout {
+		sentItems[out.Item] = true
+	}
+
+	for _, out := range spent {
+		// items packed into a new unit are not sent on either
+		if !sentItems[out.Item] && len(out.Contents) > 0 {
+			event, err := aggregationEventOf(out, deleteAction, unpackingStep, eventTime)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+	}
+
+	var owners []string
+	shipped := make(map[string][]TXOutput)
+
+	for _, out := range tx.Vout {
+		owner := out.Recipient()
+
+		switch {
+		case !spentItems[out.Item] && len(out.Contents) > 0:
+			event, err := aggregationEventOf(out, addAction, packingStep, eventTime)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		case spentItems[out.Item] && owner != sender:
+			if _, ok := shipped[owner]; !ok {
+				owners = append(owners, owner)
+			}
+			shipped[owner] = append(shipped[owner], out)
+		}
+	}
+
+	for _, owner := range owners {
+		epcs, err := encodeEPCs(shipped[owner])
+		if err != nil {
+			return nil, err
+		}
+
+		event := EPCISEvent{objectEvent, eventTime, "+00:00", epcs, "", nil, observeAction, shippingStep, inTransitDisposition, nil, nil, nil}
+		event.SourceList = []EPCISSource{{owningParty, epcisParty(sender, orgs, rotations)}}
+		event.DestinationList = []EPCISDestination{{owningParty, epcisParty(owner, orgs, rotations)}}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// decommissionEvents maps a decommissioning by sender to one DELETE
+// ObjectEvent per reason code
+func decommissionEvents(tx *Transaction, sender, eventTime string, orgs []Organisation, rotations []KeyRotation) ([]EPCISEvent, error) {
+	var events []EPCISEvent
+	var reasons []string
+	byReason := make(map[string][]TXOutput)
+
+	for _, out := range tx.Vout {
+		reason := string(out.Decommission)
+		if _, ok := byReason[reason]; !ok {
+			reasons = append(reasons, reason)
+		}
+		byReason[reason] = append(byReason[reason], out)
+	}
+
+	for _, reason := range reasons {
+		epcs, err := encodeEPCs(byReason[reason])
+		if err != nil {
+			return nil, err
+		}
+
+		event := EPCISEvent{objectEvent, eventTime, "+00:00", epcs, "", nil, deleteAction, decommissioningStep, decommissionDispositions[reason], nil, nil, nil}
+		event.SourceList = []EPCISSource{{owningParty, epcisParty(sender, orgs, rotations)}}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// offerAnswerEvents maps the answer to the offers spent to an accepting
+// ObjectEvent for the items accepted and a void_shipping ObjectEvent for those
+// rejected or reclaimed, one per sender and recipient of the offers
+func offerAnswerEvents(tx *Transaction, spent []TXOutput, eventTime string, orgs []Organisation, rotations []KeyRotation) ([]EPCISEvent, error) {
+	type answer struct{ step, sender, recipient string }
+
+	var events []EPCISEvent
+	var answers []answer
+	offers := make(map[string]TXOutput)
+	answered := make(map[answer][]TXOutput)
+
+	for _, out := range spent {
+		offers[out.Item] = out
+	}
+	for _, out := range tx.Vout {
+		offer := offers[out.Item]
+		step := acceptingStep
+		if bytes.Compare(out.PubKeyHash, offer.PubKeyHash) == 0 {
+			step = voidShippingStep
+		}
+
+		a := answer{step, string(Base58Encode(offer.PubKeyHash)), offer.Recipient()}
+		if _, ok := answered[a]; !ok {
+			answers = append(answers, a)
+		}
+		answered[a] = append(answered[a], out)
+	}
+
+	for _, a := range answers {
+		epcs, err := encodeEPCs(answered[a])
+		if err != nil {
+			return nil, err
+		}
+
+		event := EPCISEvent{objectEvent, eventTime, "+00:00", epcs, "", nil, observeAction, a.step, inProgressDisposition, nil, nil, nil}
+		event.SourceList = []EPCISSource{{owningParty, epcisParty(a.sender, orgs, rotations)}}
+		event.DestinationList = []EPCISDestination{{owningParty, epcisParty(a.recipient, orgs, rotations)}}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// aggregationEventOf builds the AggregationEvent of a logistic unit and the
+// items packed in it directly
+func aggregationEventOf(unit TXOutput, action, bizStep, eventTime string) (EPCISEvent, error) {
+	parent, err := EncodeEPC(unit.Item)
+	if err != nil {
+		return EPCISEvent{}, err
+	}
+
+	var children []string
+	for _, packed := range unit.Contents {
+		child, err := EncodeEPC(packed.Item)
+		if err != nil {
+			return EPCISEvent{}, err
+		}
+		children = append(children, child)
+	}
+
+	return EPCISEvent{aggregationEvent, eventTime, "+00:00", nil, parent, children, action, bizStep, inProgressDisposition, nil, nil, nil}, nil
+}
+
+// encodeEPCs returns the EPC URIs of the items of outs
+func encodeEPCs(outs []TXOutput) ([]string, error) {
+	var epcs []string
+
+	for _, out := range outs {
+		epc, err := EncodeEPC(out.Item)
+		if err != nil {
+			return nil, err
+		}
+		epcs = append(epcs, epc)
+	}
+
+	return epcs, nil
+}
+
+// epcisParty identifies the owner of address: the party GLN of the prefix of
+// the organisation holding or having held it according to rotations, the GSTIN
+// of an organisation whose prefix is not digits, or else the address itself
+func epcisParty(address string, orgs []Organisation, rotations []KeyRotation) string {
+	org, ok := findOrganisationByAddress(address, orgs, rotations)
+	if !ok {
+		return addressPartyScheme + address
+	}
+
+	if pgln, err := EncodePGLN(string(org.Prefix)); err == nil {
+		return pgln
+	}
+	return gstinPartyScheme + string(org.GSTIN)
+}
+
+// resolveEPCISParty returns the address of a party identified as by
+// epcisParty. Organisations are resolved to the address of their current key.
+func resolveEPCISParty(party string, orgs []Organisation, rotations []KeyRotation) (string, error) {
+	if strings.HasPrefix(party, addressPartyScheme) {
+		address := strings.TrimPrefix(party, addressPartyScheme)
+		if !ValidateAddress(address) {
+			return "", fmt.Errorf("Party %s is not a valid address", party)
+		}
+		return address, nil
+	}
+
+	for _, org := range orgs {
+		pgln, err := EncodePGLN(string(org.Prefix))
+		// GSTINs are recorded upper case
+		if (err == nil && pgln == party) || strings.EqualFold(party, gstinPartyScheme+string(org.GSTIN)) {
+			keys := keysOf(rotations, org.PubKey)
+			return string(GetAddressFromPubKey(keys[len(keys)-1])), nil
+		}
+	}
+
+	return "", fmt.Errorf("Party %s is not an organisation or address", party)
+}
+
+// NewEPCISILMD returns the instance master data of items minted with batch,
+// nil if there is none
+func NewEPCISILMD(b *Batch) *EPCISILMD {
+	view := NewBatchView(b)
+	if view == nil {
+		return nil
+	}
+
+	return &EPCISILMD{view.Lot, view.Manufactured, view.Expiry}
+}
+
+// cbvTerm returns the bare CBV term of a value given as a term or a full URI
+// of the vocabulary, e.g. shipping for https://ref.gs1.org/cbv/BizStep-shipping
+func cbvTerm(value, vocabulary string) string {
+	return strings.TrimPrefix(value, cbvPrefix+vocabulary+"-")
+}
+
+// Validate checks that the document is an EPCIS 2.0 document whose events can
+// be recorded on the ledger: commissioning and shipping ObjectEvents and
+// AggregationEvents packing or unpacking a logistic unit
+func (doc EPCISDocument) Validate() error {
+	if doc.Type != epcisDocumentType {
+		return fmt.Errorf("Document type is %q, not %s", doc.Type, epcisDocumentType)
+	}
+	if doc.SchemaVersion != epcisSchemaVersion {
+		return fmt.Errorf("Schema version is %q, not %s", doc.SchemaVersion, epcisSchemaVersion)
+	}
+	if len(doc.Body.EventList) == 0 {
+		return errors.New("Document has no events")
+	}
+
+	for i, event := range doc.Body.EventList {
+		err := event.validate()
+		if err != nil {
+			return fmt.Errorf("Event %d: %s", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (event EPCISEvent) validate() error {
+	if _, err := time.Parse(time.RFC3339, event.EventTime); err != nil {
+		return errors.New("Event time is not an RFC 3339 date-time")
+	}
+
+	bizStep := cbvTerm(event.BizStep, "BizStep")
+
+	switch {
+	case event.Type == objectEvent && event.Action == addAction:
+		if bizStep != commissioningStep {
+			return fmt.Errorf("ObjectEvent ADD has business step %q, not %s", event.BizStep, commissioningStep)
+		}
+		if len(event.EPCList) == 0 {
+			return errors.New("ObjectEvent has no EPCs")
+		}
+	case event.Type == objectEvent && event.Action == observeAction:
+		if len(event.EPCList) == 0 {
+			return errors.New("ObjectEvent has no EPCs")
+		}
+		if event.destination() == "" {
+			return errors.New("ObjectEvent OBSERVE has no owning party destination")
+		}
+	case event.Type == aggregationEvent && (event.Action == addAction || event.Action == deleteAction):
+		if event.ParentID == "" {
+			return errors.New("AggregationEvent has no parent")
+		}
+		if event.Action == addAction && len(event.ChildEPCs) == 0 {
+			return errors.New("AggregationEvent ADD has no children")
+		}
+	default:
+		return fmt.Errorf("%s %s cannot be recorded", event.Type, event.Action)
+	}
+
+	return nil
+}
+
+// ValidateEPCIS checks an EPCIS document as Validate does and that the EPCs and
+// parties of its events are known to the ledger
+func (bc *Blockchain) ValidateEPCIS(doc EPCISDocument) error {
+	err := doc.Validate()
+	if err != nil {
+		return err
+	}
+
+	orgs := bc.GetOrganisations()
+	rotations := bc.GetKeyRotations()
+
+	for i, event := range doc.Body.EventList {
+		epcs := event.EPCList
+		if event.Type == aggregationEvent {
+			epcs = append([]string{event.ParentID}, event.ChildEPCs...)
+		}
+		if _, err := bc.ResolveItems(epcs); err != nil {
+			return fmt.Errorf("Event %d: %s", i+1, err)
+		}
+
+		for _, party := range []string{event.source(), event.destination()} {
+			if party == "" {
+				continue
+			}
+			if _, err := resolveEPCISParty(party, orgs, rotations); err != nil {
+				return fmt.Errorf("Event %d: %s", i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// source returns the owning party the event moves items from, if any
+func (event EPCISEvent) source() string {
+	for _, source := range event.SourceList {
+		if cbvTerm(source.Type, "SDT") == owningParty {
+			return source.Source
+		}
+	}
+
+	return ""
+}
+
+// destination returns the owning party the event moves items to, if any
+func (event EPCISEvent) destination() string {
+	for _, destination := range event.DestinationList {
+		if cbvTerm(destination.Type, "SDT") == owningParty {
+			return destination.Destination
+		}
+	}
+
+	return ""
+}
+
+// NewEPCISTransaction turns a validated EPCIS event into a transaction signed
+// by wallet, which holds address. The event must move items from address, or
+// commission them to it. Commissioning mints the items listed, shipping sends
+// them to the owner of the destination, and aggregation packs the children
+// into the parent SSCC or unpacks it.
+func NewEPCISTransaction(wallet Wallet, address string, event EPCISEvent, UTXOSet *UTXOSet) (*Transaction, error) {
+	bc := UTXOSet.Blockchain
+	orgs := bc.GetOrganisations()
+	rotations := bc.GetKeyRotations()
+
+	if source := event.source(); source != "" {
+		from, err := resolveEPCISParty(source, orgs, rotations)
+		if err != nil {
+			return nil, err
+		}
+		if from != address {
+			return nil, fmt.Errorf("Source %s is not %s", source, address)
+		}
+	}
+
+	if event.Type == aggregationEvent {
+		parent, err := bc.ResolveItem(event.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if event.Action == deleteAction {
+			return NewUnpackTransaction(wallet, address, parent, UTXOSet)
+		}
+
+		children, err := bc.ResolveItems(event.ChildEPCs)
+		if err != nil {
+			return nil, err
+		}
+		return NewPackTransactionInto(wallet, address, parent, children, UTXOSet)
+	}
+
+	items, err := bc.ResolveItems(event.EPCList)
+	if err != nil {
+		return nil, err
+	}
+
+	to := address
+	if destination := event.destination(); destination != "" {
+		to, err = resolveEPCISParty(destination, orgs, rotations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if event.Action == addAction {
+		if to != address {
+			return nil, fmt.Errorf("Items are commissioned to %s, not %s", event.destination(), address)
+		}
+
+		var batch *Batch
+		if event.ILMD != nil {
+			batch, err = NewBatch(event.ILMD.LotNumber, event.ILMD.ProductionDate, event.ILMD.ItemExpirationDate)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return NewMintTransaction(wallet, address, items, batch), nil
+	}
+
+	if _, _, err := UTXOSet.findOutputsOf(wallet, items); err != nil {
+		return nil, err
+	}
+
+	return NewUTXOTransaction(wallet, address, to, items, UTXOSet), nil
+}
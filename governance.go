@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+const proposalsBucket = "proposals"
+
+// Governance requires registry changes signed by an admin to be approved by
+// Threshold admins, counting the one who signed them. It is recorded in the
+// genesis block and cannot change afterwards.
+type Governance struct {
+	ID        []byte
+	Threshold int
+}
+
+// NewGovernance creates the governance of a ledger whose registry changes
+// need threshold admins
+func NewGovernance(threshold int) *Governance {
+	governance := &Governance{nil, threshold}
+	governance.ID = governance.Hash()
+
+	return governance
+}
+
+// Hash returns the hash of the threshold of the governance
+func (g *Governance) Hash() []byte {
+	return HashFields(IntToHex(int64(g.Threshold)))
+}
+
+// String returns a human-readable representation of a governance
+func (g Governance) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Governance %x:", g.ID))
+	lines = append(lines, fmt.Sprintf("       Threshold:       %d", g.Threshold))
+	return strings.Join(lines, "\n")
+}
+
+// GetGovernance returns the governance recorded in the genesis block, or nil
+// if a single admin may change the registry
+func (bc *Blockchain) GetGovernance() *Governance {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		if len(block.PrevBlockHash) == 0 {
+			for _, e := range block.Entries {
+				if e.Type == governanceEntry {
+					return e.Governance
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// GetAdmins returns the organisations whose current role is Admin
+func (bc *Blockchain) GetAdmins() []Organisation {
+	var admins []Organisation
+
+	for _, org := range bc.GetOrganisations() {
+		if bytes.Compare(org.Role, []byte("Admin")) == 0 {
+			admins = append(admins, org)
+		}
+	}
+
+	return admins
+}
+
+// RequiredApprovals returns the number of admins that must approve a registry
+// change for the next block. It is the threshold of the governance, or every
+// admin while there are fewer, and 1 without governance.
+func (bc *Blockchain) RequiredApprovals() int {
+	governance := bc.GetGovernance()
+	if governance == nil {
+		return 1
+	}
+
+	admins := len(bc.GetAdmins())
+	if admins < governance.Threshold {
+		return admins
+	}
+
+	return governance.Threshold
+}
+
+// Proposer returns the key of the admin who signed a registry change, or nil
+// if the entry is not one. Key rotations signed by the organisation itself
+// are not registry changes.
+func (e *Entry) Proposer() []byte {
+	switch e.Type {
+	case organisationEntry:
+		return e.Organisation.AdminPubKey
+	case statusEntry:
+		return e.Status.PubKey
+	case roleEntry:
+		return e.Role.PubKey
+	case keyEntry:
+		if bytes.Compare(e.Key.PubKey, e.Key.OldPubKey) != 0 {
+			return e.Key.PubKey
+		}
+	}
+
+	return nil
+}
+
+// Approval is the signature of an admin approving the registry change with ID
+// Proposal. It is recorded in the block of the change.
+type Approval struct {
+	ID        []byte
+	Proposal  []byte
+	Timestamp int64
+	Signature []byte
+	PubKey    []byte
+}
+
+// NewApproval creates the approval of a proposal signed by the admin holding
+// address
+func NewApproval(address string, proposal *Proposal, bc *Blockchain, nodeID string) (*Approval, error) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("Address %s is not in the wallet of this node", address)
+	}
+
+	for _, approver := range proposal.Approvers() {
+		if bytes.Compare(approver, wallet.PublicKey) == 0 {
+			return nil, fmt.Errorf("Admin %s already approved proposal %x", address, proposal.ID())
+		}
+	}
+
+	approval := &Approval{nil, proposal.ID(), time.Now().Unix(), nil, wallet.PublicKey}
+	approval.Sign(wallet.PrivateKey)
+
+	err = bc.ValidateApproval(approval, proposal.Entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return approval, nil
+}
+
+// Hash returns the hash of the approval, excluding its ID
+func (a *Approval) Hash() []byte {
+	return HashFields(
+		a.Proposal,
+		IntToHex(a.Timestamp),
+		a.Signature,
+		a.PubKey,
+	)
+}
+
+// Sign sets the ID of the approval and signs it
+func (a *Approval) Sign(privKey ecdsa.PrivateKey) {
+	a.Signature = nil
+	a.ID = a.Hash()
+
+	a.Signature = signDigest(privKey, a.ID)
+}
+
+// Verify checks that the ID matches the approval and is signed by its PubKey
+func (a *Approval) Verify() bool {
+	aCopy := *a
+	aCopy.Signature = nil
+	if bytes.Compare(aCopy.Hash(), a.ID) != 0 {
+		return false
+	}
+
+	return verifyDigest(a.PubKey, a.ID, a.Signature)
+}
+
+// String returns a human-readable representation of an approval
+func (a Approval) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Approval %x:", a.ID))
+
+	lines = append(lines, fmt.Sprintf("       Proposal:       %x", a.Proposal))
+	lines = append(lines, fmt.Sprintf("       Admin:       %s", GetAddressFromPubKey(a.PubKey)))
+	lines = append(lines, fmt.Sprintf("       Signature: %x", a.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %x", a.PubKey))
+	return strings.Join(lines, "\n")
+}
+
+// Proposal is a registry change waiting for the approvals of admins. Nodes
+// keep proposals outside the ledger and exchange them until one collects
+// enough approvals to be mined with them.
+type Proposal struct {
+	Entry     *Entry
+	Approvals []*Approval
+}
+
+// NewProposal creates a proposal of a registry change without approvals
+func NewProposal(entry *Entry) *Proposal {
+	return &Proposal{entry, nil}
+}
+
+// ID returns the ID of the proposed change
+func (p *Proposal) ID() []byte {
+	return p.Entry.ID()
+}
+
+// Approvers returns the keys of the admins approving the change, starting
+// with the one who proposed it
+func (p *Proposal) Approvers() [][]byte {
+	approvers := [][]byte{p.Entry.Proposer()}
+
+	for _, approval := range p.Approvals {
+		approvers = append(approvers, approval.PubKey)
+	}
+
+	return approvers
+}
+
+// Entries returns the change followed by its approvals, as mined
+func (p *Proposal) Entries() []*Entry {
+	entries := []*Entry{p.Entry}
+
+	for _, approval := range p.Approvals {
+		entries = append(entries, NewApprovalEntry(approval))
+	}
+
+	return entries
+}
+
+// Merge adds the approvals of other by admins that did not approve p yet and
+// reports whether it added any
+func (p *Proposal) Merge(other *Proposal) bool {
+	merged := false
+
+	for _, approval := range other.Approvals {
+		approved := false
+		for _, approver := range p.Approvers() {
+			approved = approved || bytes.Compare(approver, approval.PubKey) == 0
+		}
+
+		if !approved {
+			p.Approvals = append(p.Approvals, approval)
+			merged = true
+		}
+	}
+
+	return merged
+}
+
+// Serialize serializes the proposal
+func (p *Proposal) Serialize() []byte {
+	var result bytes.Buffer
+	encoder := gob.NewEncoder(&result)
+
+	err := encoder.Encode(p)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return result.Bytes()
+}
+
+// DeserializeProposal deserializes a proposal
+func DeserializeProposal(d []byte) *Proposal {
+	var proposal Proposal
+
+	decoder := gob.NewDecoder(bytes.NewReader(d))
+	err := decoder.Decode(&proposal)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return &proposal
+}
+
+// SaveProposal keeps a proposal, replacing the one with the same ID
+func (bc *Blockchain) SaveProposal(proposal *Proposal) {
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(proposalsBucket))
+		if err != nil {
+			return err
+		}
+
+		return b.Put(proposal.ID(), proposal.Serialize())
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// DeleteProposal forgets the proposal with ID id
+func (bc *Blockchain) DeleteProposal(id []byte) {
+	err := bc.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(proposalsBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.Delete(id)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// FindProposal returns the pending proposal with ID id, or nil
+func (bc *Blockchain) FindProposal(id []byte) *Proposal {
+	for _, proposal := range bc.GetProposals() {
+		if bytes.Compare(proposal.ID(), id) == 0 {
+			return proposal
+		}
+	}
+
+	return nil
+}
+
+// GetProposals returns the pending proposals. Proposals whose change has been
+// mined or can no longer be, such as a status change whose effective height
+// has passed, are forgotten.
+func (bc *Blockchain) GetProposals() []*Proposal {
+	var proposals []*Proposal
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(proposalsBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			proposals = append(proposals, DeserializeProposal(v))
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var pending []*Proposal
+	for _, proposal := range proposals {
+		if bc.IsRecorded(proposal.ID()) || bc.ValidateProposal(proposal) != nil {
+			bc.DeleteProposal(proposal.ID())
+			continue
+		}
+		pending = append(pending, proposal)
+	}
+
+	return pending
+}
+
+// IsRecorded checks whether an entry with ID id is on the ledger
+func (bc *Blockchain) IsRecorded(id []byte) bool {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, e := range block.Entries {
+			if bytes.Compare(e.ID(), id) == 0 {
+				return true
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			return false
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// epcSGTINScheme starts the EPC pure identity URI of an SGTIN
+const epcSGTINScheme = "urn:epc:id:sgtin:"
+
+// epcSSCCScheme starts the EPC pure identity URI of an SSCC
+const epcSSCCScheme = "urn:epc:id:sscc:"
+
+// epcPGLNScheme starts the EPC pure identity URI of a party GLN
+const epcPGLNScheme = "urn:epc:id:pgln:"
+
+// ssccLength is the number of digits of an SSCC, check digit included
+const ssccLength = 18
+
+// glnLength is the number of digits of a GLN, check digit included
+const glnLength = 13
+
+// digitalLinkDomain is the resolver of the GS1 Digital Links built for items
+const digitalLinkDomain = "https://id.gs1.org"
+
+// gtinLength is the number of digits of a GTIN-14, check digit included
+const gtinLength = 14
+
+// GS1 application identifiers of an SGTIN
+const gtinAI = "01"
+const serialAI = "21"
+
+var digits = regexp.MustCompile(`^[0-9]+$`)
+
+var elementString = regexp.MustCompile(`^(\([0-9]{2,4}\)[^()]+)+$`)
+
+var elementField = regexp.MustCompile(`\(([0-9]{2,4})\)([^()]+)`)
+
+// GS1Identifiers are the standard forms of an SGTIN
+type GS1Identifiers struct {
+	EPC           string
+	GTIN          string
+	ElementString string
+	DigitalLink   string
+}
+
+// gtinCheckDigit computes the check digit of the digits of a GTIN before it
+func gtinCheckDigit(number string) int {
+	sum := 0
+
+	// weights alternate 3 and 1 starting from the rightmost digit
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if (len(number)-i)%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+
+	return (10 - sum%10) % 10
+}
+
+// itemReference returns the indicator digit and product code of an item
+// reference, padded to fill 13 digits with the company prefix
+func itemReference(prefix string, code int) (string, error) {
+	if !digits.MatchString(prefix) || len(prefix) >= gtinLength-2 {
+		return "", fmt.Errorf("Prefix %s is not a GS1 company prefix of at most %d digits", prefix, gtinLength-3)
+	}
+
+	width := gtinLength - 2 - len(prefix)
+	reference := strconv.Itoa(code)
+	if len(reference) > width {
+		return "", fmt.Errorf("Product code %d does not fit the %d digits left by prefix %s", code, width, prefix)
+	}
+
+	return "0" + strings.Repeat("0", width-len(reference)) + reference, nil
+}
+
+// FormatGTIN builds the GTIN-14 of a product code of a company prefix
+func FormatGTIN(prefix string, code int) (string, error) {
+	reference, err := itemReference(prefix, code)
+	if err != nil {
+		return "", err
+	}
+
+	number := reference[:1] + prefix + reference[1:]
+
+	return number + strconv.Itoa(gtinCheckDigit(number)), nil
+}
+
+// EncodeGS1 returns the standard forms of an SGTIN
+func EncodeGS1(item string) (GS1Identifiers, error) {
+	prefix, code, serial, err := ParseSGTIN(item)
+	if err != nil {
+		return GS1Identifiers{}, err
+	}
+
+	reference, err := itemReference(prefix, code)
+	if err != nil {
+		return GS1Identifiers{}, err
+	}
+
+	gtin, err := FormatGTIN(prefix, code)
+	if err != nil {
+		return GS1Identifiers{}, err
+	}
+
+	return GS1Identifiers{
+		EPC:           fmt.Sprintf("%s%s.%s.%d", epcSGTINScheme, prefix, reference, serial),
+		GTIN:          gtin,
+		ElementString: fmt.Sprintf("(%s)%s(%s)%d", gtinAI, gtin, serialAI, serial),
+		DigitalLink:   fmt.Sprintf("%s/%s/%s/%s/%d", digitalLinkDomain, gtinAI, gtin, serialAI, serial),
+	}, nil
+}
+
+// DecodeEPCURI reads the SGTIN of an EPC pure identity URI
+func DecodeEPCURI(uri string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(uri, epcSGTINScheme), ".")
+	if !strings.HasPrefix(uri, epcSGTINScheme) || len(parts) != 3 {
+		return "", errors.New("EPC URI must have the form urn:epc:id:sgtin:CompanyPrefix.ItemRefAndIndicator.SerialNumber")
+	}
+
+	prefix, reference := parts[0], parts[1]
+	if !digits.MatchString(prefix) || !digits.MatchString(reference) || len(prefix)+len(reference) != gtinLength-1 {
+		return "", errors.New("EPC URI company prefix and item reference must have 13 digits")
+	}
+	if reference[0] != '0' {
+		return "", errors.New("EPC URI indicator digit must be 0")
+	}
+
+	code, _ := strconv.Atoi(reference[1:])
+
+	return formatDecodedSGTIN(prefix, code, parts[2])
+}
+
+// DecodeGTIN splits a GTIN into the company prefix among prefixes it starts
+// with and its product code. GTIN-8, GTIN-12 and GTIN-13 are padded to 14
+// digits.
+func DecodeGTIN(gtin string, prefixes []string) (string, int, error) {
+	if !digits.MatchString(gtin) || len(gtin) > gtinLength {
+		return "", 0, errors.New("GTIN must have at most 14 digits")
+	}
+
+	gtin = strings.Repeat("0", gtinLength-len(gtin)) + gtin
+	if strconv.Itoa(gtinCheckDigit(gtin[:gtinLength-1])) != gtin[gtinLength-1:] {
+		return "", 0, fmt.Errorf("GTIN %s has a wrong check digit", gtin)
+	}
+	if gtin[0] != '0' {
+		return "", 0, errors.New("GTIN indicator digit must be 0")
+	}
+
+	// company prefixes do not overlap, the longest match is the most specific
+	prefix := ""
+	for _, p := range prefixes {
+		if digits.MatchString(p) && strings.HasPrefix(gtin[1:gtinLength-1], p) && len(p) > len(prefix) && len(p) < gtinLength-2 {
+			prefix = p
+		}
+	}
+	if prefix == "" {
+		return "", 0, fmt.Errorf("GTIN %s does not start with the prefix of an organisation", gtin)
+	}
+
+	code, _ := strconv.Atoi(gtin[1+len(prefix) : gtinLength-1])
+
+	return prefix, code, nil
+}
+
+// DecodeElementString reads the SGTIN of a GS1 element string such as
+// (01)GTIN(21)SERIAL, resolving the company prefix among prefixes. Other
+// application identifiers are ignored.
+func DecodeElementString(element string, prefixes []string) (string, error) {
+	if !elementString.MatchString(element) {
+		return "", errors.New("GS1 element string must have the form (01)GTIN(21)SERIAL")
+	}
+
+	fields := make(map[string]string)
+	for _, field := range elementField.FindAllStringSubmatch(element, -1) {
+		fields[field[1]] = field[2]
+	}
+
+	return decodeGTINAndSerial(fields[gtinAI], fields[serialAI], prefixes)
+}
+
+// DecodeDigitalLink reads the SGTIN of a GS1 Digital Link URL of any domain,
+// such as https://id.gs1.org/01/GTIN/21/SERIAL, resolving the company prefix
+// among prefixes
+func DecodeDigitalLink(link string, prefixes []string) (string, error) {
+	gtin, serial, err := parseDigitalLink(link)
+	if err != nil {
+		return "", err
+	}
+
+	return decodeGTINAndSerial(gtin, serial, prefixes)
+}
+
+// DigitalLinkElementString returns the GS1 element string of the item named by
+// a Digital Link. Unlike the link it can be used as a segment of a path.
+func DigitalLinkElementString(link string) (string, error) {
+	gtin, serial, err := parseDigitalLink(link)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("(%s)%s(%s)%s", gtinAI, gtin, serialAI, serial), nil
+}
+
+// parseDigitalLink returns the GTIN and serial in the path of a Digital Link
+func parseDigitalLink(link string) (string, string, error) {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", "", errors.New("GS1 Digital Link must be an http or https URL")
+	}
+
+	segments := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	for i := 0; i+3 < len(segments); i++ {
+		if segments[i] != gtinAI || segments[i+2] != serialAI {
+			continue
+		}
+
+		serial, err := url.PathUnescape(segments[i+3])
+		if err != nil {
+			return "", "", errors.New("GS1 Digital Link serial is not escaped")
+		}
+
+		return segments[i+1], serial, nil
+	}
+
+	return "", "", errors.New("GS1 Digital Link must have the path /01/GTIN/21/SERIAL")
+}
+
+// decodeGTINAndSerial builds the SGTIN of a GTIN and a serial
+func decodeGTINAndSerial(gtin, serial string, prefixes []string) (string, error) {
+	if gtin == "" || serial == "" {
+		return "", errors.New("GS1 identifier must have a GTIN (01) and a serial (21)")
+	}
+
+	prefix, code, err := DecodeGTIN(gtin, prefixes)
+	if err != nil {
+		return "", err
+	}
+
+	return formatDecodedSGTIN(prefix, code, serial)
+}
+
+// formatDecodedSGTIN builds the SGTIN of a decoded identifier, whose serial
+// must be a positive number without leading zeros to name the same item
+func formatDecodedSGTIN(prefix string, code int, serial string) (string, error) {
+	s, err := strconv.Atoi(serial)
+	if err != nil || s < 1 || strconv.Itoa(s) != serial {
+		return "", fmt.Errorf("Serial %s is not a positive number without leading zeros", serial)
+	}
+	if code < 1 {
+		return "", errors.New("Product code is not a positive number")
+	}
+
+	return FormatSGTIN(prefix, code, s), nil
+}
+
+// EncodeSSCCURI returns the EPC pure identity URI of the SSCC of a logistic
+// unit, with extension digit 0
+func EncodeSSCCURI(unit string) (string, error) {
+	prefix, serial, err := ParseSSCC(unit)
+	if err != nil {
+		return "", err
+	}
+	if !digits.MatchString(prefix) || len(prefix) >= ssccLength-2 {
+		return "", fmt.Errorf("Prefix %s is not a GS1 company prefix of at most %d digits", prefix, ssccLength-3)
+	}
+
+	width := ssccLength - 2 - len(prefix)
+	reference := strconv.Itoa(serial)
+	if len(reference) > width {
+		return "", fmt.Errorf("Serial %d does not fit the %d digits left by prefix %s", serial, width, prefix)
+	}
+
+	return fmt.Sprintf("%s%s.0%s%s", epcSSCCScheme, prefix, strings.Repeat("0", width-len(reference)), reference), nil
+}
+
+// DecodeSSCCURI reads the SSCC of an EPC pure identity URI
+func DecodeSSCCURI(uri string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(uri, epcSSCCScheme), ".")
+	if !strings.HasPrefix(uri, epcSSCCScheme) || len(parts) != 2 {
+		return "", errors.New("EPC URI must have the form urn:epc:id:sscc:CompanyPrefix.SerialReference")
+	}
+
+	prefix, reference := parts[0], parts[1]
+	if !digits.MatchString(prefix) || !digits.MatchString(reference) || len(prefix)+len(reference) != ssccLength-1 {
+		return "", errors.New("EPC URI company prefix and serial reference must have 17 digits")
+	}
+	if reference[0] != '0' {
+		return "", errors.New("EPC URI extension digit must be 0")
+	}
+
+	serial, _ := strconv.Atoi(reference[1:])
+	if serial < 1 {
+		return "", errors.New("SSCC serial is not a positive number")
+	}
+
+	return FormatSSCC(prefix, serial), nil
+}
+
+// EncodeEPC returns the EPC pure identity URI of an SGTIN or SSCC
+func EncodeEPC(item string) (string, error) {
+	if _, _, err := ParseSSCC(item); err == nil {
+		return EncodeSSCCURI(item)
+	}
+
+	ids, err := EncodeGS1(item)
+	if err != nil {
+		return "", err
+	}
+
+	return ids.EPC, nil
+}
+
+// EncodePGLN returns the EPC pure identity URI of the party GLN of a company
+// prefix, with a party reference of zeros
+func EncodePGLN(prefix string) (string, error) {
+	if !digits.MatchString(prefix) || len(prefix) >= glnLength {
+		return "", fmt.Errorf("Prefix %s is not a GS1 company prefix of at most %d digits", prefix, glnLength-1)
+	}
+
+	return epcPGLNScheme + prefix + "." + strings.Repeat("0", glnLength-1-len(prefix)), nil
+}
+
+// ResolveItem returns the SGTIN or SSCC of an item given in any of the forms
+// accepted by the CLI and the API: the internal identifiers, EPC pure identity
+// URIs of SGTINs and SSCCs, GS1 element strings and GS1 Digital Links
+func (bc *Blockchain) ResolveItem(item string) (string, error) {
+	// the standard forms contain dots, so they are told apart first
+	switch {
+	case strings.HasPrefix(item, epcSGTINScheme):
+		return DecodeEPCURI(item)
+	case strings.HasPrefix(item, epcSSCCScheme):
+		return DecodeSSCCURI(item)
+	case strings.HasPrefix(item, "("):
+		return DecodeElementString(item, bc.getPrefixes())
+	case isDigitalLink(item):
+		return DecodeDigitalLink(item, bc.getPrefixes())
+	}
+
+	if _, _, _, err := ParseSGTIN(item); err == nil {
+		return item, nil
+	}
+	if _, _, err := ParseSSCC(item); err == nil {
+		return item, nil
+	}
+
+	return "", fmt.Errorf("Item %s is neither an SGTIN, an SSCC, an EPC URI, a GS1 element string nor a GS1 Digital Link", item)
+}
+
+// ResolveItems returns the SGTIN or SSCC of every item, as ResolveItem does
+func (bc *Blockchain) ResolveItems(items []string) ([]string, error) {
+	var resolved []string
+
+	for _, item := range items {
+		r, err := bc.ResolveItem(item)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, r)
+	}
+
+	return resolved, nil
+}
+
+// getPrefixes returns the GS1 company prefixes of the organisations
+func (bc *Blockchain) getPrefixes() []string {
+	var prefixes []string
+
+	for _, org := range bc.GetOrganisations() {
+		prefixes = append(prefixes, string(org.Prefix))
+	}
+
+	return prefixes
+}
+
+// isDigitalLink tells whether an item is given as a GS1 Digital Link
+func isDigitalLink(item string) bool {
+	return strings.HasPrefix(item, "http://") || strings.HasPrefix(item, "https://")
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// testChain is a blockchain in a temporary directory whose entries are built
+// and signed with in-memory wallets, so that tests need no wallet file
+type testChain struct {
+	t     *testing.T
+	bc    *Blockchain
+	admin *Wallet
+	// sealer signs the blocks of a ledger sealed by proof of authority
+	sealer *Wallet
+}
+
+// newTestChain creates a blockchain sealed by consensus with an Admin, and
+// restricted by policy unless it is nil
+func newTestChain(t *testing.T, consensus string, policy *TransferPolicy) *testChain {
+	nodeID := "test"
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := NewWallet()
+	CreateBlockchain("Admin", hex.EncodeToString(admin.PublicKey), "ad1", "0001", consensus, policy, nil, nodeID)
+	bc := NewBlockchain(nodeID)
+
+	t.Cleanup(func() {
+		bc.db.Close()
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+
+	return &testChain{t, bc, admin, admin}
+}
+
+// addressOf returns the address of a wallet
+func addressOf(w *Wallet) string {
+	return string(w.GetAddress())
+}
+
+// block builds the next block on the tip with entries, sealed by the engine
+// of the ledger
+func (c *testChain) block(entries ...*Entry) *Block {
+	tip, err := c.bc.GetBlock(c.bc.tip)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return c.blockAfter(&tip, entries...)
+}
+
+// blockAfter builds a block on parent with entries, sealed by the engine of
+// the ledger
+func (c *testChain) blockAfter(parent *Block, entries ...*Entry) *Block {
+	block := NewBlock(entries, parent.Hash, parent.Height+1)
+	c.seal(block, c.sealer)
+
+	return block
+}
+
+// seal seals block with the engine of the ledger, signing it with wallet
+// under proof of authority
+func (c *testChain) seal(block *Block, wallet *Wallet) {
+	poa, ok := c.bc.consensus.(*PoAEngine)
+	if !ok {
+		PoWEngine{}.Seal(block)
+		return
+	}
+
+	block.Validator = wallet.PublicKey
+	block.Hash = poa.Hash(block)
+	block.Signature = signDigest(wallet.PrivateKey, block.Hash)
+}
+
+// mine adds entries to the ledger in a new block on the tip
+func (c *testChain) mine(entries ...*Entry) error {
+	return c.bc.AddBlock(c.block(entries...))
+}
+
+// mustMine mines entries and fails the test if they are rejected
+func (c *testChain) mustMine(entries ...*Entry) {
+	c.t.Helper()
+
+	err := c.mine(entries...)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// register records an organisation with role signed by the Admin and returns
+// its wallet
+func (c *testChain) register(name, prefix, role string) *Wallet {
+	c.t.Helper()
+	wallet := NewWallet()
+
+	org := &Organisation{nil, []byte(name), []byte(name), []byte(prefix), []byte(role), nil, wallet.PublicKey, c.admin.PublicKey}
+	org.ID = org.Hash()
+	org.Sign(c.admin.PrivateKey, Base58Decode([]byte(addressOf(c.admin))))
+
+	c.mustMine(NewOrganisationEntry(org))
+
+	return wallet
+}
+
+// addProducts records products of the manufacturer holding maker
+func (c *testChain) addProducts(maker *Wallet, names ...string) {
+	c.t.Helper()
+	var products []*Product
+
+	code := c.bc.GetNextProductCode(addressOf(maker))
+	for i, name := range names {
+		product := &Product{nil, code + i, []byte(name), nil, maker.PublicKey, nil}
+		product.ID = product.Hash()
+		products = append(products, product)
+	}
+	SignProducts(products, maker.PrivateKey, Base58Decode([]byte(addressOf(maker))))
+
+	c.mustMine(NewProductEntries(products)...)
+}
+
+// mint records a coinbase of the manufacturer holding maker minting items
+func (c *testChain) mint(maker *Wallet, items ...string) {
+	c.t.Helper()
+
+	c.mustMine(mintEntry(maker, items...))
+}
+
+// mintEntry returns a coinbase of the manufacturer holding maker minting items
+func mintEntry(maker *Wallet, items ...string) *Entry {
+	tx := NewMintTransaction(*maker, addressOf(maker), items, nil)
+
+	return NewTransactionEntries([]*Transaction{tx})[0]
+}
+
+// changeStatus records a status change of org signed by the Admin, taking
+// effect at the next block
+func (c *testChain) changeStatus(org *Wallet, action string) {
+	c.t.Helper()
+
+	change := &StatusChange{nil, org.PublicKey, []byte(action), []byte("test"), c.bc.GetBestHeight() + 1, time.Now().Unix(), nil, c.admin.PublicKey}
+	change.Sign(c.admin.PrivateKey)
+
+	c.mustMine(NewStatusEntry(change))
+}
+
+// changeRole records a role change of the organisation registered with org,
+// signed by the Admin
+func (c *testChain) changeRole(org *Wallet, role string) {
+	c.t.Helper()
+
+	change := &RoleChange{nil, org.PublicKey, []byte(role), time.Now().Unix(), nil, c.admin.PublicKey}
+	change.Sign(c.admin.PrivateKey)
+
+	c.mustMine(NewRoleEntry(change))
+}
+
+// rotate records the rotation of the key of the organisation holding from to
+// a new key, signed by the organisation, and returns the new wallet
+func (c *testChain) rotate(from *Wallet) *Wallet {
+	c.t.Helper()
+	wallet := NewWallet()
+
+	rotation := &KeyRotation{nil, from.PublicKey, wallet.PublicKey, time.Now().Unix(), nil, from.PublicKey, nil}
+	rotation.Sign(from.PrivateKey, wallet.PrivateKey)
+
+	c.mustMine(NewKeyEntry(rotation))
+
+	return wallet
+}
+
+// items returns the SGTINs of serials 1 to n of a product code under prefix
+func items(prefix string, code, n int) []string {
+	var sgtins []string
+
+	for serial := 1; serial <= n; serial++ {
+		sgtins = append(sgtins, fmt.Sprintf("%s.%d.%d", prefix, code, serial))
+	}
+
+	return sgtins
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Actions of the events of a chain of custody
+const mintAction = "mint"
+const transferAction = "transfer"
+const packAction = "pack"
+const unpackAction = "unpack"
+const sellAction = "sell"
+const claimAction = "claim"
+const rejectedClaimAction = "rejected_claim"
+const decommissionAction = "decommission"
+const offerAction = "offer"
+const acceptAction = "accept"
+const rejectAction = "reject"
+const reclaimAction = "reclaim"
+
+// ItemEvent records a transaction that assigned an item to an owner.
+// Containers are the logistic units the item was packed in, outermost first.
+// Issues flag the gaps and broken links of the chain of custody found at the
+// event. Conflicting is set on events that do not continue the chain: a second
+// mint, a transfer that does not spend the output of the previous event or a
+// rejected claim. Reason is the reason code of a decommissioning and OfferedTo
+// the address an offer was made to.
+type ItemEvent struct {
+	BlockHash   []byte
+	Height      int
+	Timestamp   int64
+	TxID        []byte
+	Index       int
+	Sender      string
+	Owner       string
+	Minted      bool
+	Action      string
+	Reason      string
+	OfferedTo   string
+	Containers  []string
+	Issues      []string
+	Conflicting bool
+}
+
+// ItemTrace is the chain of custody of an item. Owner is the current owner
+// according to the UTXO set, empty if the item is not held.
+type ItemTrace struct {
+	Item   string
+	Events []ItemEvent
+	Owner  string
+}
+
+// GetItemHistory returns the transactions that carried an item, oldest first,
+// whether on its own or packed in a logistic unit. Each transfer must spend the
+// output of the previous event and be sent by its owner, under any key its
+// organisation held, by the consumer who claimed it or by the address an offer
+// was made to; otherwise the event is flagged. Rejected claims are listed after the transactions of their block
+// and are not part of the chain. The chain of custody of a logistic unit
+// starts with its packing.
+func (bc *Blockchain) GetItemHistory(item string) []ItemEvent {
+	var events []ItemEvent
+	var inputs [][]TXInput
+	// claim hashes of sales and hashes of the codes of rejected claims, by event
+	var hashes [][]byte
+	orgs := bc.GetOrganisations()
+	rotations := bc.GetKeyRotations()
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		txs := block.Transactions()
+		attempts := block.ClaimAttempts()
+
+		for i := len(attempts) - 1; i >= 0; i-- {
+			if string(attempts[i].Item) == item {
+				event := ItemEvent{block.Hash, block.Height, block.Timestamp, attempts[i].ID, 0, string(GetAddressFromPubKey(attempts[i].PubKey)), "", false, rejectedClaimAction, "", "", nil, nil, true}
+				events = append([]ItemEvent{event}, events...)
+				inputs = append([][]TXInput{nil}, inputs...)
+				hashes = append([][]byte{HashClaimCode(item, string(attempts[i].Code))}, hashes...)
+			}
+		}
+
+		for i := len(txs) - 1; i >= 0; i-- {
+			for _, out := range txs[i].Vout {
+				if containers, ok := out.Carries(item); ok {
+					action := transferAction
+					if !out.IsSpendable() {
+						action = decommissionAction
+					} else if len(out.ClaimHash) > 0 {
+						action = sellAction
+					} else if out.IsOffered() {
+						action = offerAction
+					} else if txs[i].IsClaim() {
+						action = claimAction
+					}
+
+					event := ItemEvent{block.Hash, block.Height, block.Timestamp, txs[i].ID, out.Index, "", string(Base58Encode(out.PubKeyHash)), txs[i].IsCoinbase(), action, string(out.Decommission), "", containers, nil, false}
+					if out.IsOffered() {
+						event.OfferedTo = string(Base58Encode(out.OfferTo))
+					}
+					events = append([]ItemEvent{event}, events...)
+					inputs = append([][]TXInput{txs[i].Vin}, inputs...)
+					hashes = append([][]byte{out.ClaimHash}, hashes...)
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	// index of the last event of the chain of custody, and the claim hash and
+	// claimant of the last sale and claim
+	last := -1
+	var claimHash []byte
+	var claimant string
+
+	for i := range events {
+		event := &events[i]
+
+		if event.Action == rejectedClaimAction {
+			if last >= 0 {
+				event.Owner = events[last].Owner
+			}
+			if bytes.Compare(hashes[i], claimHash) == 0 {
+				event.Issues = append(event.Issues, fmt.Sprintf("Claim rejected: item was already claimed by %s, with a copy of its claim code", claimant))
+			} else {
+				event.Issues = append(event.Issues, fmt.Sprintf("Claim rejected: item was already claimed by %s, with a wrong claim code", claimant))
+			}
+			continue
+		}
+
+		prev := last
+		last = i
+		switch event.Action {
+		case sellAction:
+			claimHash = hashes[i]
+		case claimAction:
+			claimant = event.Owner
+		}
+
+		if event.Minted {
+			event.Action = mintAction
+			if prev >= 0 {
+				event.Issues = append(event.Issues, "Item is minted again")
+				event.Conflicting = true
+			}
+			continue
+		}
+		if len(inputs[i]) > 0 {
+			event.Sender = string(GetAddressFromPubKey(inputs[i][0].PubKey))
+		}
+		if prev < 0 {
+			if _, _, err := ParseSSCC(item); err == nil {
+				event.Action = packAction
+			} else {
+				event.Issues = append(event.Issues, "Chain of custody does not start with a mint")
+			}
+			continue
+		}
+
+		previous := events[prev]
+		switch {
+		case event.Action == decommissionAction:
+			// a unit is decommissioned with the items packed in it
+		case previous.Action == offerAction && event.Action == transferAction:
+			event.Action = answerAction(event, previous, orgs, rotations)
+		case len(event.Containers) > len(previous.Containers):
+			event.Action = packAction
+		case len(event.Containers) < len(previous.Containers):
+			event.Action = unpackAction
+		}
+		linked := false
+		for _, vin := range inputs[i] {
+			if bytes.Compare(vin.Txid, previous.TxID) == 0 && vin.Vout == previous.Index {
+				event.Sender = string(GetAddressFromPubKey(vin.PubKey))
+				linked = true
+			}
+		}
+
+		if !linked {
+			event.Issues = append(event.Issues, fmt.Sprintf("Transfer does not spend output %x:%d of the previous event", previous.TxID, previous.Index))
+			event.Conflicting = true
+		}
+		// a claim is sent by the consumer who gave the claim code, and the
+		// answer to an offer by the address it was made to
+		answered := event.Action == acceptAction || event.Action == rejectAction
+		if event.Action != claimAction && !answered && !sameParty(event.Sender, previous.Owner, orgs, rotations) {
+			event.Issues = append(event.Issues, fmt.Sprintf("Sender %s is not the previous owner %s", event.Sender, previous.Owner))
+		}
+	}
+
+	return events
+}
+
+// answerAction tells whether an event following an offer accepts it, rejects
+// it or reclaims it
+func answerAction(event *ItemEvent, offer ItemEvent, orgs []Organisation, rotations []KeyRotation) string {
+	switch {
+	case !sameParty(event.Sender, offer.OfferedTo, orgs, rotations):
+		return reclaimAction
+	case sameParty(event.Owner, offer.OfferedTo, orgs, rotations):
+		return acceptAction
+	}
+
+	return rejectAction
+}
+
+// TraceItem returns the chain of custody of an item and checks that its last
+// event matches the UTXO set. A logistic unit that is no longer held has been
+// unpacked, and a decommissioned item is no longer held.
+func (bc *Blockchain) TraceItem(item string) ItemTrace {
+	trace := ItemTrace{item, bc.GetItemHistory(item), ""}
+	if len(trace.Events) == 0 {
+		return trace
+	}
+
+	last := trace.LastEvent()
+	held := UTXOSet{bc}.FindItem(item)
+
+	_, _, err := ParseSSCC(item)
+	unit := err == nil
+
+	switch {
+	case last.Action == decommissionAction && len(held) == 0:
+		// the item is out of circulation
+	case last.Action == decommissionAction:
+		last.Issues = append(last.Issues, "Decommissioned item is still held in the UTXO set")
+	case len(held) == 0 && unit:
+		// the unit was unpacked
+	case len(held) == 0:
+		last.Issues = append(last.Issues, "Item is not held by anyone in the UTXO set")
+	case len(held) > 1:
+		last.Issues = append(last.Issues, fmt.Sprintf("Item is held by %d outputs in the UTXO set", len(held)))
+	default:
+		for txID, out := range held {
+			trace.Owner = string(Base58Encode(out.PubKeyHash))
+			if txID != fmt.Sprintf("%x", last.TxID) || out.Index != last.Index {
+				last.Issues = append(last.Issues, fmt.Sprintf("Item is held by output %s:%d instead of the last event", txID, out.Index))
+			}
+		}
+	}
+
+	return trace
+}
+
+// LastEvent returns the last event of the chain of custody, leaving out
+// rejected claims
+func (trace *ItemTrace) LastEvent() *ItemEvent {
+	for i := len(trace.Events) - 1; i > 0; i-- {
+		if trace.Events[i].Action != rejectedClaimAction {
+			return &trace.Events[i]
+		}
+	}
+
+	return &trace.Events[0]
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KeyRotation replaces OldPubKey, the key an organisation holds, with
+// NewPubKey. It is signed by the holder of PubKey, either OldPubKey itself or
+// an Admin, and by the holder of NewPubKey. The organisation keeps its
+// identity, role, catalog and inventory across rotations.
+type KeyRotation struct {
+	ID           []byte
+	OldPubKey    []byte
+	NewPubKey    []byte
+	Timestamp    int64
+	Signature    []byte
+	PubKey       []byte
+	NewSignature []byte
+}
+
+// NewKeyRotation creates a rotation of the key of the organisation holding org
+// to the key of newAddress, signed by the key of address, which must be org or
+// an admin, and by the key of newAddress
+func NewKeyRotation(address, org, newAddress string, bc *Blockchain, nodeID string) (*KeyRotation, error) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("Address %s is not in the wallet of this node", address)
+	}
+	newWallet, ok := wallets.Wallets[newAddress]
+	if !ok {
+		return nil, fmt.Errorf("Address %s is not in the wallet of this node", newAddress)
+	}
+
+	target, err := bc.FindOrganisationByAddress(org)
+	if err != nil {
+		return nil, fmt.Errorf("Organisation %s not found", org)
+	}
+
+	rotation := &KeyRotation{nil, bc.GetCurrentKey(target.PubKey), newWallet.PublicKey, time.Now().Unix(), nil, wallet.PublicKey, nil}
+	rotation.Sign(wallet.PrivateKey, newWallet.PrivateKey)
+
+	err = bc.ValidateKeyRotation(rotation)
+	if err != nil {
+		return nil, err
+	}
+
+	return rotation, nil
+}
+
+// Hash returns the hash of the key rotation, excluding its ID
+func (kr *KeyRotation) Hash() []byte {
+	return HashFields(
+		kr.OldPubKey,
+		kr.NewPubKey,
+		IntToHex(kr.Timestamp),
+		kr.Signature,
+		kr.PubKey,
+		kr.NewSignature,
+	)
+}
+
+// Sign sets the ID of the key rotation and signs it with the key of the signer
+// and the new key
+func (kr *KeyRotation) Sign(privKey, newPrivKey ecdsa.PrivateKey) {
+	kr.Signature = nil
+	kr.NewSignature = nil
+	kr.ID = kr.Hash()
+
+	kr.Signature = signDigest(privKey, kr.ID)
+	kr.NewSignature = signDigest(newPrivKey, kr.ID)
+}
+
+// Verify checks that the ID matches the key rotation and is signed by both its
+// PubKey and its NewPubKey
+func (kr *KeyRotation) Verify() bool {
+	krCopy := *kr
+	krCopy.Signature = nil
+	krCopy.NewSignature = nil
+	if bytes.Compare(krCopy.Hash(), kr.ID) != 0 {
+		return false
+	}
+
+	return verifyDigest(kr.PubKey, kr.ID, kr.Signature) && verifyDigest(kr.NewPubKey, kr.ID, kr.NewSignature)
+}
+
+// String returns a human-readable representation of a key rotation
+func (kr KeyRotation) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Key rotation %x:", kr.ID))
+
+	lines = append(lines, fmt.Sprintf("       From:       %s", GetAddressFromPubKey(kr.OldPubKey)))
+	lines = append(lines, fmt.Sprintf("       To:       %s", GetAddressFromPubKey(kr.NewPubKey)))
+	lines = append(lines, fmt.Sprintf("       Signature: %x", kr.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %x", kr.PubKey))
+	lines = append(lines, fmt.Sprintf("       NewSignature: %x", kr.NewSignature))
+	return strings.Join(lines, "\n")
+}
+
+// GetKeyRotations returns every key rotation in registration order
+func (bc *Blockchain) GetKeyRotations() []KeyRotation {
+	var rotations []KeyRotation
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		blockRotations := block.KeyRotations()
+
+		for i := len(blockRotations) - 1; i >= 0; i-- {
+			rotations = append([]KeyRotation{*blockRotations[i]}, rotations...)
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return rotations
+}
+
+// keysOf returns the keys held by the organisation registered with pubKey
+// according to rotations, oldest first. The last one is its current key.
+func keysOf(rotations []KeyRotation, pubKey []byte) [][]byte {
+	keys := [][]byte{pubKey}
+
+	for _, kr := range rotations {
+		if bytes.Compare(kr.OldPubKey, keys[len(keys)-1]) == 0 {
+			keys = append(keys, kr.NewPubKey)
+		}
+	}
+
+	return keys
+}
+
+// registeredKey returns the key the organisation holding or having held
+// pubKey was registered with according to rotations, or pubKey if it was never
+// rotated to
+func registeredKey(rotations []KeyRotation, pubKey []byte) []byte {
+	for i := len(rotations) - 1; i >= 0; i-- {
+		if bytes.Compare(rotations[i].NewPubKey, pubKey) == 0 {
+			pubKey = rotations[i].OldPubKey
+		}
+	}
+
+	return pubKey
+}
+
+// findOrganisationByAddress returns the organisation of orgs holding or having
+// held address according to rotations
+func findOrganisationByAddress(address string, orgs []Organisation, rotations []KeyRotation) (Organisation, bool) {
+	for _, org := range orgs {
+		for _, key := range keysOf(rotations, org.PubKey) {
+			if string(GetAddressFromPubKey(key)) == address {
+				return org, true
+			}
+		}
+	}
+
+	return Organisation{}, false
+}
+
+// sameParty checks whether two addresses are the same or held by the same
+// organisation of orgs according to rotations
+func sameParty(address, other string, orgs []Organisation, rotations []KeyRotation) bool {
+	if address == other {
+		return true
+	}
+
+	org, ok := findOrganisationByAddress(address, orgs, rotations)
+	if !ok {
+		return false
+	}
+	otherOrg, ok := findOrganisationByAddress(other, orgs, rotations)
+
+	return ok && bytes.Compare(org.ID, otherOrg.ID) == 0
+}
+
+// GetCurrentKey returns the key the organisation registered with pubKey holds
+func (bc *Blockchain) GetCurrentKey(pubKey []byte) []byte {
+	keys := keysOf(bc.GetKeyRotations(), pubKey)
+
+	return keys[len(keys)-1]
+}
+
+// GetAddresses returns every address held by the organisation holding or
+// having held address, oldest first, or address alone if no organisation did
+func (bc *Blockchain) GetAddresses(address string) []string {
+	rotations := bc.GetKeyRotations()
+
+	org, ok := findOrganisationByAddress(address, bc.GetOrganisations(), rotations)
+	if !ok {
+		return []string{address}
+	}
+
+	return addressesOf(keysOf(rotations, org.PubKey))
+}
+
+// addressesOf returns the addresses of keys
+func addressesOf(keys [][]byte) []string {
+	var addresses []string
+
+	for _, key := range keys {
+		addresses = append(addresses, string(GetAddressFromPubKey(key)))
+	}
+
+	return addresses
+}
+
+// containsAddress checks whether address is one of addresses
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetKeyHashes returns the hashes of every key held by the organisation holding
+// or having held the key hashed to pubKeyHash, or pubKeyHash alone if no
+// organisation did
+func (bc *Blockchain) GetKeyHashes(pubKeyHash []byte) [][]byte {
+	rotations := bc.GetKeyRotations()
+
+	for _, org := range bc.GetOrganisations() {
+		var hashes [][]byte
+		held := false
+
+		for _, key := range keysOf(rotations, org.PubKey) {
+			hash := HashPubKey(key)
+			held = held || bytes.Compare(hash, pubKeyHash) == 0
+			hashes = append(hashes, hash)
+		}
+
+		if held {
+			return hashes
+		}
+	}
+
+	return [][]byte{pubKeyHash}
+}
+
+// checkCurrentKey returns an error if pubKey is a key an organisation rotated
+// away from
+func (bc *Blockchain) checkCurrentKey(pubKey []byte) error {
+	org, err := bc.FindOrganisationByPublicKey(pubKey)
+	if err != nil {
+		return nil
+	}
+	if bytes.Compare(bc.GetCurrentKey(org.PubKey), pubKey) != 0 {
+		return fmt.Errorf("Key of organisation %s at %s has been rotated", org.Name, GetAddressFromPubKey(pubKey))
+	}
+
+	return nil
+}
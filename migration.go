@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+// Buckets of the three-chain database format
+const legacyTransactionsBucket = "blocks"
+const legacyProductsBucket = "products"
+const legacyOrganisationBucket = "organisations"
+
+// legacyBlock is a block of the three-chain format, carrying either
+// transactions, products or an organisation
+type legacyBlock struct {
+	Timestamp     int64
+	Transactions  []*Transaction
+	Products      []*Product
+	Organisation  *Organisation
+	PrevBlockHash []byte
+	Hash          []byte
+	Nonce         int
+	Height        int
+}
+
+// Migration records the blocks converted from a database of the three-chain
+// format, one Merkle root of entries per block following the genesis block.
+// Their entries keep the IDs and signatures made with the gob hashes of that
+// format, which depend on the process that computed them and cannot be checked
+// again, so they are trusted as recorded instead of validated. It is recorded
+// in the genesis block and cannot change afterwards.
+type Migration struct {
+	ID     []byte
+	Blocks [][]byte
+}
+
+// NewMigration creates the migration record of the converted blocks, in order
+func NewMigration(blocks []*Block) *Migration {
+	migration := &Migration{}
+
+	for _, block := range blocks {
+		migration.Blocks = append(migration.Blocks, block.HashEntries())
+	}
+	migration.ID = migration.Hash()
+
+	return migration
+}
+
+// Hash returns the hash of the Merkle roots of the converted blocks
+func (m *Migration) Hash() []byte {
+	return HashFields(m.Blocks...)
+}
+
+// String returns a human-readable representation of a migration
+func (m Migration) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Migration %x:", m.ID))
+	lines = append(lines, fmt.Sprintf("       Blocks:          %d", len(m.Blocks)))
+	return strings.Join(lines, "\n")
+}
+
+// migrations caches the migration record of each open database, as the
+// genesis block it is recorded in never changes
+var migrations = make(map[*bolt.DB]*Migration)
+
+// GetMigration returns the migration recorded in the genesis block, or nil
+// if the ledger was not converted from the three-chain format
+func (bc *Blockchain) GetMigration() *Migration {
+	if migration, ok := migrations[bc.db]; ok {
+		return migration
+	}
+
+	var migration *Migration
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		if len(block.PrevBlockHash) == 0 {
+			for _, e := range block.Entries {
+				if e.Type == migrationEntry {
+					migration = e.Migration
+				}
+			}
+			break
+		}
+	}
+
+	migrations[bc.db] = migration
+
+	return migration
+}
+
+// IsMigratedBlock checks whether block is one of the blocks converted from
+// the three-chain format, with the height and entries the migration recorded
+func (bc *Blockchain) IsMigratedBlock(block *Block) bool {
+	migration := bc.GetMigration()
+	if migration == nil || block.Height < 1 || block.Height > len(migration.Blocks) {
+		return false
+	}
+
+	return bytes.Compare(block.HashEntries(), migration.Blocks[block.Height-1]) == 0
+}
+
+// entries converts the payload of a legacy block to ledger entries
+func (b *legacyBlock) entries() []*Entry {
+	if b.Organisation != nil {
+		return []*Entry{NewOrganisationEntry(b.Organisation)}
+	} else if b.Products != nil {
+		return NewProductEntries(b.Products)
+	}
+
+	return NewTransactionEntries(b.Transactions)
+}
+
+// readLegacyChain returns the blocks of a legacy bucket from the first to the tip
+func readLegacyChain(tx *bolt.Tx, bucket string) ([]*legacyBlock, error) {
+	var blocks []*legacyBlock
+
+	b := tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil, fmt.Errorf("Bucket %s is missing", bucket)
+	}
+
+	hash := b.Get([]byte("l"))
+	for len(hash) != 0 {
+		data := b.Get(hash)
+		if data == nil {
+			return nil, fmt.Errorf("Block %x of bucket %s is missing", hash, bucket)
+		}
+
+		block := DeserializeLegacyBlock(data)
+		blocks = append([]*legacyBlock{block}, blocks...)
+		hash = block.PrevBlockHash
+	}
+
+	return blocks, nil
+}
+
+// mergeLegacyChains orders the blocks of the three chains by timestamp. Each
+// chain keeps its own order and on equal timestamps organisations come before
+// products and products before transactions.
+func mergeLegacyChains(chains ...[]*legacyBlock) []*legacyBlock {
+	var merged []*legacyBlock
+
+	for {
+		next := -1
+		for i, chain := range chains {
+			if len(chain) > 0 && (next == -1 || chain[0].Timestamp < chains[next][0].Timestamp) {
+				next = i
+			}
+		}
+
+		if next == -1 {
+			return merged
+		}
+
+		merged = append(merged, chains[next][0])
+		chains[next] = chains[next][1:]
+	}
+}
+
+// checkLegacyEntries checks the structure of the entries converted from a
+// legacy block, whose IDs and signatures cannot be checked: every entry must
+// be well formed, transactions must be new and their inputs must spend
+// unspent outputs of the ledger or of earlier transactions of the block
+func checkLegacyEntries(u UTXOSet, entries []*Entry) error {
+	if len(entries) == 0 {
+		return errors.New("Block has no entries")
+	}
+
+	created := make(map[string]bool)
+	spent := make(map[string]bool)
+
+	for _, e := range entries {
+		if !e.IsWellFormed() {
+			return fmt.Errorf("Entry of type %q is malformed", e.Type)
+		}
+		if e.Type != transactionEntry {
+			continue
+		}
+
+		tx := e.Transaction
+		if u.HasTransaction(tx.ID) || created[fmt.Sprintf("%x:0", tx.ID)] {
+			return fmt.Errorf("Transaction %x is already recorded", tx.ID)
+		}
+
+		if !tx.IsCoinbase() {
+			for _, vin := range tx.Vin {
+				key := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+				_, unspent := u.FindUnspentOutput(vin.Txid, vin.Vout)
+
+				if spent[key] || !(unspent || created[key]) {
+					return fmt.Errorf("Transaction %x spends output %s, which is not unspent", tx.ID, key)
+				}
+				spent[key] = true
+			}
+		}
+
+		for _, out := range tx.Vout {
+			created[fmt.Sprintf("%x:%d", tx.ID, out.Index)] = true
+		}
+	}
+
+	return nil
+}
+
+// MigrateBlockchain converts a database of the three-chain format into a
+// single ledger. The original file is kept as a backup. Blocks are converted
+// in timestamp order with their entries unchanged, and the genesis block
+// records the Merkle root of each of them, so that they are trusted instead
+// of validated. The migration stops at the first block whose entries are not
+// consistent with the ledger built so far, and the backup must then be
+// restored. Converted blocks are mined with the proof of work, so that nodes
+// migrating the same chains derive the same ledger.
+func MigrateBlockchain(nodeID string) {
+	dbFile := fmt.Sprintf(dbFile, nodeID)
+	if dbExists(dbFile) == false {
+		fmt.Println("No existing blockchain found. Create one first.")
+		os.Exit(1)
+	}
+
+	db, err := bolt.Open(dbFile, 0600, nil)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer db.Close()
+
+	var orgs, products, txs []*legacyBlock
+	consensus := powConsensus
+
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(blocksBucket)) != nil {
+			return errors.New("Blockchain is already migrated")
+		}
+		if c := tx.Bucket([]byte(configBucket)); c != nil {
+			consensus = string(c.Get([]byte("consensus")))
+		}
+
+		orgs, err = readLegacyChain(tx, legacyOrganisationBucket)
+		if err != nil {
+			return err
+		}
+		products, err = readLegacyChain(tx, legacyProductsBucket)
+		if err != nil {
+			return err
+		}
+		txs, err = readLegacyChain(tx, legacyTransactionsBucket)
+		if err != nil {
+			return err
+		}
+
+		return tx.CopyFile(dbFile+".bak", 0600)
+	})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(orgs) == 0 {
+		fmt.Println("Organisation chain has no genesis block")
+		os.Exit(1)
+	}
+
+	var blocks []*Block
+	for i, legacy := range mergeLegacyChains(orgs[1:], products, txs) {
+		block := NewBlock(legacy.entries(), nil, i+1)
+		block.Timestamp = legacy.Timestamp
+		blocks = append(blocks, block)
+	}
+
+	genesis := NewBlock(append(orgs[0].entries(), NewMigrationEntry(NewMigration(blocks))), nil, 0)
+	genesis.Timestamp = orgs[0].Timestamp
+	PoWEngine{}.Seal(genesis)
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{legacyTransactionsBucket, legacyProductsBucket, legacyOrganisationBucket, utxoBucket, undoBucket, chainworkBucket, configBucket} {
+			if tx.Bucket([]byte(bucket)) != nil {
+				err := tx.DeleteBucket([]byte(bucket))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err := createLedgerBuckets(tx, consensus)
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket([]byte(blocksBucket))
+		err = b.Put(genesis.Hash, genesis.Serialize())
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte("l"), genesis.Hash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc := &Blockchain{genesis.Hash, db, nil}
+	bc.consensus, err = newConsensusEngine(consensus, bc, nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	UTXOSet := UTXOSet{bc}
+
+	for _, block := range blocks {
+		err := checkLegacyEntries(UTXOSet, block.Entries)
+		if err != nil {
+			fmt.Printf("Block %d cannot be converted, restore %s.bak: %s\n", block.Height, dbFile, err)
+			os.Exit(1)
+		}
+
+		block.PrevBlockHash = bc.tip
+		PoWEngine{}.Seal(block)
+
+		err = bc.AddBlock(block)
+		if err != nil {
+			fmt.Printf("Block %d cannot be converted, restore %s.bak: %s\n", block.Height, dbFile, err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Migrated to a ledger of %d blocks\n", bc.GetBestHeight()+1)
+}
+
+// DeserializeLegacyBlock deserializes a block of the three-chain format
+func DeserializeLegacyBlock(d []byte) *legacyBlock {
+	var block legacyBlock
+
+	decoder := gob.NewDecoder(bytes.NewReader(d))
+	err := decoder.Decode(&block)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return &block
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+// testdata/legacy_blockchain.db is a database of the three-chain format with
+// three organisations, the products soap and tea of Maker, the items 0123.1.1,
+// 0123.2.1 and 0123.1.2 minted by Maker and 0123.1.1 sent to Dist
+func TestMigrateBlockchain(t *testing.T) {
+	for _, consensus := range []string{powConsensus, poaConsensus} {
+		t.Run(consensus, func(t *testing.T) {
+			testMigrateBlockchain(t, consensus)
+		})
+	}
+}
+
+// testMigrateBlockchain migrates the legacy database configured for consensus
+func testMigrateBlockchain(t *testing.T, consensus string) {
+	nodeID := "legacy"
+
+	data, err := ioutil.ReadFile("testdata/legacy_blockchain.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Chdir(wd)
+
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(fmt.Sprintf(dbFile, nodeID), data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = setLegacyConsensus(fmt.Sprintf(dbFile, nodeID), consensus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MigrateBlockchain(nodeID)
+
+	if !dbExists(fmt.Sprintf(dbFile, nodeID) + ".bak") {
+		t.Error("backup of the legacy database is missing")
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	if height := bc.GetBestHeight(); height != 6 {
+		t.Errorf("best height is %d, expected 6", height)
+	}
+
+	migration := bc.GetMigration()
+	if migration == nil || len(migration.Blocks) != 6 {
+		t.Fatalf("genesis block records migration %v, expected 6 blocks", migration)
+	}
+
+	if orgs := bc.GetOrganisations(); len(orgs) != 3 {
+		t.Errorf("%d organisations were migrated, expected 3", len(orgs))
+	}
+
+	maker, err := bc.FindOrganisationByAddress("1rQsMy3HN9RKgZTQnaQu4DfuT8nN4LyKQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []int{1, 2} {
+		if _, err := bc.FindCatalogProduct("1rQsMy3HN9RKgZTQnaQu4DfuT8nN4LyKQ", code); err != nil {
+			t.Errorf("product %d of %s was not migrated: %s", code, maker.Name, err)
+		}
+	}
+
+	owners := map[string]string{
+		"0123.1.1": "178WT4eYTscLSveK2ucr8wWze6M9iWJoKz",
+		"0123.2.1": "1rQsMy3HN9RKgZTQnaQu4DfuT8nN4LyKQ",
+		"0123.1.2": "1rQsMy3HN9RKgZTQnaQu4DfuT8nN4LyKQ",
+	}
+	for item, owner := range owners {
+		verification := bc.VerifyItem(item)
+		if !verification.Genuine() {
+			t.Errorf("%s is not genuine: %v", item, verification.Checks)
+		}
+		if verification.Owner != owner {
+			t.Errorf("%s is held by %q, expected %s", item, verification.Owner, owner)
+		}
+	}
+
+	// peers receiving the converted blocks accept their proof of work
+	for _, hash := range bc.GetBlockHashes() {
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(block.PrevBlockHash) == 0 {
+			continue
+		}
+
+		if err := bc.ValidateBlock(&block); err != nil {
+			t.Errorf("block %d is rejected: %s", block.Height, err)
+		}
+		if err := bc.verifySeal(&block); err != nil {
+			t.Errorf("seal of block %d is invalid: %s", block.Height, err)
+		}
+	}
+}
+
+// setLegacyConsensus selects the consensus engine of a legacy database
+func setLegacyConsensus(dbFile, consensus string) error {
+	db, err := bolt.Open(dbFile, 0600, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		c, err := tx.CreateBucketIfNotExists([]byte(configBucket))
+		if err != nil {
+			return err
+		}
+
+		return c.Put([]byte("consensus"), []byte(consensus))
+	})
+}
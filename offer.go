@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// NewOfferTransaction creates the offer of items held by from to the address
+// to. The items stay with from until to accepts or rejects them, or until from
+// reclaims them after height expiry, if not 0.
+func NewOfferTransaction(wallet Wallet, from, to string, items []string, expiry int, UTXOSet *UTXOSet) (*Transaction, error) {
+	inputs, spent, err := UTXOSet.findOutputsOf(wallet, items)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := Transaction{nil, inputs, nil}
+	for i, out := range spent {
+		output := NewTXOutput(i, out.Item, from)
+		output.Contents = out.Contents
+		output.OfferTo = Base58Decode([]byte(to))
+		output.OfferExpiry = expiry
+		tx.Vout = append(tx.Vout, *output)
+	}
+
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+
+	return &tx, nil
+}
+
+// NewOfferAnswerTransaction creates the answer of address to the offers of
+// items: action is acceptAction or rejectAction for the address they are
+// offered to, and reclaimAction for the address that offered them. Accepted
+// items go to address, the others back to the sender.
+func NewOfferAnswerTransaction(wallet Wallet, address string, items []string, action string, UTXOSet *UTXOSet) (*Transaction, error) {
+	var inputs []TXInput
+	var outputs []TXOutput
+	pubKeyHashes := UTXOSet.Blockchain.GetKeyHashes(HashPubKey(wallet.PublicKey))
+
+	for _, item := range items {
+		found := false
+
+		for txid, out := range UTXOSet.FindItem(item) {
+			if out.Item != item || !out.IsOffered() {
+				continue
+			}
+
+			sender := string(Base58Encode(out.PubKeyHash))
+			if action == reclaimAction && !out.IsLockedWithAnyKey(pubKeyHashes) {
+				return nil, fmt.Errorf("Item %s was not offered by %s", item, address)
+			}
+			if action != reclaimAction && !out.IsOfferedToAnyKey(pubKeyHashes) {
+				return nil, fmt.Errorf("Item %s is not offered to %s", item, address)
+			}
+
+			txID, err := hex.DecodeString(txid)
+			if err != nil {
+				log.Panic(err)
+			}
+			inputs = append(inputs, TXInput{txID, out.Index, nil, wallet.PublicKey, nil})
+
+			to := sender
+			if action == acceptAction {
+				to = address
+			}
+			output := NewTXOutput(len(outputs), item, to)
+			output.Contents = out.Contents
+			outputs = append(outputs, *output)
+			found = true
+		}
+
+		if !found {
+			return nil, fmt.Errorf("Item %s is not offered", item)
+		}
+	}
+
+	tx := Transaction{nil, inputs, outputs}
+	tx.ID = tx.Hash()
+	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+
+	return &tx, nil
+}
+
+// IsOfferExpired checks whether an offer can no longer be answered in the next
+// block, and so may be reclaimed by its sender
+func (bc *Blockchain) IsOfferExpired(out TXOutput) bool {
+	return out.OfferExpiry > 0 && bc.GetBestHeight()+1 > out.OfferExpiry
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestOffers(t *testing.T) {
+	// parties are the organisations registered on the ledger
+	type parties struct {
+		maker, dist, shop *Wallet
+	}
+
+	tests := []struct {
+		name string
+		// offer returns the transaction to validate, given the parties where
+		// the manufacturer holds 0123.1.1
+		offer func(c *testChain, p parties) (*Transaction, error)
+		valid bool
+	}{
+		{"offer", func(c *testChain, p parties) (*Transaction, error) {
+			return NewOfferTransaction(*p.maker, addressOf(p.maker), addressOf(p.dist), []string{"0123.1.1"}, 0, &UTXOSet{c.bc})
+		}, true},
+		{"offer to its sender", func(c *testChain, p parties) (*Transaction, error) {
+			return NewOfferTransaction(*p.maker, addressOf(p.maker), addressOf(p.maker), []string{"0123.1.1"}, 0, &UTXOSet{c.bc})
+		}, false},
+		{"offer expiring before the next block", func(c *testChain, p parties) (*Transaction, error) {
+			return NewOfferTransaction(*p.maker, addressOf(p.maker), addressOf(p.dist), []string{"0123.1.1"}, c.bc.GetBestHeight(), &UTXOSet{c.bc})
+		}, false},
+		{"accept", func(c *testChain, p parties) (*Transaction, error) {
+			c.offer(p.maker, p.dist, 0, "0123.1.1")
+			return NewOfferAnswerTransaction(*p.dist, addressOf(p.dist), []string{"0123.1.1"}, acceptAction, &UTXOSet{c.bc})
+		}, true},
+		{"reject", func(c *testChain, p parties) (*Transaction, error) {
+			c.offer(p.maker, p.dist, 0, "0123.1.1")
+			return NewOfferAnswerTransaction(*p.dist, addressOf(p.dist), []string{"0123.1.1"}, rejectAction, &UTXOSet{c.bc})
+		}, true},
+		{"accept sending the item elsewhere", func(c *testChain, p parties) (*Transaction, error) {
+			c.offer(p.maker, p.dist, 0, "0123.1.1")
+			tx, err := NewOfferAnswerTransaction(*p.dist, addressOf(p.dist), []string{"0123.1.1"}, acceptAction, &UTXOSet{c.bc})
+			if err != nil {
+				return nil, err
+			}
+			tx.Vout[0] = *NewTXOutput(0, "0123.1.1", addressOf(p.shop))
+			c.resign(tx, p.dist)
+			return tx, nil
+		}, false},
+		{"reclaim without expiry", func(c *testChain, p parties) (*Transaction, error) {
+			c.offer(p.maker, p.dist, 0, "0123.1.1")
+			return NewOfferAnswerTransaction(*p.maker, addressOf(p.maker), []string{"0123.1.1"}, reclaimAction, &UTXOSet{c.bc})
+		}, false},
+		{"reclaim before expiry", func(c *testChain, p parties) (*Transaction, error) {
+			c.offer(p.maker, p.dist, c.bc.GetBestHeight()+2, "0123.1.1")
+			return NewOfferAnswerTransaction(*p.maker, addressOf(p.maker), []string{"0123.1.1"}, reclaimAction, &UTXOSet{c.bc})
+		}, false},
+		{"reclaim after expiry", func(c *testChain, p parties) (*Transaction, error) {
+			c.offer(p.maker, p.dist, c.bc.GetBestHeight()+1, "0123.1.1")
+			c.mint(p.maker, "0123.1.2")
+			return NewOfferAnswerTransaction(*p.maker, addressOf(p.maker), []string{"0123.1.1"}, reclaimAction, &UTXOSet{c.bc})
+		}, true},
+		{"accept after expiry", func(c *testChain, p parties) (*Transaction, error) {
+			c.offer(p.maker, p.dist, c.bc.GetBestHeight()+1, "0123.1.1")
+			c.mint(p.maker, "0123.1.2")
+			return NewOfferAnswerTransaction(*p.dist, addressOf(p.dist), []string{"0123.1.1"}, acceptAction, &UTXOSet{c.bc})
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestChain(t, powConsensus, nil)
+			p := parties{
+				c.register("Maker", "0123", "Manufacturer"),
+				c.register("Dist", "0124", "Distributor"),
+				c.register("Shop", "0125", "Retailer"),
+			}
+			c.addProducts(p.maker, "soap")
+			c.mint(p.maker, "0123.1.1")
+
+			tx, err := test.offer(c, p)
+			if err == nil {
+				err = c.mine(NewTransactionEntries([]*Transaction{tx})...)
+			}
+
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("offer returned %v, expected valid %t", err, test.valid)
+			}
+		})
+	}
+}
+
+// offer records the offer of items held by from to the holder of to, until
+// height expiry if not 0
+func (c *testChain) offer(from, to *Wallet, expiry int, items ...string) {
+	c.t.Helper()
+
+	tx, err := NewOfferTransaction(*from, addressOf(from), addressOf(to), items, expiry, &UTXOSet{c.bc})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	c.mustMine(NewTransactionEntries([]*Transaction{tx})...)
+}
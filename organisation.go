@@ -3,15 +3,11 @@ package main
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
-	"math/big"
 	"strings"
 )
 
@@ -40,16 +36,9 @@ func (org Organisation) Serialize() []byte {
 	return encoded.Bytes()
 }
 
-// Hash returns the hash of the Organisation
+// Hash returns the hash of the Organisation, excluding its ID
 func (org *Organisation) Hash() []byte {
-	var hash [32]byte
-
-	o := *org
-	o.ID = []byte{}
-
-	hash = sha256.Sum256(o.Serialize())
-
-	return hash[:]
+	return HashFields(org.Name, org.GSTIN, org.Prefix, org.Role, org.Signature, org.PubKey, org.AdminPubKey)
 }
 
 // Deserialize deserializes Organisation
@@ -74,13 +63,7 @@ func (org *Organisation) Sign(privKey ecdsa.PrivateKey, pubKeyHash []byte) {
 	oCopy.ID = oCopy.Hash()
 	oCopy.AdminPubKey = nil
 
-	r, s, err := ecdsa.Sign(rand.Reader, &privKey, oCopy.ID)
-	if err != nil {
-		log.Panic(err)
-	}
-	signature := append(r.Bytes(), s.Bytes()...)
-
-	org.Signature = signature
+	org.Signature = signDigest(privKey, oCopy.ID)
 }
 
 // String returns a human-readable representation of a organisation
@@ -109,7 +92,6 @@ func (org *Organisation) Copy() Organisation {
 
 // Verifies a Organisation
 func (org *Organisation) Verify(pubKeyHash []byte) bool {
-	curve := elliptic.P256()
 	oCopy := org.Copy()
 
 	oCopy.Signature = nil
@@ -117,30 +99,13 @@ func (org *Organisation) Verify(pubKeyHash []byte) bool {
 	oCopy.ID = oCopy.Hash()
 	oCopy.AdminPubKey = nil
 
-	r := big.Int{}
-	s := big.Int{}
-	sigLen := len(org.Signature)
-	r.SetBytes(org.Signature[:(sigLen / 2)])
-	s.SetBytes(org.Signature[(sigLen / 2):])
-
-	x := big.Int{}
-	y := big.Int{}
-	keyLen := len(org.AdminPubKey)
-	x.SetBytes(org.AdminPubKey[:(keyLen / 2)])
-	y.SetBytes(org.AdminPubKey[(keyLen / 2):])
-
-	rawPubKey := ecdsa.PublicKey{curve, &x, &y}
-	if ecdsa.Verify(&rawPubKey, oCopy.ID, &r, &s) == false {
-		return false
-	}
-
-	return true
+	return verifyDigest(org.AdminPubKey, oCopy.ID, org.Signature)
 }
 
 // NewOrganisation creates a new organisation
-func NewOrganisation(address, name, pubKey, gstin, prefix, role string, OrganisationCache *OrganisationCacheSet) (*Organisation, error) {
+func NewOrganisation(address, name, pubKey, gstin, prefix, role string, OrganisationCache *OrganisationCacheSet, nodeID string) (*Organisation, error) {
 
-	wallets, err := NewWallets()
+	wallets, err := NewWallets(nodeID)
 
 	if err != nil {
 		log.Panic(err)
@@ -20,7 +20,7 @@ func (p OrganisationCacheSet) Reindex() {
 }
 
 // Update updates the OrganisationCache set with organisation from the Block
-// The Block is considered to be the tip3 of a blockchain
+// The Block is considered to be the tip of a blockchain
 func (p OrganisationCacheSet) Update(block *Block) {
 
 }
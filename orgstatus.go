@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Statuses of an organisation
+const activeStatus = "active"
+const suspendedStatus = "suspended"
+const revokedStatus = "revoked"
+
+// Admin actions changing the status of an organisation
+const suspendAction = "suspend"
+const reinstateAction = "reinstate"
+const revokeAction = "revoke"
+
+// statusOf maps each action to the status it sets
+var statusOf = map[string]string{
+	suspendAction:   suspendedStatus,
+	reinstateAction: activeStatus,
+	revokeAction:    revokedStatus,
+}
+
+// StatusChange is an action signed by an Admin suspending, reinstating or
+// permanently revoking the organisation holding OrgPubKey. It takes effect at
+// block height Height. Revocation cannot be undone.
+type StatusChange struct {
+	ID        []byte
+	OrgPubKey []byte
+	Action    []byte
+	Reason    []byte
+	Height    int
+	Timestamp int64
+	Signature []byte
+	PubKey    []byte
+}
+
+// NewStatusChange creates a status change of the organisation holding org,
+// signed by the admin holding address. A height of 0 takes effect at the next
+// block.
+func NewStatusChange(address, org, action, reason string, height int, bc *Blockchain, nodeID string) (*StatusChange, error) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("Address %s is not in the wallet of this node", address)
+	}
+
+	target, err := bc.FindOrganisationByAddress(org)
+	if err != nil {
+		return nil, fmt.Errorf("Organisation %s not found", org)
+	}
+
+	if height == 0 {
+		height = bc.GetBestHeight() + 1
+	}
+
+	change := &StatusChange{nil, target.PubKey, []byte(action), []byte(reason), height, time.Now().Unix(), nil, wallet.PublicKey}
+	change.Sign(wallet.PrivateKey)
+
+	err = bc.ValidateStatusChange(change)
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// Hash returns the hash of the status change, excluding its ID
+func (c *StatusChange) Hash() []byte {
+	return HashFields(
+		c.OrgPubKey,
+		c.Action,
+		c.Reason,
+		IntToHex(int64(c.Height)),
+		IntToHex(c.Timestamp),
+		c.Signature,
+		c.PubKey,
+	)
+}
+
+// Sign sets the ID of the status change and signs it
+func (c *StatusChange) Sign(privKey ecdsa.PrivateKey) {
+	c.Signature = nil
+	c.ID = c.Hash()
+
+	c.Signature = signDigest(privKey, c.ID)
+}
+
+// Verify checks that the ID matches the status change and is signed by its PubKey
+func (c *StatusChange) Verify() bool {
+	cCopy := *c
+	cCopy.Signature = nil
+	if bytes.Compare(cCopy.Hash(), c.ID) != 0 {
+		return false
+	}
+
+	return verifyDigest(c.PubKey, c.ID, c.Signature)
+}
+
+// String returns a human-readable representation of a status change
+func (c StatusChange) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Status change %x:", c.ID))
+
+	lines = append(lines, fmt.Sprintf("       Organisation:       %s", GetAddressFromPubKey(c.OrgPubKey)))
+	lines = append(lines, fmt.Sprintf("       Action:       %s", c.Action))
+	lines = append(lines, fmt.Sprintf("       Reason:       %s", c.Reason))
+	lines = append(lines, fmt.Sprintf("       Height:       %d", c.Height))
+	lines = append(lines, fmt.Sprintf("       Signature: %x", c.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %x", c.PubKey))
+	return strings.Join(lines, "\n")
+}
+
+// GetStatusChanges returns every status change in registration order
+func (bc *Blockchain) GetStatusChanges() []StatusChange {
+	var changes []StatusChange
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		blockChanges := block.StatusChanges()
+
+		for i := len(blockChanges) - 1; i >= 0; i-- {
+			changes = append([]StatusChange{*blockChanges[i]}, changes...)
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return changes
+}
+
+// findStatus returns the status of the organisation holding pubKey at block
+// height height, and the change of changes that set it, or nil if it was never
+// changed
+func findStatus(changes []StatusChange, pubKey []byte, height int) (string, *StatusChange) {
+	status := activeStatus
+	var last *StatusChange
+
+	for i := range changes {
+		if bytes.Compare(changes[i].OrgPubKey, pubKey) == 0 && changes[i].Height <= height {
+			status = statusOf[string(changes[i].Action)]
+			last = &changes[i]
+		}
+	}
+
+	return status, last
+}
+
+// GetOrganisationStatus returns the status of the organisation holding or
+// having held pubKey for the next block, and the change that set it, if any
+func (bc *Blockchain) GetOrganisationStatus(pubKey []byte) (string, *StatusChange) {
+	return findStatus(bc.GetStatusChanges(), registeredKey(bc.GetKeyRotations(), pubKey), bc.GetBestHeight()+1)
+}
+
+// CheckActive returns an error if pubKey is a key its organisation rotated
+// away from, or if that organisation is suspended or revoked for the next
+// block. Keys of no organisation are active.
+func (bc *Blockchain) CheckActive(pubKey []byte) error {
+	err := bc.checkCurrentKey(pubKey)
+	if err != nil {
+		return err
+	}
+
+	status, change := bc.GetOrganisationStatus(pubKey)
+	if status == activeStatus {
+		return nil
+	}
+
+	org, err := bc.FindOrganisationByPublicKey(pubKey)
+	if err != nil {
+		return errors.New("Organisation not found")
+	}
+
+	return fmt.Errorf("Organisation %s is %s: %s", org.Name, status, change.Reason)
+}
@@ -0,0 +1,566 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Output formats of the CLI, selected with -output
+const textOutput = "text"
+const jsonOutput = "json"
+const csvOutput = "csv"
+
+// Exit codes of the CLI
+const exitFailure = 1
+const exitUsage = 2
+
+// csvTable is implemented by the results the CLI can print as CSV
+type csvTable interface {
+	csvHeader() []string
+	csvRecords() [][]string
+}
+
+// print writes the result of a command in the selected output format. The
+// text output is left to text.
+func (cli *CLI) print(result csvTable, text func()) {
+	switch cli.output {
+	case jsonOutput:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(result)
+		if err != nil {
+			cli.exit(exitFailure, err.Error())
+		}
+	case csvOutput:
+		writer := csv.NewWriter(os.Stdout)
+		writer.Write(result.csvHeader())
+
+		err := writer.WriteAll(result.csvRecords())
+		if err != nil {
+			cli.exit(exitFailure, err.Error())
+		}
+	default:
+		text()
+	}
+}
+
+// exit reports an error on stderr, as JSON with the JSON output, and exits with code
+func (cli *CLI) exit(code int, message string) {
+	if cli.output == jsonOutput {
+		json.NewEncoder(os.Stderr).Encode(apiError{message})
+	} else {
+		fmt.Fprintln(os.Stderr, message)
+	}
+
+	os.Exit(code)
+}
+
+// check exits with exitFailure if err is set
+func (cli *CLI) check(err error) {
+	if err != nil {
+		cli.exit(exitFailure, err.Error())
+	}
+}
+
+func (view BlockView) csvHeader() []string {
+	return []string{"hash", "prev_hash", "height", "timestamp", "nonce", "validator", "sealed", "entries"}
+}
+
+func (view BlockView) csvRecords() [][]string {
+	return [][]string{{
+		view.Hash,
+		view.PrevHash,
+		strconv.Itoa(view.Height),
+		strconv.FormatInt(view.Timestamp, 10),
+		strconv.Itoa(view.Nonce),
+		view.Validator,
+		strconv.FormatBool(view.Sealed),
+		strconv.Itoa(len(view.Entries)),
+	}}
+}
+
+func (views BlockViews) csvHeader() []string {
+	return BlockView{}.csvHeader()
+}
+
+func (views BlockViews) csvRecords() [][]string {
+	var records [][]string
+	for _, view := range views {
+		records = append(records, view.csvRecords()...)
+	}
+
+	return records
+}
+
+// A transaction is written as one record per output
+func (view TransactionView) csvHeader() []string {
+	return []string{"txid", "coinbase", "index", "item", "address"}
+}
+
+func (view TransactionView) csvRecords() [][]string {
+	var records [][]string
+	for _, out := range view.Outputs {
+		records = append(records, []string{view.ID, strconv.FormatBool(view.Coinbase), strconv.Itoa(out.Index), out.Item, out.Address})
+	}
+
+	return records
+}
+
+func (views TransactionViews) csvHeader() []string {
+	return TransactionView{}.csvHeader()
+}
+
+func (views TransactionViews) csvRecords() [][]string {
+	var records [][]string
+	for _, view := range views {
+		records = append(records, view.csvRecords()...)
+	}
+
+	return records
+}
+
+func (view OutputView) csvRecord() []string {
+	return []string{strconv.Itoa(view.Index), view.Item, view.Address, strconv.FormatBool(view.Recalled)}
+}
+
+// batchRecord writes the production data of an item, empty fields if it has none
+func batchRecord(view *BatchView) []string {
+	if view == nil {
+		return []string{"", "", "", ""}
+	}
+
+	return []string{view.Lot, view.Manufactured, view.Expiry, view.Status}
+}
+
+func (view InventoryView) csvHeader() []string {
+	return []string{"index", "item", "address", "recalled", "container", "lot", "manufactured", "expiry", "expiry_status", "offer", "offer_to", "offer_expiry"}
+}
+
+// The items packed in a logistic unit follow it, with the unit as container.
+// Incoming and outgoing offers follow the items, with offer set to incoming or
+// outgoing and address to the sender.
+func (view InventoryView) csvRecords() [][]string {
+	var records [][]string
+	for _, list := range []struct {
+		offer string
+		outs  []OutputView
+	}{{"", view.Items}, {"incoming", view.Incoming}, {"outgoing", view.Outgoing}} {
+		for _, out := range list.outs {
+			offer := []string{list.offer, out.OfferTo, ""}
+			if out.OfferExpiry > 0 {
+				offer[2] = strconv.Itoa(out.OfferExpiry)
+			}
+
+			record := append(append(out.csvRecord(), ""), batchRecord(out.Batch)...)
+			records = append(records, append(record, offer...))
+			for _, packed := range packedRecords(out, out.Item, out.Contents) {
+				records = append(records, append(packed, offer...))
+			}
+		}
+	}
+
+	return records
+}
+
+func packedRecords(out OutputView, container string, contents []PackedItemView) [][]string {
+	var records [][]string
+	for _, packed := range contents {
+		record := []string{strconv.Itoa(out.Index), packed.Item, out.Address, strconv.FormatBool(packed.Recalled), container}
+		records = append(records, append(record, batchRecord(packed.Batch)...))
+		records = append(records, packedRecords(out, packed.Item, packed.Contents)...)
+	}
+
+	return records
+}
+
+func (view LotView) csvHeader() []string {
+	return []string{"lot", "item", "owner", "containers", "recalled", "manufactured", "expiry", "expiry_status"}
+}
+
+// Containers are separated by spaces
+func (view LotView) csvRecords() [][]string {
+	var records [][]string
+	for _, entry := range view.Items {
+		records = append(records, []string{
+			view.Lot,
+			entry.Item,
+			entry.Owner,
+			strings.Join(entry.Containers, " "),
+			strconv.FormatBool(entry.Recalled),
+			entry.Batch.Manufactured,
+			entry.Batch.Expiry,
+			entry.Batch.Status,
+		})
+	}
+
+	return records
+}
+
+// An EPCIS document is written as one record per event
+func (doc EPCISDocument) csvHeader() []string {
+	return []string{"type", "event_time", "action", "biz_step", "disposition", "epcs", "parent_id", "source", "destination"}
+}
+
+func (doc EPCISDocument) csvRecords() [][]string {
+	var records [][]string
+	for _, event := range doc.Body.EventList {
+		epcs := event.EPCList
+		if event.Type == aggregationEvent {
+			epcs = event.ChildEPCs
+		}
+
+		records = append(records, []string{
+			event.Type,
+			event.EventTime,
+			event.Action,
+			event.BizStep,
+			event.Disposition,
+			strings.Join(epcs, " "),
+			event.ParentID,
+			event.source(),
+			event.destination(),
+		})
+	}
+
+	return records
+}
+
+func (view ProductView) csvHeader() []string {
+	return []string{"id", "code", "name", "manufacturer", "pubkey", "signature", "version", "status", "gtin", "description", "brand", "category", "hs_code", "net_quantity", "unit", "spec_hash"}
+}
+
+func (view ProductView) csvRecords() [][]string {
+	attributes := view.Attributes
+	if attributes == nil {
+		attributes = &ProductAttributesView{}
+	}
+
+	return [][]string{{
+		view.ID,
+		strconv.Itoa(view.Code),
+		view.Name,
+		view.Manufacturer,
+		view.PubKey,
+		view.Signature,
+		strconv.Itoa(view.Version),
+		view.Status,
+		attributes.GTIN,
+		attributes.Description,
+		attributes.Brand,
+		attributes.Category,
+		attributes.HSCode,
+		attributes.NetQuantity,
+		attributes.Unit,
+		attributes.SpecHash,
+	}}
+}
+
+func (views ProductViews) csvHeader() []string {
+	return ProductView{}.csvHeader()
+}
+
+func (views ProductViews) csvRecords() [][]string {
+	var records [][]string
+	for _, view := range views {
+		records = append(records, view.csvRecords()...)
+	}
+
+	return records
+}
+
+func (view OrganisationView) csvHeader() []string {
+	return []string{"id", "name", "gstin", "prefix", "role", "address", "pubkey", "admin_pubkey", "signature", "status", "current_address"}
+}
+
+func (view OrganisationView) csvRecords() [][]string {
+	return [][]string{{view.ID, view.Name, view.GSTIN, view.Prefix, view.Role, view.Address, view.PubKey, view.AdminPubKey, view.Signature, view.Status, view.CurrentAddress}}
+}
+
+func (views OrganisationViews) csvHeader() []string {
+	return OrganisationView{}.csvHeader()
+}
+
+func (views OrganisationViews) csvRecords() [][]string {
+	var records [][]string
+	for _, view := range views {
+		records = append(records, view.csvRecords()...)
+	}
+
+	return records
+}
+
+func (view ItemVerificationView) csvHeader() []string {
+	return []string{"item", "genuine", "conflicts", "check", "passed", "detail"}
+}
+
+func (view ItemVerificationView) csvRecords() [][]string {
+	var records [][]string
+	for _, check := range view.Checks {
+		records = append(records, []string{
+			view.Item,
+			strconv.FormatBool(view.Genuine),
+			strconv.Itoa(view.Conflicts),
+			check.Name,
+			strconv.FormatBool(check.Passed),
+			check.Detail,
+		})
+	}
+
+	return records
+}
+
+func (view ItemHistoryView) csvHeader() []string {
+	return []string{"item", "block", "height", "timestamp", "txid", "owner", "minted", "recalled", "index", "sender", "sender_organisation", "owner_organisation", "owner_role", "issues", "action", "containers", "reason", "offered_to"}
+}
+
+// Issues of an event are separated by semicolons, containers by spaces
+func (view ItemHistoryView) csvRecords() [][]string {
+	var records [][]string
+	for _, event := range view.Events {
+		var sender, senderOrganisation, offeredTo string
+		if event.Sender != nil {
+			sender = event.Sender.Address
+			senderOrganisation = event.Sender.Organisation
+		}
+		if event.OfferedTo != nil {
+			offeredTo = event.OfferedTo.Address
+		}
+
+		records = append(records, []string{
+			view.Item,
+			event.Block,
+			strconv.Itoa(event.Height),
+			strconv.FormatInt(event.Timestamp, 10),
+			event.Txid,
+			event.Owner,
+			strconv.FormatBool(event.Minted),
+			strconv.FormatBool(view.Recall != nil),
+			strconv.Itoa(event.Index),
+			sender,
+			senderOrganisation,
+			event.Receiver.Organisation,
+			event.Receiver.Role,
+			strings.Join(event.Issues, "; "),
+			event.Action,
+			strings.Join(event.Containers, " "),
+			event.Reason,
+			offeredTo,
+		})
+	}
+
+	return records
+}
+
+func (view StatusChangeView) csvHeader() []string {
+	return []string{"id", "organisation", "admin", "action", "reason", "height", "timestamp", "pubkey", "signature"}
+}
+
+func (view StatusChangeView) csvRecords() [][]string {
+	return [][]string{{
+		view.ID,
+		view.Organisation,
+		view.Admin,
+		view.Action,
+		view.Reason,
+		strconv.Itoa(view.Height),
+		strconv.FormatInt(view.Timestamp, 10),
+		view.PubKey,
+		view.Signature,
+	}}
+}
+
+func (view KeyRotationView) csvHeader() []string {
+	return []string{"id", "from", "to", "signer", "timestamp", "pubkey", "signature", "new_pubkey", "new_signature"}
+}
+
+func (view KeyRotationView) csvRecords() [][]string {
+	return [][]string{{
+		view.ID,
+		view.From,
+		view.To,
+		view.Signer,
+		strconv.FormatInt(view.Timestamp, 10),
+		view.PubKey,
+		view.Signature,
+		view.NewPubKey,
+		view.NewSignature,
+	}}
+}
+
+func (view RoleChangeView) csvHeader() []string {
+	return []string{"id", "organisation", "admin", "role", "timestamp", "pubkey", "signature"}
+}
+
+func (view RoleChangeView) csvRecords() [][]string {
+	return [][]string{{
+		view.ID,
+		view.Organisation,
+		view.Admin,
+		view.Role,
+		strconv.FormatInt(view.Timestamp, 10),
+		view.PubKey,
+		view.Signature,
+	}}
+}
+
+func (view CatalogUpdateView) csvHeader() []string {
+	return []string{"id", "manufacturer", "prefix", "code", "version", "action", "name", "reason", "timestamp", "pubkey", "signature"}
+}
+
+func (view CatalogUpdateView) csvRecords() [][]string {
+	return [][]string{{
+		view.ID,
+		view.Manufacturer,
+		view.Prefix,
+		strconv.Itoa(view.Code),
+		strconv.Itoa(view.Version),
+		view.Action,
+		view.Name,
+		view.Reason,
+		strconv.FormatInt(view.Timestamp, 10),
+		view.PubKey,
+		view.Signature,
+	}}
+}
+
+func (view ProposalView) csvHeader() []string {
+	return []string{"id", "type", "organisation", "proposer", "approvers", "required", "recorded"}
+}
+
+// Approvers are separated by spaces
+func (view ProposalView) csvRecords() [][]string {
+	return [][]string{{
+		view.ID,
+		view.Type,
+		view.Organisation,
+		view.Proposer,
+		strings.Join(view.Approvers, " "),
+		strconv.Itoa(view.Required),
+		strconv.FormatBool(view.Recorded),
+	}}
+}
+
+func (views ProposalViews) csvHeader() []string {
+	return ProposalView{}.csvHeader()
+}
+
+func (views ProposalViews) csvRecords() [][]string {
+	var records [][]string
+	for _, view := range views {
+		records = append(records, view.csvRecords()...)
+	}
+
+	return records
+}
+
+func (view RecallView) csvHeader() []string {
+	return []string{"id", "manufacturer", "prefix", "code", "items", "from_serial", "to_serial", "reason", "timestamp", "pubkey", "signature"}
+}
+
+// Listed items are separated by spaces
+func (view RecallView) csvRecords() [][]string {
+	return [][]string{{
+		view.ID,
+		view.Manufacturer,
+		view.Prefix,
+		strconv.Itoa(view.Code),
+		strings.Join(view.Items, " "),
+		strconv.Itoa(view.FromSerial),
+		strconv.Itoa(view.ToSerial),
+		view.Reason,
+		strconv.FormatInt(view.Timestamp, 10),
+		view.PubKey,
+		view.Signature,
+	}}
+}
+
+func (views RecallViews) csvHeader() []string {
+	return RecallView{}.csvHeader()
+}
+
+func (views RecallViews) csvRecords() [][]string {
+	var records [][]string
+	for _, view := range views {
+		records = append(records, view.csvRecords()...)
+	}
+
+	return records
+}
+
+// The holders report is written as one record per recalled item
+func (views RecallHolderViews) csvHeader() []string {
+	return []string{"address", "item"}
+}
+
+func (views RecallHolderViews) csvRecords() [][]string {
+	var records [][]string
+	for _, view := range views {
+		for _, item := range view.Items {
+			records = append(records, []string{view.Address, item})
+		}
+	}
+
+	return records
+}
+
+// A transfer policy is written as one record per rule
+// A sale is written as one record per item, with its claim code
+func (view SaleView) csvHeader() []string {
+	return []string{"txid", "item", "code"}
+}
+
+func (view SaleView) csvRecords() [][]string {
+	var records [][]string
+	for _, code := range view.Codes {
+		records = append(records, []string{view.Transaction.ID, code.Item, code.Code})
+	}
+
+	return records
+}
+
+func (view PolicyView) csvHeader() []string {
+	return []string{"from", "to"}
+}
+
+func (view PolicyView) csvRecords() [][]string {
+	var records [][]string
+	for _, rule := range view.Rules {
+		records = append(records, []string{rule.From, rule.To})
+	}
+
+	return records
+}
+
+func (view AddressView) csvHeader() []string {
+	return []string{"address", "pubkey"}
+}
+
+func (view AddressView) csvRecords() [][]string {
+	return [][]string{{view.Address, view.PubKey}}
+}
+
+func (views AddressViews) csvHeader() []string {
+	return AddressView{}.csvHeader()
+}
+
+func (views AddressViews) csvRecords() [][]string {
+	var records [][]string
+	for _, view := range views {
+		records = append(records, view.csvRecords()...)
+	}
+
+	return records
+}
+
+func (view UTXOSetView) csvHeader() []string {
+	return []string{"transactions"}
+}
+
+func (view UTXOSetView) csvRecords() [][]string {
+	return [][]string{{strconv.Itoa(view.Transactions)}}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultTransferPolicy moves items down the supply chain and allows returns
+// one step back up
+const defaultTransferPolicy = "Manufacturer>Distributor,Distributor>Retailer,Retailer>Consumer,Consumer>Retailer,Retailer>Distributor,Distributor>Manufacturer"
+
+// consumerRole is the role of addresses that belong to no organisation
+const consumerRole = "Consumer"
+
+// TransferRule allows organisations of role From to send items to role To
+type TransferRule struct {
+	From []byte
+	To   []byte
+}
+
+// TransferPolicy lists the role-to-role transfers allowed on the ledger. It is
+// recorded in the genesis block and cannot change afterwards.
+type TransferPolicy struct {
+	ID    []byte
+	Rules []TransferRule
+}
+
+// ParseTransferPolicy reads rules written as FROM>TO separated by commas
+func ParseTransferPolicy(rules string) (*TransferPolicy, error) {
+	policy := &TransferPolicy{}
+
+	for _, rule := range strings.Split(rules, ",") {
+		roles := strings.Split(strings.TrimSpace(rule), ">")
+		if len(roles) != 2 || strings.TrimSpace(roles[0]) == "" || strings.TrimSpace(roles[1]) == "" {
+			return nil, fmt.Errorf("Rule %q is not of the form FROM>TO", rule)
+		}
+
+		from := []byte(strings.TrimSpace(roles[0]))
+		to := []byte(strings.TrimSpace(roles[1]))
+		if policy.Allows(from, to) {
+			return nil, fmt.Errorf("Rule %s>%s is repeated", from, to)
+		}
+		policy.Rules = append(policy.Rules, TransferRule{from, to})
+	}
+
+	if len(policy.Rules) == 0 {
+		return nil, errors.New("Transfer policy has no rules")
+	}
+	policy.ID = policy.Hash()
+
+	return policy, nil
+}
+
+// Hash returns the hash of the rules of the policy
+func (p *TransferPolicy) Hash() []byte {
+	var rules [][]byte
+	for _, rule := range p.Rules {
+		rules = append(rules, HashFields(rule.From, rule.To))
+	}
+
+	return HashFields(rules...)
+}
+
+// Allows reports whether role from may send items to role to
+func (p *TransferPolicy) Allows(from, to []byte) bool {
+	for _, rule := range p.Rules {
+		if bytes.Compare(rule.From, from) == 0 && bytes.Compare(rule.To, to) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check returns an error naming the rule a transfer from role from to role to
+// would break
+func (p *TransferPolicy) Check(from, to []byte) error {
+	if p.Allows(from, to) {
+		return nil
+	}
+
+	var allowed []string
+	for _, rule := range p.Rules {
+		if bytes.Compare(rule.From, from) == 0 {
+			allowed = append(allowed, string(rule.To))
+		}
+	}
+
+	if len(allowed) == 0 {
+		return fmt.Errorf("Transfer policy has no rule %s>%s: %s may not send items", from, to, from)
+	}
+
+	return fmt.Errorf("Transfer policy has no rule %s>%s: %s may only send items to %s", from, to, from, strings.Join(allowed, ", "))
+}
+
+// String returns a human-readable representation of a transfer policy
+func (p TransferPolicy) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Transfer policy %x:", p.ID))
+
+	for _, rule := range p.Rules {
+		lines = append(lines, fmt.Sprintf("       %s -> %s", rule.From, rule.To))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// GetTransferPolicy returns the policy recorded in the genesis block, or nil
+// if transfers are not restricted
+func (bc *Blockchain) GetTransferPolicy() *TransferPolicy {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		if len(block.PrevBlockHash) == 0 {
+			for _, e := range block.Entries {
+				if e.Type == policyEntry {
+					return e.Policy
+				}
+			}
+			return nil
+		}
+	}
+}
+
+// GetAddressRole returns the role of the organisation holding address, or the
+// Consumer role if no organisation holds it
+func (bc *Blockchain) GetAddressRole(address string) []byte {
+	org, err := bc.FindOrganisationByAddress(address)
+	if err != nil {
+		return []byte(consumerRole)
+	}
+
+	return org.Role
+}
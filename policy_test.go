@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestValidateTransfer(t *testing.T) {
+	// parties are the organisations registered on the ledger by role
+	type parties struct {
+		maker, dist, retailer *Wallet
+	}
+
+	tests := []struct {
+		name string
+		// transfer mints items and returns the transaction sending them
+		transfer func(c *testChain, p parties) *Transaction
+		valid    bool
+	}{
+		{"manufacturer to distributor", func(c *testChain, p parties) *Transaction {
+			return c.send(p.maker, addressOf(p.dist), "0123.1.1")
+		}, true},
+		{"manufacturer to retailer", func(c *testChain, p parties) *Transaction {
+			return c.send(p.maker, addressOf(p.retailer), "0123.1.1")
+		}, false},
+		{"manufacturer to consumer", func(c *testChain, p parties) *Transaction {
+			return c.send(p.maker, addressOf(NewWallet()), "0123.1.1")
+		}, false},
+		{"distributor to retailer", func(c *testChain, p parties) *Transaction {
+			c.mustMine(NewTransactionEntries([]*Transaction{c.send(p.maker, addressOf(p.dist), "0123.1.1")})...)
+			return c.send(p.dist, addressOf(p.retailer), "0123.1.1")
+		}, true},
+		{"manufacturer back to the address of its previous key", func(c *testChain, p parties) *Transaction {
+			rotated := c.rotate(p.maker)
+			c.mint(rotated, "0123.1.2")
+			return c.send(rotated, addressOf(p.maker), "0123.1.2")
+		}, true},
+		{"signed with a rotated key", func(c *testChain, p parties) *Transaction {
+			c.rotate(p.maker)
+			return c.send(p.maker, addressOf(p.dist), "0123.1.1")
+		}, false},
+		{"to a suspended distributor", func(c *testChain, p parties) *Transaction {
+			c.changeStatus(p.dist, suspendAction)
+			return c.send(p.maker, addressOf(p.dist), "0123.1.1")
+		}, false},
+		{"from a suspended manufacturer", func(c *testChain, p parties) *Transaction {
+			c.changeStatus(p.maker, suspendAction)
+			return c.send(p.maker, addressOf(p.dist), "0123.1.1")
+		}, false},
+		{"to a reinstated distributor", func(c *testChain, p parties) *Transaction {
+			c.changeStatus(p.dist, suspendAction)
+			c.changeStatus(p.dist, reinstateAction)
+			return c.send(p.maker, addressOf(p.dist), "0123.1.1")
+		}, true},
+		{"to a retailer given the distributor role", func(c *testChain, p parties) *Transaction {
+			c.changeRole(p.retailer, "Distributor")
+			return c.send(p.maker, addressOf(p.retailer), "0123.1.1")
+		}, true},
+		{"to a distributor given the retailer role", func(c *testChain, p parties) *Transaction {
+			c.changeRole(p.dist, "Retailer")
+			return c.send(p.maker, addressOf(p.dist), "0123.1.1")
+		}, false},
+	}
+
+	policy, err := ParseTransferPolicy("Manufacturer>Distributor,Distributor>Retailer,Retailer>Consumer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestChain(t, powConsensus, policy)
+			p := parties{
+				c.register("Maker", "0123", "Manufacturer"),
+				c.register("Dist", "0124", "Distributor"),
+				c.register("Shop", "0126", "Retailer"),
+			}
+			c.addProducts(p.maker, "soap")
+			c.mint(p.maker, "0123.1.1")
+
+			tx := test.transfer(c, p)
+
+			err := c.bc.ValidateTransfer(tx)
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("ValidateTransfer returned %v, expected valid %t", err, test.valid)
+			}
+		})
+	}
+}
+
+// send returns the transaction by which the holder of wallet sends items to
+// address
+func (c *testChain) send(wallet *Wallet, to string, items ...string) *Transaction {
+	UTXOSet := UTXOSet{c.bc}
+
+	return NewUTXOTransaction(*wallet, addressOf(wallet), to, items, &UTXOSet)
+}
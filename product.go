@@ -3,24 +3,21 @@ package main
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/sha256"
 	"encoding/gob"
 	"errors"
 	"fmt"
 	"log"
-	"math/big"
 	"strings"
 )
 
-// Product struct
+// Product struct. Attributes is nil for products registered without any.
 type Product struct {
-	ID        []byte
-	Code      int
-	Name      []byte
-	Signature []byte
-	PubKey    []byte
+	ID         []byte
+	Code       int
+	Name       []byte
+	Signature  []byte
+	PubKey     []byte
+	Attributes *ProductAttributes
 }
 
 // Serialize returns a serialized Product
@@ -36,16 +33,14 @@ func (p Product) Serialize() []byte {
 	return encoded.Bytes()
 }
 
-// Hash returns the hash of the Product
+// Hash returns the hash of the Product, excluding its ID. Products without
+// attributes hash as they did before attributes existed.
 func (p *Product) Hash() []byte {
-	var hash [32]byte
-
-	product := *p
-	product.ID = []byte{}
-
-	hash = sha256.Sum256(product.Serialize())
+	if p.Attributes == nil {
+		return HashFields(IntToHex(int64(p.Code)), p.Name, p.Signature, p.PubKey)
+	}
 
-	return hash[:]
+	return HashFields(IntToHex(int64(p.Code)), p.Name, p.Signature, p.PubKey, p.Attributes.Hash())
 }
 
 // Deserialize deserializes Product
@@ -70,13 +65,7 @@ func (p *Product) Sign(privKey ecdsa.PrivateKey, pubKeyHash []byte) {
 	pCopy.ID = pCopy.Hash()
 	pCopy.PubKey = nil
 
-	r, s, err := ecdsa.Sign(rand.Reader, &privKey, pCopy.ID)
-	if err != nil {
-		log.Panic(err)
-	}
-	signature := append(r.Bytes(), s.Bytes()...)
-
-	p.Signature = signature
+	p.Signature = signDigest(privKey, pCopy.ID)
 }
 
 // String returns a human-readable representation of a product
@@ -87,6 +76,9 @@ func (p Product) String() string {
 
 	lines = append(lines, fmt.Sprintf("       Name:       %s", p.Name))
 	lines = append(lines, fmt.Sprintf("       Code:       %d", p.Code))
+	if p.Attributes != nil && p.Attributes.GTIN != "" {
+		lines = append(lines, fmt.Sprintf("       GTIN:       %s", p.Attributes.GTIN))
+	}
 	lines = append(lines, fmt.Sprintf("       Signature: %x", p.Signature))
 	lines = append(lines, fmt.Sprintf("       PubKey: %x", p.PubKey))
 	return strings.Join(lines, "\n")
@@ -95,14 +87,13 @@ func (p Product) String() string {
 // Copy creates a copy of Product to be used in signing
 func (p *Product) Copy() Product {
 
-	pCopy := Product{p.ID, p.Code, p.Name, nil, p.PubKey}
+	pCopy := Product{p.ID, p.Code, p.Name, nil, p.PubKey, p.Attributes}
 
 	return pCopy
 }
 
 // Verifies a Product
 func (p *Product) Verify(pubKeyHash []byte) bool {
-	curve := elliptic.P256()
 	pCopy := p.Copy()
 
 	pCopy.Signature = nil
@@ -110,28 +101,26 @@ func (p *Product) Verify(pubKeyHash []byte) bool {
 	pCopy.ID = pCopy.Hash()
 	pCopy.PubKey = nil
 
-	r := big.Int{}
-	s := big.Int{}
-	sigLen := len(p.Signature)
-	r.SetBytes(p.Signature[:(sigLen / 2)])
-	s.SetBytes(p.Signature[(sigLen / 2):])
-
-	x := big.Int{}
-	y := big.Int{}
-	keyLen := len(p.PubKey)
-	x.SetBytes(p.PubKey[:(keyLen / 2)])
-	y.SetBytes(p.PubKey[(keyLen / 2):])
-
-	rawPubKey := ecdsa.PublicKey{curve, &x, &y}
-	if ecdsa.Verify(&rawPubKey, pCopy.ID, &r, &s) == false {
-		return false
+	return verifyDigest(p.PubKey, pCopy.ID, p.Signature)
+}
+
+// VerifyOwner checks that the Product was registered with the key of one of
+// addresses, the addresses an organisation held. The signature of a recorded
+// product was checked against its key when it was validated.
+func (p *Product) VerifyOwner(addresses []string) bool {
+	owner := string(GetAddressFromPubKey(p.PubKey))
+
+	for _, address := range addresses {
+		if address == owner {
+			return true
+		}
 	}
 
-	return true
+	return false
 }
 
-// NewProduct creates a new product
-func NewProducts(address string, products []string, nodeID string) ([]*Product, error) {
+// NewProduct creates a new product with the given attributes, nil for none
+func NewProducts(address string, products []string, attributes *ProductAttributes, ProductCache *ProductCacheSet, nodeID string) ([]*Product, error) {
 	var ps []*Product
 	var count int
 
@@ -141,10 +130,8 @@ func NewProducts(address string, products []string, nodeID string) ([]*Product,
 		log.Panic(err)
 	}
 
-	ProductCache := ProductCacheSet{NewBlockchain(nodeID)}
-
 	wallet := wallets.GetWallet(address)
-	r := ProductCacheSet.Blockchain.GetRole(wallet.PublicKey)
+	r := ProductCache.Blockchain.GetRole(wallet.PublicKey)
 
 	if r == nil {
 		return nil, errors.New("Organisation not found")
@@ -153,17 +140,25 @@ func NewProducts(address string, products []string, nodeID string) ([]*Product,
 	if bytes.Compare(r, []byte("Manufacturer")) != 0 {
 		return nil, errors.New("Not authorized to perform this action")
 	}
+	if err := ProductCache.Blockchain.CheckActive(wallet.PublicKey); err != nil {
+		return nil, err
+	}
 
-	count = ProductCacheSet.Blockchain.GetNextProductCode(address)
+	count = ProductCache.Blockchain.GetNextProductCode(address)
 
 	for index, p := range products[:] {
-		product := &Product{nil, count + index, []byte(p), nil, wallet.PublicKey}
+		product := &Product{nil, count + index, []byte(p), nil, wallet.PublicKey, attributes}
 		product.ID = product.Hash()
 		ps = append(ps, product)
 	}
 
 	SignProducts(ps, wallet.PrivateKey, Base58Decode([]byte(address)))
 
+	err = ProductCache.Blockchain.ValidateProducts(ps)
+	if err != nil {
+		return nil, err
+	}
+
 	return ps, nil
 }
 
@@ -25,7 +25,7 @@ func (p ProductCacheSet) Reindex() {
 }
 
 // Update updates the ProductCache set with product from the Block
-// The Block is considered to be the tip2 of a blockchain
+// The Block is considered to be the tip of a blockchain
 func (p ProductCacheSet) Update(block *Block) {
 
 }
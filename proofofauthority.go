@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const validatorRole = "Validator"
+
+// Weight of blocks sealed by the in-turn validator and by any other validator
+const inTurnWork = 2
+const outOfTurnWork = 1
+
+// PoAEngine seals blocks with the signature of a validator. Validators are the
+// active organisations with the Admin or Validator role as of the parent of the
+// block, sealing with their current key. The in-turn validator of a height is
+// picked round-robin in registration order; the others may seal out of turn,
+// but their blocks weigh less when choosing a branch.
+type PoAEngine struct {
+	bc     *Blockchain
+	nodeID string
+	// validator sets already computed, by parent hash and height
+	validators map[string][][]byte
+}
+
+func (poa *PoAEngine) prepareData(block *Block) []byte {
+	data := bytes.Join(
+		[][]byte{
+			block.PrevBlockHash,
+			block.HashEntries(),
+			IntToHex(block.Timestamp),
+			IntToHex(int64(block.Height)),
+			block.Validator,
+		},
+		[]byte{},
+	)
+
+	return data
+}
+
+// Hash recomputes the block hash from its header, contents and validator
+func (poa *PoAEngine) Hash(block *Block) []byte {
+	hash := sha256.Sum256(poa.prepareData(block))
+
+	return hash[:]
+}
+
+// signer returns the validator wallet of this node for the block at height
+// following parentHash, preferring the one in turn
+func (poa *PoAEngine) signer(parentHash []byte, height int) (*Wallet, error) {
+	validators := poa.validatorsOf(parentHash, height)
+
+	wallets, err := NewWallets(poa.nodeID)
+	if err != nil {
+		return nil, errors.New("No wallet found for this node")
+	}
+
+	for i := range validators {
+		pubKey := validators[(height+i)%len(validators)]
+		wallet, ok := wallets.Wallets[string(GetAddressFromPubKey(pubKey))]
+		if ok {
+			return wallet, nil
+		}
+	}
+
+	return nil, errors.New("This node holds no validator key")
+}
+
+// Seal signs the block with a validator key held by this node
+func (poa *PoAEngine) Seal(block *Block) error {
+	wallet, err := poa.signer(block.PrevBlockHash, block.Height)
+	if err != nil {
+		return err
+	}
+
+	block.Validator = wallet.PublicKey
+	block.Hash = poa.Hash(block)
+	block.Signature = signDigest(wallet.PrivateKey, block.Hash)
+
+	return nil
+}
+
+// Verify checks that the block is signed by a validator as of its parent
+func (poa *PoAEngine) Verify(block *Block) error {
+	if !poa.isValidator(block.Validator, block.PrevBlockHash, block.Height) {
+		return rejectBlock(RejectInvalidSeal, "block is not sealed by a validator")
+	}
+	if hash := poa.Hash(block); bytes.Compare(hash, block.Hash) != 0 {
+		return rejectBlock(RejectInvalidHash, "expected %x", hash)
+	}
+	if !verifyDigest(block.Validator, block.Hash, block.Signature) {
+		return rejectBlock(RejectInvalidSeal, "invalid validator signature")
+	}
+
+	return nil
+}
+
+// Work gives blocks sealed in turn more weight than out-of-turn blocks
+func (poa *PoAEngine) Work(block *Block) *big.Int {
+	validators := poa.validatorsOf(block.PrevBlockHash, block.Height)
+	if len(validators) > 0 && bytes.Compare(validators[block.Height%len(validators)], block.Validator) == 0 {
+		return big.NewInt(inTurnWork)
+	}
+
+	return big.NewInt(outOfTurnWork)
+}
+
+// CanSeal reports whether the wallet of this node holds a validator key for
+// the next block
+func (poa *PoAEngine) CanSeal() bool {
+	_, err := poa.signer(poa.bc.tip, poa.bc.GetBestHeight()+1)
+
+	return err == nil
+}
+
+// validatorsOf returns the validators of the block at height following
+// parentHash. The set of a known parent never changes, so it is computed once.
+func (poa *PoAEngine) validatorsOf(parentHash []byte, height int) [][]byte {
+	key := fmt.Sprintf("%x:%d", parentHash, height)
+
+	validators, ok := poa.validators[key]
+	if !ok {
+		validators = poa.bc.GetValidators(parentHash, height)
+		if validators != nil {
+			poa.validators[key] = validators
+		}
+	}
+
+	return validators
+}
+
+// isValidator checks whether a public key is the key of a validator of the
+// block at height following parentHash
+func (poa *PoAEngine) isValidator(pubKey, parentHash []byte, height int) bool {
+	if len(pubKey) == 0 {
+		return false
+	}
+
+	for _, validator := range poa.validatorsOf(parentHash, height) {
+		if bytes.Compare(validator, pubKey) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetValidators returns the current keys of the validators of the block at
+// height following parentHash, in registration order: the organisations with
+// the Admin or Validator role as of that parent, unless suspended or revoked
+// at height. It returns nil when the parent is not known.
+func (bc *Blockchain) GetValidators(parentHash []byte, height int) [][]byte {
+	var validators [][]byte
+
+	if len(parentHash) == 0 {
+		return nil
+	}
+	if _, err := bc.GetBlock(parentHash); err != nil {
+		return nil
+	}
+
+	// the registry as recorded up to the parent, which may be on a side branch
+	parent := &Blockchain{parentHash, bc.db, bc.consensus}
+	rotations := parent.GetKeyRotations()
+	changes := parent.GetStatusChanges()
+
+	for _, org := range parent.GetOrganisations() {
+		if bytes.Compare(org.Role, []byte("Admin")) != 0 && bytes.Compare(org.Role, []byte(validatorRole)) != 0 {
+			continue
+		}
+		if status, _ := findStatus(changes, org.PubKey, height); status != activeStatus {
+			continue
+		}
+
+		keys := keysOf(rotations, org.PubKey)
+		validators = append(validators, keys[len(keys)-1])
+	}
+
+	return validators
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+// blockOn builds a block at height on parent sealed with the key of wallet.
+// Seals are checked before contents, so its entry is a mint of the Admin.
+func (c *testChain) blockOn(parent []byte, height int, wallet *Wallet) *Block {
+	tx := NewMintTransaction(*c.admin, addressOf(c.admin), []string{"0001.1.1"}, nil)
+
+	block := NewBlock(NewTransactionEntries([]*Transaction{tx}), parent, height)
+	c.seal(block, wallet)
+
+	return block
+}
+
+func TestPoAVerify(t *testing.T) {
+	// accepted marks blocks that are not rejected
+	const accepted = RejectReason(-1)
+
+	tests := []struct {
+		name string
+		// block returns the block to validate on a ledger with an Admin
+		block  func(c *testChain) *Block
+		reason RejectReason
+	}{
+		{"sealed by the admin", func(c *testChain) *Block {
+			return c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, c.admin)
+		}, accepted},
+		{"unknown parent", func(c *testChain) *Block {
+			parent := make([]byte, 32)
+			rand.Read(parent)
+			return c.blockOn(parent, c.bc.GetBestHeight()+1, c.admin)
+		}, RejectUnknownParent},
+		{"sealed by a manufacturer", func(c *testChain) *Block {
+			maker := c.register("Maker", "0123", "Manufacturer")
+			return c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, maker)
+		}, RejectInvalidSeal},
+		{"sealed by a validator", func(c *testChain) *Block {
+			validator := c.register("Validator", "0124", validatorRole)
+			return c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, validator)
+		}, accepted},
+		{"sealed by a validator before its registration", func(c *testChain) *Block {
+			genesis := c.bc.tip
+			validator := c.register("Validator", "0124", validatorRole)
+			return c.blockOn(genesis, 1, validator)
+		}, RejectInvalidSeal},
+		{"signed by another key", func(c *testChain) *Block {
+			block := c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, NewWallet())
+			block.Validator = c.admin.PublicKey
+			block.Hash = c.bc.consensus.(*PoAEngine).Hash(block)
+			return block
+		}, RejectInvalidSeal},
+		{"sealed by a rotated key", func(c *testChain) *Block {
+			validator := c.register("Validator", "0124", validatorRole)
+			c.rotate(validator)
+			return c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, validator)
+		}, RejectInvalidSeal},
+		{"sealed by the key rotated to", func(c *testChain) *Block {
+			validator := c.register("Validator", "0124", validatorRole)
+			rotated := c.rotate(validator)
+			return c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, rotated)
+		}, accepted},
+		{"sealed by a suspended validator", func(c *testChain) *Block {
+			validator := c.register("Validator", "0124", validatorRole)
+			c.changeStatus(validator, suspendAction)
+			return c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, validator)
+		}, RejectInvalidSeal},
+		{"sealed by a reinstated validator", func(c *testChain) *Block {
+			validator := c.register("Validator", "0124", validatorRole)
+			c.changeStatus(validator, suspendAction)
+			c.changeStatus(validator, reinstateAction)
+			return c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, validator)
+		}, accepted},
+		{"sealed by a manufacturer given the validator role", func(c *testChain) *Block {
+			maker := c.register("Maker", "0123", "Manufacturer")
+			c.changeRole(maker, validatorRole)
+			return c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, maker)
+		}, accepted},
+		{"sealed by a validator given another role", func(c *testChain) *Block {
+			validator := c.register("Validator", "0124", validatorRole)
+			c.changeRole(validator, "Manufacturer")
+			return c.blockOn(c.bc.tip, c.bc.GetBestHeight()+1, validator)
+		}, RejectInvalidSeal},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestChain(t, poaConsensus, nil)
+
+			err := c.bc.ValidateBlock(test.block(c))
+			if test.reason == accepted {
+				if err != nil {
+					t.Fatalf("block rejected: %s", err)
+				}
+				return
+			}
+
+			rejection, ok := err.(*BlockRejection)
+			if !ok {
+				t.Fatalf("expected a rejection for %s, got %v", test.reason, err)
+			}
+			if rejection.Reason != test.reason {
+				t.Fatalf("rejected for %s, expected %s", rejection, test.reason)
+			}
+		})
+	}
+}
+
+func TestGetValidatorsUnknownParent(t *testing.T) {
+	c := newTestChain(t, poaConsensus, nil)
+
+	parent := make([]byte, 32)
+	rand.Read(parent)
+
+	if validators := c.bc.GetValidators(parent, 1); validators != nil {
+		t.Fatalf("expected no validators for an unknown parent, got %d", len(validators))
+	}
+	if validators := c.bc.GetValidators(c.bc.tip, 1); len(validators) != 1 {
+		t.Fatalf("expected the admin as validator of the genesis block, got %d", len(validators))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"os"
 )
 
 var (
@@ -34,7 +35,7 @@ func (pow *ProofOfWork) prepareData(nonce int) []byte {
 	data := bytes.Join(
 		[][]byte{
 			pow.block.PrevBlockHash,
-			pow.block.HashTransactionsOrProducts(),
+			pow.block.HashEntries(),
 			IntToHex(pow.block.Timestamp),
 			IntToHex(int64(targetBits)),
 			IntToHex(int64(nonce)),
@@ -45,18 +46,19 @@ func (pow *ProofOfWork) prepareData(nonce int) []byte {
 	return data
 }
 
-// Run performs a proof-of-work
+// Run performs a proof-of-work. Progress goes to stderr, keeping the output of
+// CLI commands parseable.
 func (pow *ProofOfWork) Run() (int, []byte) {
 	var hashInt big.Int
 	var hash [32]byte
 	nonce := 0
 
-	fmt.Printf("Mining a new block")
+	fmt.Fprintf(os.Stderr, "Mining a new block")
 	for nonce < maxNonce {
 		data := pow.prepareData(nonce)
 
 		hash = sha256.Sum256(data)
-		fmt.Printf("\r%x", hash)
+		fmt.Fprintf(os.Stderr, "\r%x", hash)
 		hashInt.SetBytes(hash[:])
 
 		if hashInt.Cmp(pow.target) == -1 {
@@ -65,11 +67,25 @@ func (pow *ProofOfWork) Run() (int, []byte) {
 			nonce++
 		}
 	}
-	fmt.Print("\n\n")
+	fmt.Fprint(os.Stderr, "\n\n")
 
 	return nonce, hash[:]
 }
 
+// Hash recomputes the block hash from its header and contents
+func (pow *ProofOfWork) Hash() []byte {
+	hash := sha256.Sum256(pow.prepareData(pow.block.Nonce))
+
+	return hash[:]
+}
+
+// Work returns the expected number of hashes needed to find a block under the target
+func (pow *ProofOfWork) Work() *big.Int {
+	work := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	return work.Div(work, new(big.Int).Add(pow.target, big.NewInt(1)))
+}
+
 // Validate validates block's PoW
 func (pow *ProofOfWork) Validate() bool {
 	var hashInt big.Int
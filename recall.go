@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Recall is a notice signed by a Manufacturer withdrawing items it minted. It
+// covers the items listed in Items, or else the serials FromSerial to ToSerial
+// of a product code, or else every item of the product code.
+type Recall struct {
+	ID         []byte
+	Prefix     []byte
+	Code       int
+	Items      []string
+	FromSerial int
+	ToSerial   int
+	Reason     []byte
+	Timestamp  int64
+	Signature  []byte
+	PubKey     []byte
+}
+
+// NewRecall creates a recall signed by the manufacturer holding address
+func NewRecall(address string, code int, items []string, fromSerial, toSerial int, reason string, bc *Blockchain, nodeID string) (*Recall, error) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("Address %s is not in the wallet of this node", address)
+	}
+
+	org, err := bc.FindOrganisationByPublicKey(wallet.PublicKey)
+	if err != nil {
+		return nil, errors.New("Organisation not found")
+	}
+
+	recall := &Recall{nil, org.Prefix, code, items, fromSerial, toSerial, []byte(reason), time.Now().Unix(), nil, wallet.PublicKey}
+	recall.Sign(wallet.PrivateKey)
+
+	err = bc.ValidateRecall(recall)
+	if err != nil {
+		return nil, err
+	}
+
+	return recall, nil
+}
+
+// Hash returns the hash of the recall, excluding its ID
+func (r *Recall) Hash() []byte {
+	var items [][]byte
+	for _, item := range r.Items {
+		items = append(items, []byte(item))
+	}
+
+	return HashFields(
+		r.Prefix,
+		IntToHex(int64(r.Code)),
+		HashFields(items...),
+		IntToHex(int64(r.FromSerial)),
+		IntToHex(int64(r.ToSerial)),
+		r.Reason,
+		IntToHex(r.Timestamp),
+		r.Signature,
+		r.PubKey,
+	)
+}
+
+// Sign sets the ID of the recall and signs it
+func (r *Recall) Sign(privKey ecdsa.PrivateKey) {
+	r.Signature = nil
+	r.ID = r.Hash()
+
+	r.Signature = signDigest(privKey, r.ID)
+}
+
+// Verify checks that the ID matches the recall and is signed by its PubKey
+func (r *Recall) Verify() bool {
+	rCopy := *r
+	rCopy.Signature = nil
+	if bytes.Compare(rCopy.Hash(), r.ID) != 0 {
+		return false
+	}
+
+	return verifyDigest(r.PubKey, r.ID, r.Signature)
+}
+
+// Covers reports whether the recall applies to item
+func (r *Recall) Covers(item string) bool {
+	prefix, code, serial, err := ParseSGTIN(item)
+	if err != nil || prefix != string(r.Prefix) {
+		return false
+	}
+
+	if len(r.Items) > 0 {
+		for _, recalled := range r.Items {
+			if recalled == item {
+				return true
+			}
+		}
+		return false
+	}
+
+	if code != r.Code {
+		return false
+	}
+	if r.ToSerial > 0 {
+		return serial >= r.FromSerial && serial <= r.ToSerial
+	}
+
+	return true
+}
+
+// Scope describes the items covered by the recall
+func (r *Recall) Scope() string {
+	if len(r.Items) > 0 {
+		return "items " + strings.Join(r.Items, ",")
+	}
+	if r.ToSerial > 0 {
+		return fmt.Sprintf("serials %d to %d of product code %d", r.FromSerial, r.ToSerial, r.Code)
+	}
+
+	return fmt.Sprintf("product code %d", r.Code)
+}
+
+// String returns a human-readable representation of a recall
+func (r Recall) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Recall %x:", r.ID))
+
+	lines = append(lines, fmt.Sprintf("       Prefix:       %s", r.Prefix))
+	lines = append(lines, fmt.Sprintf("       Scope:       %s", r.Scope()))
+	lines = append(lines, fmt.Sprintf("       Reason:       %s", r.Reason))
+	lines = append(lines, fmt.Sprintf("       Signature: %x", r.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %x", r.PubKey))
+	return strings.Join(lines, "\n")
+}
+
+// GetRecalls returns every recall in registration order
+func (bc *Blockchain) GetRecalls() []Recall {
+	var recalls []Recall
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		blockRecalls := block.Recalls()
+
+		for i := len(blockRecalls) - 1; i >= 0; i-- {
+			recalls = append([]Recall{*blockRecalls[i]}, recalls...)
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return recalls
+}
+
+// findRecall returns the first of recalls covering item, or nil
+func findRecall(recalls []Recall, item string) *Recall {
+	for i := range recalls {
+		if recalls[i].Covers(item) {
+			return &recalls[i]
+		}
+	}
+
+	return nil
+}
+
+// FindRecalledOutputs returns the unspent outputs carrying recalled items, on
+// their own or packed in logistic units
+func (u UTXOSet) FindRecalledOutputs(recalls []Recall) []TXOutput {
+	var recalled []TXOutput
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			for _, out := range DeserializeOutputs(v).Outputs {
+				for _, item := range out.CarriedItems() {
+					if findRecall(recalls, item) != nil {
+						recalled = append(recalled, out)
+						break
+					}
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return recalled
+}
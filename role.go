@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RoleChange is an action signed by an Admin giving the organisation
+// registered with OrgPubKey the role Role from the next block. On a ledger
+// sealed by proof of authority, giving or taking the Admin or Validator role
+// adds or removes a validator from the next block on.
+type RoleChange struct {
+	ID        []byte
+	OrgPubKey []byte
+	Role      []byte
+	Timestamp int64
+	Signature []byte
+	PubKey    []byte
+}
+
+// NewRoleChange creates a change of the role of the organisation holding org,
+// signed by the admin holding address
+func NewRoleChange(address, org, role string, bc *Blockchain, nodeID string) (*RoleChange, error) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("Address %s is not in the wallet of this node", address)
+	}
+
+	target, err := bc.FindOrganisationByAddress(org)
+	if err != nil {
+		return nil, fmt.Errorf("Organisation %s not found", org)
+	}
+
+	change := &RoleChange{nil, target.PubKey, []byte(role), time.Now().Unix(), nil, wallet.PublicKey}
+	change.Sign(wallet.PrivateKey)
+
+	err = bc.ValidateRoleChange(change)
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// Hash returns the hash of the role change, excluding its ID
+func (c *RoleChange) Hash() []byte {
+	return HashFields(
+		c.OrgPubKey,
+		c.Role,
+		IntToHex(c.Timestamp),
+		c.Signature,
+		c.PubKey,
+	)
+}
+
+// Sign sets the ID of the role change and signs it
+func (c *RoleChange) Sign(privKey ecdsa.PrivateKey) {
+	c.Signature = nil
+	c.ID = c.Hash()
+
+	c.Signature = signDigest(privKey, c.ID)
+}
+
+// Verify checks that the ID matches the role change and is signed by its PubKey
+func (c *RoleChange) Verify() bool {
+	cCopy := *c
+	cCopy.Signature = nil
+	if bytes.Compare(cCopy.Hash(), c.ID) != 0 {
+		return false
+	}
+
+	return verifyDigest(c.PubKey, c.ID, c.Signature)
+}
+
+// String returns a human-readable representation of a role change
+func (c RoleChange) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Role change %x:", c.ID))
+
+	lines = append(lines, fmt.Sprintf("       Organisation:       %s", GetAddressFromPubKey(c.OrgPubKey)))
+	lines = append(lines, fmt.Sprintf("       Role:       %s", c.Role))
+	lines = append(lines, fmt.Sprintf("       Signature: %x", c.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %x", c.PubKey))
+	return strings.Join(lines, "\n")
+}
+
+// GetRoleChanges returns every role change in registration order
+func (bc *Blockchain) GetRoleChanges() []RoleChange {
+	var changes []RoleChange
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		blockChanges := block.RoleChanges()
+
+		for i := len(blockChanges) - 1; i >= 0; i-- {
+			changes = append([]RoleChange{*blockChanges[i]}, changes...)
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return changes
+}
+
+// applyRoleChanges gives each organisation of orgs the role set by the last
+// of changes for it
+func applyRoleChanges(orgs []Organisation, changes []RoleChange) {
+	for _, change := range changes {
+		for i := range orgs {
+			if bytes.Compare(orgs[i].PubKey, change.OrgPubKey) == 0 {
+				orgs[i].Role = change.Role
+			}
+		}
+	}
+}
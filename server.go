@@ -9,16 +9,23 @@ import (
 	"io/ioutil"
 	"log"
 	"net"
+	"sync"
 )
 
 const protocol = "tcp"
-const nodeVersion = 1
+const nodeVersion = 3
 const commandLength = 12
+const maxOrphanBlocks = 100
 
 var nodeAddress string
 var knownNodes = []string{"localhost:3000"}
 var blocksInTransit = [][]byte{}
 var mempool = make(map[string]Transaction)
+var orphanBlocks = make(map[string][]*Block)
+
+// nodeLock serialises the handling of peer messages and API requests, which
+// share the blockchain, the mempool and the sync state
+var nodeLock sync.Mutex
 
 type addr struct {
 	AddrList []string
@@ -50,6 +57,11 @@ type tx struct {
 	Transaction []byte
 }
 
+type proposal struct {
+	AddrFrom string
+	Proposal []byte
+}
+
 type verzion struct {
 	Version    int
 	BestHeight int
@@ -159,6 +171,14 @@ func sendTx(addr string, tnx *Transaction) {
 	sendData(addr, request)
 }
 
+func sendProposal(addr string, p *Proposal) {
+	data := proposal{nodeAddress, p.Serialize()}
+	payload := gobEncode(data)
+	request := append(commandToBytes("proposal"), payload...)
+
+	sendData(addr, request)
+}
+
 func sendVersion(addr string, bc *Blockchain) {
 	bestHeight := bc.GetBestHeight()
 	payload := gobEncode(verzion{nodeVersion, bestHeight, nodeAddress})
@@ -199,21 +219,99 @@ func handleBlock(request []byte, bc *Blockchain) {
 	block := DeserializeBlock(blockData)
 
 	fmt.Println("Recevied a new block!")
-	bc.AddBlock(block)
 
-	fmt.Printf("Added block %x\n", block.Hash)
+	if _, err := bc.GetBlock(block.Hash); err == nil {
+		fmt.Printf("Block %x is already known\n", block.Hash)
+	} else {
+		err = bc.ValidateBlock(block)
+		if rejection, ok := err.(*BlockRejection); ok && rejection.Reason == RejectUnknownParent {
+			addOrphanBlock(block)
+			fmt.Printf("Holding orphan block %x until its parent arrives\n", block.Hash)
+			sendGetBlocks(payload.AddrFrom)
+			return
+		}
+		if err == nil {
+			err = acceptBlock(bc, block, payload.AddrFrom)
+		}
+		if err != nil {
+			fmt.Printf("Rejected block %x: %s\n", block.Hash, err)
+			blocksInTransit = [][]byte{}
+			return
+		}
+	}
 
 	if len(blocksInTransit) > 0 {
 		blockHash := blocksInTransit[0]
 		sendGetData(payload.AddrFrom, "block", blockHash)
 
 		blocksInTransit = blocksInTransit[1:]
-	} else {
-		UTXOSet := UTXOSet{bc}
-		UTXOSet.Reindex()
 	}
 }
 
+// acceptBlock adds a validated block to the blockchain, relays it and then
+// processes the orphan blocks that were waiting for it
+func acceptBlock(bc *Blockchain, block *Block, addrFrom string) error {
+	err := bc.AddBlock(block)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added block %x\n", block.Hash)
+
+	// blocks left on a side branch are not relayed
+	if nodeAddress == knownNodes[0] && bytes.Equal(bc.tip, block.Hash) {
+		for _, node := range knownNodes {
+			if node != nodeAddress && node != addrFrom {
+				sendInv(node, "block", [][]byte{block.Hash})
+			}
+		}
+	}
+
+	key := hex.EncodeToString(block.Hash)
+	orphans := orphanBlocks[key]
+	delete(orphanBlocks, key)
+
+	for _, orphan := range orphans {
+		err := bc.ValidateBlock(orphan)
+		if err == nil {
+			err = acceptBlock(bc, orphan, addrFrom)
+		}
+		if err != nil {
+			fmt.Printf("Rejected orphan block %x: %s\n", orphan.Hash, err)
+		}
+	}
+
+	return nil
+}
+
+// addOrphanBlock holds a block whose parent is not known yet
+func addOrphanBlock(block *Block) {
+	count := 0
+	for _, blocks := range orphanBlocks {
+		count += len(blocks)
+	}
+
+	if count >= maxOrphanBlocks || isOrphanBlock(block.Hash) {
+		return
+	}
+
+	key := hex.EncodeToString(block.PrevBlockHash)
+	orphanBlocks[key] = append(orphanBlocks[key], block)
+}
+
+// isOrphanBlock checks whether a block is held in the orphan pool
+func isOrphanBlock(hash []byte) bool {
+	for _, blocks := range orphanBlocks {
+		for _, block := range blocks {
+			if bytes.Compare(block.Hash, hash) == 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func handleInv(request []byte, bc *Blockchain) {
 	var buff bytes.Buffer
 	var payload inv
@@ -228,18 +326,22 @@ func handleInv(request []byte, bc *Blockchain) {
 	fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
 
 	if payload.Type == "block" {
-		blocksInTransit = payload.Items
-
-		blockHash := payload.Items[0]
-		sendGetData(payload.AddrFrom, "block", blockHash)
-
-		newInTransit := [][]byte{}
-		for _, b := range blocksInTransit {
-			if bytes.Compare(b, blockHash) != 0 {
-				newInTransit = append(newInTransit, b)
+		// blocks are announced newest first; fetch the missing ones oldest first
+		// so that each can be validated against the chainstate of its ancestors
+		var missing [][]byte
+		for i := len(payload.Items) - 1; i >= 0; i-- {
+			if _, err := bc.GetBlock(payload.Items[i]); err != nil && !isOrphanBlock(payload.Items[i]) {
+				missing = append(missing, payload.Items[i])
 			}
 		}
-		blocksInTransit = newInTransit
+
+		if len(missing) == 0 {
+			return
+		}
+
+		sendGetData(payload.AddrFrom, "block", missing[0])
+
+		blocksInTransit = missing[1:]
 	}
 
 	if payload.Type == "tx" {
@@ -262,7 +364,7 @@ func handleGetBlocks(request []byte, bc *Blockchain) {
 		log.Panic(err)
 	}
 
-	blocks := bc.GetBlockHashes("t")
+	blocks := bc.GetBlockHashes()
 	sendInv(payload.AddrFrom, "block", blocks)
 }
 
@@ -278,7 +380,7 @@ func handleGetData(request []byte, bc *Blockchain) {
 	}
 
 	if payload.Type == "block" {
-		block, err := bc.GetBlock([]byte(payload.ID))
+		block, err := bc.GetBlock(payload.ID)
 		if err != nil {
 			return
 		}
@@ -308,34 +410,110 @@ func handleTx(request []byte, bc *Blockchain) {
 
 	txData := payload.Transaction
 	tx := DeserializeTransaction(txData)
-	mempool[hex.EncodeToString(tx.ID)] = tx
+
+	err = submitTransaction(bc, &tx, payload.AddFrom)
+	if err != nil {
+		fmt.Printf("Rejected transaction %x: %s\n", tx.ID, err)
+	}
+}
+
+// handleProposal merges the approvals of a proposal into the one the node
+// keeps. A node that can seal mines the change once it has enough approvals,
+// otherwise the proposal is relayed while it gains approvals.
+func handleProposal(request []byte, bc *Blockchain) {
+	var buff bytes.Buffer
+	var payload proposal
+
+	buff.Write(request[commandLength:])
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	received := DeserializeProposal(payload.Proposal)
+	if received.Entry == nil || bc.IsRecorded(received.ID()) {
+		return
+	}
+
+	kept := bc.FindProposal(received.ID())
+	if kept == nil {
+		kept = NewProposal(received.Entry)
+		kept.Merge(received)
+	} else if !kept.Merge(received) {
+		return
+	}
+
+	err = bc.ValidateProposal(kept)
+	if err != nil {
+		fmt.Printf("Rejected proposal %x: %s\n", received.ID(), err)
+		return
+	}
+
+	if len(kept.Approvers()) >= bc.RequiredApprovals() && bc.consensus.CanSeal() {
+		err = mineProposal(bc, kept)
+		if err == nil {
+			fmt.Println("New block is mined!")
+			return
+		}
+		fmt.Printf("Proposal %x cannot be mined: %s\n", kept.ID(), err)
+	}
+
+	bc.SaveProposal(kept)
+	for _, node := range knownNodes {
+		if node != nodeAddress && node != payload.AddrFrom {
+			sendProposal(node, kept)
+		}
+	}
+}
+
+// submitTransaction validates a transaction and adds it to the mempool. The
+// central node relays it to the other nodes, which mine once enough
+// transactions are waiting.
+func submitTransaction(bc *Blockchain, tx *Transaction, addrFrom string) error {
+	if mempool[hex.EncodeToString(tx.ID)].ID != nil {
+		return nil
+	}
+
+	UTXOSet := UTXOSet{bc}
+	err := UTXOSet.ValidateTransaction(tx)
+	if err == nil {
+		err = checkMempoolConflicts(tx)
+	}
+	if err != nil {
+		return err
+	}
+
+	mempool[hex.EncodeToString(tx.ID)] = *tx
 
 	if nodeAddress == knownNodes[0] {
 		for _, node := range knownNodes {
-			if node != nodeAddress && node != payload.AddFrom {
+			if node != nodeAddress && node != addrFrom {
 				sendInv(node, "tx", [][]byte{tx.ID})
 			}
 		}
 	} else {
-		if len(mempool) >= 2 {
+		if len(mempool) >= 2 && bc.consensus.CanSeal() {
 		MineTransactions:
 			var txs []*Transaction
 
 			for id := range mempool {
 				tx := mempool[id]
-				if bc.VerifyTransaction(&tx) {
+				if UTXOSet.ValidateTransaction(&tx) != nil {
+					delete(mempool, id)
+					continue
+				}
+				if UTXOSet.ValidateTransactions(append(txs, &tx)) == nil {
 					txs = append(txs, &tx)
 				}
 			}
 
 			if len(txs) == 0 {
 				fmt.Println("All transactions are invalid! Waiting for new ones...")
-				return
+				return nil
 			}
 
-			newBlock := bc.MineBlock(txs, nil, nil, )
-			UTXOSet := UTXOSet{bc}
-			UTXOSet.Reindex()
+			newBlock := bc.MineBlock(NewTransactionEntries(txs))
 
 			fmt.Println("New block is mined!")
 
@@ -344,11 +522,7 @@ func handleTx(request []byte, bc *Blockchain) {
 				delete(mempool, txID)
 			}
 
-			for _, node := range knownNodes {
-				if node != nodeAddress {
-					sendInv(node, "block", [][]byte{newBlock.Hash})
-				}
-			}
+			announceBlock(newBlock)
 
 			if len(mempool) > 0 {
 				goto MineTransactions
@@ -356,6 +530,28 @@ func handleTx(request []byte, bc *Blockchain) {
 
 		}
 	}
+
+	return nil
+}
+
+// checkMempoolConflicts returns an error if tx spends an output that is
+// already spent by a transaction waiting in the mempool
+func checkMempoolConflicts(tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+
+	for _, pending := range mempool {
+		for _, pendingIn := range pending.Vin {
+			for _, vin := range tx.Vin {
+				if bytes.Compare(pendingIn.Txid, vin.Txid) == 0 && pendingIn.Vout == vin.Vout {
+					return fmt.Errorf("Output %s is already spent by %x", outpoint(vin.Txid, vin.Vout), pending.ID)
+				}
+			}
+		}
+	}
+
+	return nil
 }
 
 func handleVersion(request []byte, bc *Blockchain) {
@@ -392,6 +588,9 @@ func handleConnection(conn net.Conn, bc *Blockchain) {
 	command := bytesToCommand(request[:commandLength])
 	fmt.Printf("Received %s command\n", command)
 
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+
 	switch command {
 	case "addr":
 		handleAddr(request)
@@ -405,6 +604,8 @@ func handleConnection(conn net.Conn, bc *Blockchain) {
 		handleGetData(request, bc)
 	case "tx":
 		handleTx(request, bc)
+	case "proposal":
+		handleProposal(request, bc)
 	case "version":
 		handleVersion(request, bc)
 	default:
@@ -414,8 +615,9 @@ func handleConnection(conn net.Conn, bc *Blockchain) {
 	conn.Close()
 }
 
-// StartServer starts a node
-func StartServer(nodeID string) {
+// StartServer starts a node with its control socket, serving the HTTP API on
+// apiAddress if it is set
+func StartServer(nodeID, apiAddress string) {
 	nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
 	ln, err := net.Listen(protocol, nodeAddress)
 	if err != nil {
@@ -425,6 +627,11 @@ func StartServer(nodeID string) {
 
 	bc := NewBlockchain(nodeID)
 
+	go StartControl(nodeID, bc)
+	if apiAddress != "" {
+		go StartAPI(apiAddress, bc)
+	}
+
 	if nodeAddress != knownNodes[0] {
 		sendVersion(knownNodes[0], bc)
 	}
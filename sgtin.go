@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// FormatSGTIN builds the prefix.code.serial identifier of an item
+func FormatSGTIN(prefix string, code, serial int) string {
+	return prefix + "." + strconv.Itoa(code) + "." + strconv.Itoa(serial)
+}
+
+// ParseSGTIN splits a prefix.code.serial identifier into its parts
+func ParseSGTIN(item string) (string, int, int, error) {
+	parts := strings.Split(item, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", 0, 0, errors.New("SGTIN must have the form prefix.code.serial")
+	}
+
+	code, err := strconv.Atoi(parts[1])
+	if err != nil || code < 1 {
+		return "", 0, 0, errors.New("SGTIN product code is not a positive number")
+	}
+
+	serial, err := strconv.Atoi(parts[2])
+	if err != nil || serial < 1 {
+		return "", 0, 0, errors.New("SGTIN serial is not a positive number")
+	}
+
+	return parts[0], code, serial, nil
+}
+
+// FormatSSCC builds the prefix.serial identifier of a logistic unit
+func FormatSSCC(prefix string, serial int) string {
+	return prefix + "." + strconv.Itoa(serial)
+}
+
+// ParseSSCC splits a prefix.serial identifier into its parts
+func ParseSSCC(unit string) (string, int, error) {
+	parts := strings.Split(unit, ".")
+	if len(parts) != 2 || parts[0] == "" {
+		return "", 0, errors.New("SSCC must have the form prefix.serial")
+	}
+
+	serial, err := strconv.Atoi(parts[1])
+	if err != nil || serial < 1 {
+		return "", 0, errors.New("SSCC serial is not a positive number")
+	}
+
+	return parts[0], serial, nil
+}
@@ -3,15 +3,11 @@ package main
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
-	"math/big"
 	"strconv"
 	"strings"
 )
@@ -41,16 +37,40 @@ func (tx Transaction) Serialize() []byte {
 	return encoded.Bytes()
 }
 
-// Hash returns the hash of the Transaction
+// Hash returns the hash of the Transaction, excluding its ID
 func (tx *Transaction) Hash() []byte {
-	var hash [32]byte
+	fields := [][]byte{IntToHex(int64(len(tx.Vin)))}
 
-	txCopy := *tx
-	txCopy.ID = []byte{}
+	for _, vin := range tx.Vin {
+		fields = append(fields, vin.Txid, IntToHex(int64(vin.Vout)), vin.Signature, vin.PubKey)
+		if len(vin.Code) > 0 {
+			fields = append(fields, vin.Code)
+		}
+	}
 
-	hash = sha256.Sum256(txCopy.Serialize())
+	fields = append(fields, IntToHex(int64(len(tx.Vout))))
 
-	return hash[:]
+	for _, vout := range tx.Vout {
+		fields = append(fields, IntToHex(int64(vout.Index)), []byte(vout.Item), vout.PubKeyHash)
+		// outputs without contents hash as they did before aggregation
+		if len(vout.Contents) > 0 {
+			fields = append(fields, hashContents(vout.Contents))
+		}
+		if vout.Batch != nil {
+			fields = append(fields, vout.Batch.Hash())
+		}
+		if len(vout.ClaimHash) > 0 {
+			fields = append(fields, vout.ClaimHash)
+		}
+		if len(vout.Decommission) > 0 {
+			fields = append(fields, vout.Decommission)
+		}
+		if vout.IsOffered() {
+			fields = append(fields, vout.OfferTo, IntToHex(int64(vout.OfferExpiry)))
+		}
+	}
+
+	return HashFields(fields...)
 }
 
 // Sign signs each input of a Transaction
@@ -74,16 +94,30 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
 		txCopy.ID = txCopy.Hash()
 		txCopy.Vin[inID].PubKey = nil
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
-		if err != nil {
-			log.Panic(err)
-		}
-		signature := append(r.Bytes(), s.Bytes()...)
-
-		tx.Vin[inID].Signature = signature
+		tx.Vin[inID].Signature = signDigest(privKey, txCopy.ID)
 	}
 }
 
+// SignCoinbase signs a coinbase transaction with the key of the minting organisation
+func (tx *Transaction) SignCoinbase(privKey ecdsa.PrivateKey) {
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[0].PubKey = tx.Vin[0].PubKey
+	txCopy.ID = txCopy.Hash()
+
+	tx.Vin[0].Signature = signDigest(privKey, txCopy.ID)
+}
+
+// VerifyCoinbase verifies the minter signature of a coinbase transaction
+func (tx *Transaction) VerifyCoinbase() bool {
+	vin := tx.Vin[0]
+
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[0].PubKey = vin.PubKey
+	txCopy.ID = txCopy.Hash()
+
+	return verifyDigest(vin.PubKey, txCopy.ID, vin.Signature)
+}
+
 // String returns a human-readable representation of a transaction
 func (tx Transaction) String() string {
 	var lines []string
@@ -97,28 +131,61 @@ func (tx Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
 		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
 		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+		if len(input.Code) > 0 {
+			lines = append(lines, fmt.Sprintf("       Code:      %s", input.Code))
+		}
 	}
 
 	for i, output := range tx.Vout {
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
 		lines = append(lines, fmt.Sprintf("       ProductID:  %s", output.Item))
+		if len(output.Contents) > 0 {
+			lines = append(lines, fmt.Sprintf("       Contents: %s", formatContents(output.Contents)))
+		}
+		if output.Batch != nil {
+			lines = append(lines, fmt.Sprintf("       Batch: %s", output.Batch))
+		}
+		if len(output.ClaimHash) > 0 {
+			lines = append(lines, fmt.Sprintf("       Claim hash: %x", output.ClaimHash))
+		}
+		if len(output.Decommission) > 0 {
+			lines = append(lines, fmt.Sprintf("       Decommissioned: %s", output.Decommission))
+		}
+		if output.IsOffered() {
+			lines = append(lines, fmt.Sprintf("       Offered to: %s", Base58Encode(output.OfferTo)))
+		}
+		if output.OfferExpiry > 0 {
+			lines = append(lines, fmt.Sprintf("       Offer expiry: %d", output.OfferExpiry))
+		}
 		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
 	}
 
 	return strings.Join(lines, "\n")
 }
 
+// ComputeID returns the ID the Transaction is created with, the hash of its
+// contents before the inputs are signed
+func (tx *Transaction) ComputeID() []byte {
+	txCopy := tx.TrimmedCopy()
+
+	for i, vin := range tx.Vin {
+		txCopy.Vin[i].PubKey = vin.PubKey
+	}
+
+	return txCopy.Hash()
+}
+
 // TrimmedCopy creates a trimmed copy of Transaction to be used in signing
 func (tx *Transaction) TrimmedCopy() Transaction {
 	var inputs []TXInput
 	var outputs []TXOutput
 
 	for _, vin := range tx.Vin {
-		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil})
+		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil, vin.Code})
 	}
 
 	for _, vout := range tx.Vout {
-		outputs = append(outputs, TXOutput{vout.Index, vout.Item, vout.PubKeyHash})
+		outputs = append(outputs, TXOutput{vout.Index, vout.Item, vout.PubKeyHash, vout.Contents, vout.Batch, vout.ClaimHash, vout.Decommission, vout.OfferTo, vout.OfferExpiry})
 	}
 
 	txCopy := Transaction{tx.ID, inputs, outputs}
@@ -140,7 +207,6 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 	}
 
 	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
 
 	for inID, vin := range tx.Vin {
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
@@ -149,20 +215,7 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 		txCopy.ID = txCopy.Hash()
 		txCopy.Vin[inID].PubKey = nil
 
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
-
-		rawPubKey := ecdsa.PublicKey{curve, &x, &y}
-		if ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) == false {
+		if !verifyDigest(vin.PubKey, txCopy.ID, vin.Signature) {
 			return false
 		}
 	}
@@ -170,8 +223,9 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 	return true
 }
 
-// NewCoinbaseTX creates a new coinbase transaction
-func NewCoinbaseTX(utxoSet *UTXOSet, to string, subsidy []string, nodeID string) (*Transaction, error) {
+// NewCoinbaseTX creates a new coinbase transaction minting the next serial of
+// each product code. Every item minted carries batch, which may be nil.
+func NewCoinbaseTX(utxoSet *UTXOSet, to string, subsidy []string, batch *Batch, nodeID string) (*Transaction, error) {
 	var codes []int
 	var sgtin []string
 	var packetCode string
@@ -186,6 +240,9 @@ func NewCoinbaseTX(utxoSet *UTXOSet, to string, subsidy []string, nodeID string)
 	if bytes.Compare(r, []byte("Manufacturer")) != 0 {
 		return &Transaction{}, errors.New("Not authorized to perform this action")
 	}
+	if err := utxoSet.Blockchain.CheckActive(wallet.PublicKey); err != nil {
+		return &Transaction{}, err
+	}
 
 	for _, p := range subsidy {
 		i, err := strconv.Atoi(p)
@@ -196,49 +253,48 @@ func NewCoinbaseTX(utxoSet *UTXOSet, to string, subsidy []string, nodeID string)
 		}
 	}
 
+	// serials are generated from the chain, so repeated codes in one
+	// request are offset by the number already generated for that code
+	minted := make(map[int]int)
+
 	for i := range codes {
 		packetCode, err = utxoSet.Blockchain.GenerateSGTIN(to, wallet.PublicKey, codes[i])
 
 		if err != nil {
 			return &Transaction{}, err
-		} else {
-			sgtin = append(sgtin, packetCode)
-		}
-	}
-
-	data := ""
-
-	if data == "" {
-		randData := make([]byte, 20)
-		_, err := rand.Read(randData)
-		if err != nil {
-			log.Panic(err)
 		}
 
-		data = fmt.Sprintf("%x", randData)
+		prefix, code, serial, _ := ParseSGTIN(packetCode)
+		sgtin = append(sgtin, FormatSGTIN(prefix, code, serial+minted[code]))
+		minted[code]++
 	}
 
-	txin := TXInput{[]byte{}, -1, nil, []byte(data)}
-	txout := NewTXOutput(0, sgtin[0], to)
-	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}}
+	return NewMintTransaction(wallet, to, sgtin, batch), nil
+}
+
+// NewMintTransaction creates a coinbase transaction minting the given SGTINs
+// to address to. Every item minted carries batch, which may be nil.
+func NewMintTransaction(wallet Wallet, to string, items []string, batch *Batch) *Transaction {
+	txin := TXInput{[]byte{}, -1, nil, wallet.PublicKey, nil}
+	tx := Transaction{nil, []TXInput{txin}, nil}
 
-	for i, index := range sgtin[1:] {
-		//fmt.Println(i)
-		tx.Vout = append(tx.Vout, *NewTXOutput(i+1, index, to))
+	for i, item := range items {
+		txout := NewTXOutput(i, item, to)
+		txout.Batch = batch
+		tx.Vout = append(tx.Vout, *txout)
 	}
 
 	tx.ID = tx.Hash()
-	return &tx, nil
+	tx.SignCoinbase(wallet.PrivateKey)
+
+	return &tx
 }
 
 // NewUTXOTransaction creates a new transaction
 func NewUTXOTransaction(wallet Wallet, from string, to string,products []string, UTXOSet *UTXOSet) *Transaction {
 	var inputs []TXInput
+	contents := make(map[string][]PackedItem)
 
-	wallets, err := NewWallets()
-	if err != nil {
-		log.Panic(err)
-	}
 	pubKeyHash := HashPubKey(wallet.PublicKey)
 	found, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, products)
 
@@ -254,8 +310,11 @@ func NewUTXOTransaction(wallet Wallet, from string, to string,products []string,
 		}
 
 		for _, out := range outs {
-			input := TXInput{txID, out, nil, wallet.PublicKey}
+			input := TXInput{txID, out, nil, wallet.PublicKey, nil}
 			inputs = append(inputs, input)
+
+			spent, _ := UTXOSet.FindUnspentOutput(txID, out)
+			contents[spent.Item] = spent.Contents
 		}
 
 	}
@@ -269,6 +328,11 @@ func NewUTXOTransaction(wallet Wallet, from string, to string,products []string,
 		tx.Vout = append(tx.Vout, *NewTXOutput(i+1, index, to))
 	}
 
+	// logistic units are sent with the items packed in them
+	for i := range tx.Vout {
+		tx.Vout[i].Contents = contents[tx.Vout[i].Item]
+	}
+
 	tx.ID = tx.Hash()
 
 	//outputs = append(outputs, *NewTXOutput(product, to))
@@ -2,12 +2,14 @@ package main
 
 import "bytes"
 
-// TXInput represents a transaction input
+// TXInput represents a transaction input. Code is only set by a claim and
+// holds the claim code of the output it spends.
 type TXInput struct {
 	Txid      []byte
 	Vout      int
 	Signature []byte
 	PubKey    []byte
+	Code      []byte
 }
 
 // UsesKey checks whether the address initiated the transaction
@@ -6,11 +6,25 @@ import (
 	"log"
 )
 
-// TXOutput represents a transaction output
+// TXOutput represents a transaction output. Contents is set when Item is a
+// logistic unit and holds the items packed in it. Batch may only be set on the
+// outputs of a coinbase transaction. ClaimHash is set on items sold to a
+// consumer and holds the hash of their claim code. Decommission is set on items
+// taken out of circulation and holds the reason code; such an output cannot be
+// spent and is never added to the UTXO set. OfferTo is set on items offered to
+// another address, locked like PubKeyHash: they stay with the sender until
+// that address accepts or rejects them, or the sender reclaims them after
+// height OfferExpiry, if set.
 type TXOutput struct {
-	Index      int
-	Item       string
-	PubKeyHash []byte
+	Index        int
+	Item         string
+	PubKeyHash   []byte
+	Contents     []PackedItem
+	Batch        *Batch
+	ClaimHash    []byte
+	Decommission []byte
+	OfferTo      []byte
+	OfferExpiry  int
 }
 
 // Lock signs the output
@@ -27,9 +41,59 @@ func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
 	return bytes.Compare(pubKeyHashT, pubKeyHash) == 0
 }
 
+// IsLockedWithAnyKey checks if the output can be used by the owner of any of
+// the pubkey hashes, the keys an organisation held
+func (out *TXOutput) IsLockedWithAnyKey(pubKeyHashes [][]byte) bool {
+	for _, pubKeyHash := range pubKeyHashes {
+		if out.IsLockedWithKey(pubKeyHash) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsOffered checks whether the output is a pending offer to another address
+func (out *TXOutput) IsOffered() bool {
+	return len(out.OfferTo) > 0
+}
+
+// IsOfferedToAnyKey checks if the output is offered to the owner of any of the
+// pubkey hashes, the keys an organisation held
+func (out *TXOutput) IsOfferedToAnyKey(pubKeyHashes [][]byte) bool {
+	if !out.IsOffered() {
+		return false
+	}
+
+	offerTo := out.OfferTo[1 : len(out.OfferTo)-4]
+	for _, pubKeyHash := range pubKeyHashes {
+		if bytes.Compare(offerTo, pubKeyHash) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Recipient returns the address the output goes to: the address it is offered
+// to, if any, or else the one it is locked to
+func (out *TXOutput) Recipient() string {
+	if out.IsOffered() {
+		return string(Base58Encode(out.OfferTo))
+	}
+
+	return string(Base58Encode(out.PubKeyHash))
+}
+
+// IsSpendable checks whether the output can be spent, that is whether it was
+// not decommissioned
+func (out *TXOutput) IsSpendable() bool {
+	return len(out.Decommission) == 0
+}
+
 // NewTXOutput create a new TXOutput
 func NewTXOutput(seat int, product string, address string) *TXOutput {
-	txo := &TXOutput{seat, product, nil}
+	txo := &TXOutput{seat, product, nil, nil, nil, nil, nil, nil, 0}
 	txo.Lock([]byte(address))
 
 	return txo
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"log"
 )
@@ -17,6 +18,21 @@ func IntToHex(num int64) []byte {
 	return buff.Bytes()
 }
 
+// HashFields returns the SHA-256 hash of length-prefixed fields. Unlike a gob
+// encoding the result does not depend on the process that computes it.
+func HashFields(fields ...[]byte) []byte {
+	var data []byte
+
+	for _, field := range fields {
+		data = append(data, IntToHex(int64(len(field)))...)
+		data = append(data, field...)
+	}
+
+	hash := sha256.Sum256(data)
+
+	return hash[:]
+}
+
 // ReverseBytes reverses a byte array
 func ReverseBytes(data []byte) {
 	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
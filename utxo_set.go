@@ -1,24 +1,29 @@
 package main
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/hex"
-	"fmt"
 	"log"
+	"sort"
 
 	"github.com/boltdb/bolt"
 )
 
 const utxoBucket = "chainstate"
+const undoBucket = "undo"
 
 // UTXOSet represents UTXO set
 type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
-// FindSpendableOutputs finds and returns unspent outputs to reference in inputs
+// FindSpendableOutputs finds and returns unspent outputs to reference in
+// inputs, including those locked to former keys of the organisation of pubkeyHash
 func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, products []string) (int, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
 	found := 0
+	pubKeyHashes := u.Blockchain.GetKeyHashes(pubkeyHash)
 	db := u.Blockchain.db
 
 	err := db.View(func(tx *bolt.Tx) error {
@@ -34,7 +39,7 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, products []string) (int
 			for _, out := range outs.Outputs {
 
 				for _, product := range products {
-					if out.IsLockedWithKey(pubkeyHash) && found < len(products) && product == out.Item {
+					if out.IsLockedWithAnyKey(pubKeyHashes) && !out.IsOffered() && found < len(products) && product == out.Item {
 						found++
 						unspentOutputs[txID] = append(unspentOutputs[txID], out.Index)
 					}
@@ -53,9 +58,58 @@ func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, products []string) (int
 	return found, unspentOutputs
 }
 
-// FindUTXO finds UTXO for a public key hash
+// FindUnspentOutput returns the output at index of transaction txID if it is unspent
+func (u UTXOSet) FindUnspentOutput(txID []byte, index int) (TXOutput, bool) {
+	var output TXOutput
+	found := false
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		outsBytes := b.Get(txID)
+
+		if outsBytes == nil {
+			return nil
+		}
+
+		for _, out := range DeserializeOutputs(outsBytes).Outputs {
+			if out.Index == index {
+				output = out
+				found = true
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return output, found
+}
+
+// HasTransaction checks whether the transaction with ID txID has outputs in
+// the UTXO set
+func (u UTXOSet) HasTransaction(txID []byte) bool {
+	found := false
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket([]byte(utxoBucket)).Get(txID) != nil
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return found
+}
+
+// FindUTXO finds UTXO for a public key hash, including those locked to former
+// keys of its organisation
 func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 	var UTXOs []TXOutput
+	pubKeyHashes := u.Blockchain.GetKeyHashes(pubKeyHash)
 	db := u.Blockchain.db
 
 	err := db.View(func(tx *bolt.Tx) error {
@@ -67,7 +121,7 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 
 			for _, out := range outs.Outputs {
 				//fmt.Printf("BEFORE IF %+v\n", out)
-				if out.IsLockedWithKey(pubKeyHash) {
+				if out.IsLockedWithAnyKey(pubKeyHashes) {
 					UTXOs = append(UTXOs, out)
 					//fmt.Printf("%+v\n", out)
 
@@ -85,6 +139,61 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 	return UTXOs
 }
 
+// FindOffers finds the unspent outputs offered to a public key hash, including
+// those offered to former keys of its organisation
+func (u UTXOSet) FindOffers(pubKeyHash []byte) []TXOutput {
+	var offers []TXOutput
+	pubKeyHashes := u.Blockchain.GetKeyHashes(pubKeyHash)
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			for _, out := range DeserializeOutputs(v).Outputs {
+				if out.IsOfferedToAnyKey(pubKeyHashes) {
+					offers = append(offers, out)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return offers
+}
+
+// FindItem finds the unspent outputs carrying an item, on its own or packed in a
+// logistic unit, keyed by transaction ID
+func (u UTXOSet) FindItem(item string) map[string]TXOutput {
+	held := make(map[string]TXOutput)
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			for _, out := range DeserializeOutputs(v).Outputs {
+				if _, ok := out.Carries(item); ok {
+					held[hex.EncodeToString(k)] = out
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return held
+}
+
 // CountTransactions returns the number of transactions in the UTXO set
 func (u UTXOSet) CountTransactions() int {
 	db := u.Blockchain.db
@@ -151,14 +260,16 @@ func (u UTXOSet) Reindex() {
 }
 
 // Update updates the UTXO set with transactions from the Block
-// The Block is considered to be the tip1 of a blockchain
+// The Block is considered to be the tip of a blockchain. The outputs it spends
+// are recorded in the undo bucket so that Rollback can restore them.
 func (u UTXOSet) Update(block *Block) {
 	db := u.Blockchain.db
 
 	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
+		undo := BlockUndo{}
 
-		for _, tx := range block.Transactions {
+		for _, tx := range block.Transactions() {
 			if tx.IsCoinbase() == false {
 
 				for _, vin := range tx.Vin {
@@ -170,6 +281,8 @@ func (u UTXOSet) Update(block *Block) {
 
 						if out.Index != vin.Vout {
 							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
+						} else {
+							undo.Spent = append(undo.Spent, SpentOutput{vin.Txid, out})
 						}
 					}
 
@@ -191,17 +304,23 @@ func (u UTXOSet) Update(block *Block) {
 			newOutputs := TXOutputs{}
 
 			for _, out := range tx.Vout {
-				newOutputs.Outputs = append(newOutputs.Outputs, out)
+				if out.IsSpendable() {
+					newOutputs.Outputs = append(newOutputs.Outputs, out)
+				}
+			}
+			if len(newOutputs.Outputs) == 0 {
+				continue
 			}
-			fmt.Printf("ID: %x \n", tx.ID)
-			fmt.Printf("Serial: %x \n", newOutputs.Serialize())
-			err := b.Put(tx.ID, newOutputs.Serialize())
 
+			err := b.Put(tx.ID, newOutputs.Serialize())
 			if err != nil {
-				fmt.Printf("INSIDE \n")
 				log.Panic(err)
 			}
-			fmt.Printf("TEST \n")
+		}
+
+		err := tx.Bucket([]byte(undoBucket)).Put(block.Hash, undo.Serialize())
+		if err != nil {
+			log.Panic(err)
 		}
 
 		return nil
@@ -211,3 +330,90 @@ func (u UTXOSet) Update(block *Block) {
 		log.Panic(err)
 	}
 }
+
+// Rollback reverts the changes Update made for the Block, which must be the
+// tip of the blockchain. It returns false if no undo data was recorded.
+func (u UTXOSet) Rollback(block *Block) bool {
+	db := u.Blockchain.db
+	found := false
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		ub := tx.Bucket([]byte(undoBucket))
+
+		undoData := ub.Get(block.Hash)
+		if undoData == nil {
+			return nil
+		}
+		found = true
+		undo := DeserializeBlockUndo(undoData)
+
+		for _, tx := range block.Transactions() {
+			err := b.Delete(tx.ID)
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		for _, spent := range undo.Spent {
+			outs := TXOutputs{}
+			if outsBytes := b.Get(spent.Txid); outsBytes != nil {
+				outs = DeserializeOutputs(outsBytes)
+			}
+
+			outs.Outputs = append(outs.Outputs, spent.Output)
+			sort.Slice(outs.Outputs, func(i, j int) bool {
+				return outs.Outputs[i].Index < outs.Outputs[j].Index
+			})
+
+			err := b.Put(spent.Txid, outs.Serialize())
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		return ub.Delete(block.Hash)
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return found
+}
+
+// SpentOutput is an output removed from the UTXO set by a block
+type SpentOutput struct {
+	Txid   []byte
+	Output TXOutput
+}
+
+// BlockUndo records the outputs spent by a block
+type BlockUndo struct {
+	Spent []SpentOutput
+}
+
+// Serialize serializes BlockUndo
+func (undo BlockUndo) Serialize() []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(undo)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// DeserializeBlockUndo deserializes BlockUndo
+func DeserializeBlockUndo(data []byte) BlockUndo {
+	var undo BlockUndo
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&undo)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return undo
+}
@@ -0,0 +1,1281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// outpoint identifies a transaction output referenced by an input
+func outpoint(txID []byte, index int) string {
+	return fmt.Sprintf("%s:%d", hex.EncodeToString(txID), index)
+}
+
+// validateOutputs checks that every output is well formed and that no SGTIN or
+// SSCC is carried twice by the transaction. It returns the items carried by the
+// outputs, mapped to the hash of the items packed in them.
+func validateOutputs(tx *Transaction) (map[string]string, error) {
+	if len(tx.Vout) == 0 {
+		return nil, errors.New("Transaction has no outputs")
+	}
+
+	for i, out := range tx.Vout {
+		if out.Index != i {
+			return nil, fmt.Errorf("Output %d has index %d", i, out.Index)
+		}
+		if len(out.PubKeyHash) <= addressChecksumLen+1 {
+			return nil, fmt.Errorf("Output %d is not locked to a valid address", i)
+		}
+		for _, item := range out.CarriedItems() {
+			if item == "" {
+				return nil, fmt.Errorf("Output %d carries no item", i)
+			}
+		}
+	}
+
+	return carriedContents(tx.Vout)
+}
+
+// ValidateTransaction checks a transaction against the item transfer rules.
+// Its ID must be the one computed from its contents and must not be in the
+// chainstate bucket yet. Every input must spend an output of the chainstate
+// bucket owned by the signer or locked to a former key of its organisation,
+// an item waiting to be claimed with its claim code or an offer its signer may
+// answer. The outputs must carry the items of the inputs as checked by
+// ValidatePacking, no SGTIN may be referenced twice, sales and claims must
+// follow ValidateClaims, decommissionings ValidateDecommission, offers and
+// their answers ValidateOffers and the transfer must follow the transfer
+// policy.
+func (u UTXOSet) ValidateTransaction(tx *Transaction) error {
+	// the UTXO set is keyed by ID, so a forged or reused one would replace
+	// the outputs of another transaction
+	if bytes.Compare(tx.ID, tx.ComputeID()) != 0 {
+		return errors.New("ID does not match the transaction")
+	}
+	if u.HasTransaction(tx.ID) {
+		return fmt.Errorf("Transaction %x is already recorded", tx.ID)
+	}
+
+	outItems, err := validateOutputs(tx)
+	if err != nil {
+		return err
+	}
+
+	if tx.IsCoinbase() {
+		return u.ValidateCoinbase(tx)
+	}
+	if len(tx.Vin) == 0 {
+		return errors.New("Transaction has no inputs")
+	}
+
+	for _, out := range tx.Vout {
+		if out.Batch != nil {
+			return fmt.Errorf("Item %s is given a batch outside its coinbase", out.Item)
+		}
+	}
+
+	spent := make(map[string]bool)
+	var spentOutputs []TXOutput
+
+	for _, vin := range tx.Vin {
+		key := outpoint(vin.Txid, vin.Vout)
+		if spent[key] {
+			return fmt.Errorf("Output %s is spent twice", key)
+		}
+		spent[key] = true
+
+		out, found := u.FindUnspentOutput(vin.Txid, vin.Vout)
+		if !found {
+			return fmt.Errorf("Output %s is not in the UTXO set", key)
+		}
+		if len(out.ClaimHash) > 0 {
+			if len(vin.Code) == 0 {
+				return fmt.Errorf("Item %s is waiting to be claimed", out.Item)
+			}
+			if bytes.Compare(HashClaimCode(out.Item, string(vin.Code)), out.ClaimHash) != 0 {
+				return fmt.Errorf("Claim code of item %s is not valid", out.Item)
+			}
+		} else if out.IsOffered() {
+			err := u.Blockchain.checkOfferSigner(out, vin.PubKey)
+			if err != nil {
+				return err
+			}
+		} else if !out.IsLockedWithAnyKey(u.Blockchain.GetKeyHashes(HashPubKey(vin.PubKey))) {
+			return fmt.Errorf("Output %s is not owned by the signer", key)
+		}
+		spentOutputs = append(spentOutputs, out)
+	}
+
+	inItems, err := carriedContents(spentOutputs)
+	if err != nil {
+		return err
+	}
+
+	err = u.Blockchain.ValidatePacking(tx, inItems, outItems)
+	if err != nil {
+		return err
+	}
+
+	err = u.Blockchain.ValidateClaims(tx, spentOutputs)
+	if err != nil {
+		return err
+	}
+
+	err = u.Blockchain.ValidateDecommission(tx, spentOutputs)
+	if err != nil {
+		return err
+	}
+
+	err = u.Blockchain.ValidateOffers(tx, spentOutputs)
+	if err != nil {
+		return err
+	}
+
+	if !u.Blockchain.VerifyTransaction(tx) {
+		return errors.New("Invalid signature")
+	}
+
+	// answers to offers were checked against the transfer policy when offered
+	if spentOutputs[0].IsOffered() {
+		return u.Blockchain.CheckActive(tx.Vin[0].PubKey)
+	}
+
+	return u.Blockchain.ValidateTransfer(tx)
+}
+
+// ValidatePacking checks the items carried by the outputs of a transaction
+// against those of the outputs it spends, both mapped to the hash of the items
+// packed in them. Every item keeps the items packed in it, except that logistic
+// units may be unpacked and new ones packed. A new unit must not be empty and
+// must carry an SSCC under the prefix of the signer that was never used before.
+func (bc *Blockchain) ValidatePacking(tx *Transaction, inItems, outItems map[string]string) error {
+	empty := hex.EncodeToString(hashContents(nil))
+
+	for item, contents := range outItems {
+		if spent, ok := inItems[item]; ok {
+			if spent != contents {
+				return fmt.Errorf("Item %s does not keep the items packed in it", item)
+			}
+			continue
+		}
+
+		prefix, _, err := ParseSSCC(item)
+		if err != nil {
+			return fmt.Errorf("Item %s is not carried by any input", item)
+		}
+		if contents == empty {
+			return fmt.Errorf("Logistic unit %s packs no items", item)
+		}
+
+		org, err := bc.FindOrganisationByPublicKey(tx.Vin[0].PubKey)
+		if err != nil {
+			return errors.New("Packer is not a registered organisation")
+		}
+		if prefix != string(org.Prefix) {
+			return fmt.Errorf("Logistic unit %s does not carry the prefix %s of its packer", item, org.Prefix)
+		}
+		if bc.IsPacked(item) {
+			return fmt.Errorf("Logistic unit %s has already been packed", item)
+		}
+	}
+
+	for item := range inItems {
+		if _, ok := outItems[item]; ok {
+			continue
+		}
+		if _, _, err := ParseSSCC(item); err != nil {
+			return fmt.Errorf("Item %s is not carried by any output", item)
+		}
+	}
+
+	return nil
+}
+
+// ValidateClaims checks the sales of items to consumers and their claims, given
+// the outputs spent by the transaction. Only SGTINs may be sold, they stay with
+// their seller until claimed, and the role of the seller must be allowed to
+// send items to consumers. A claim spends nothing but items waiting to be
+// claimed and must be signed by a single consumer, who receives them.
+func (bc *Blockchain) ValidateClaims(tx *Transaction, spent []TXOutput) error {
+	seller := tx.Vin[0].PubKey
+
+	for _, out := range tx.Vout {
+		if len(out.ClaimHash) == 0 {
+			continue
+		}
+
+		if _, _, _, err := ParseSGTIN(out.Item); err != nil || len(out.Contents) > 0 {
+			return fmt.Errorf("Item %s is not an SGTIN and cannot be sold to consumers", out.Item)
+		}
+		if !out.IsLockedWithKey(HashPubKey(seller)) {
+			return fmt.Errorf("Item %s must stay with its seller until claimed", out.Item)
+		}
+		err := bc.checkConsumerSale(seller)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !tx.IsClaim() {
+		return nil
+	}
+
+	claimant := tx.Vin[0].PubKey
+	for i, vin := range tx.Vin {
+		if len(spent[i].ClaimHash) == 0 {
+			return fmt.Errorf("Output %s is not waiting to be claimed", outpoint(vin.Txid, vin.Vout))
+		}
+		if bytes.Compare(vin.PubKey, claimant) != 0 {
+			return errors.New("Claim is signed by more than one key")
+		}
+	}
+
+	if org, err := bc.FindOrganisationByPublicKey(claimant); err == nil {
+		return fmt.Errorf("Only consumers can claim items, %s is an organisation", org.Name)
+	}
+	for _, out := range tx.Vout {
+		if !out.IsLockedWithKey(HashPubKey(claimant)) || len(out.ClaimHash) > 0 {
+			return fmt.Errorf("Claimed item %s must go to its claimant", out.Item)
+		}
+	}
+
+	return nil
+}
+
+// checkConsumerSale checks that the transfer policy allows the role of seller
+// to send items to consumers
+func (bc *Blockchain) checkConsumerSale(seller []byte) error {
+	policy := bc.GetTransferPolicy()
+	if policy == nil {
+		return nil
+	}
+
+	role := bc.GetRole(seller)
+	if role == nil {
+		role = []byte(consumerRole)
+	}
+
+	return policy.Check(role, []byte(consumerRole))
+}
+
+// ValidateDecommission checks a transaction taking items out of circulation,
+// given the outputs it spends. Every output must then be decommissioned with a
+// known reason code and record an item spent by the transaction, left with
+// its signer. Items may not be sold or claimed at the same time, and only
+// roles allowed to send items to consumers may decommission them as sold.
+func (bc *Blockchain) ValidateDecommission(tx *Transaction, spent []TXOutput) error {
+	if !tx.IsDecommission() {
+		return nil
+	}
+	if tx.IsClaim() {
+		return errors.New("A claim cannot decommission items")
+	}
+
+	signer := tx.Vin[0].PubKey
+	spentItems := make(map[string]bool)
+	for _, out := range spent {
+		spentItems[out.Item] = true
+	}
+
+	for _, out := range tx.Vout {
+		reason := string(out.Decommission)
+		if out.IsSpendable() {
+			return fmt.Errorf("Item %s is not decommissioned with the other items of the transaction", out.Item)
+		}
+		if !IsDecommissionReason(reason) {
+			return fmt.Errorf("Reason %s is not one of %s", reason, strings.Join(decommissionReasons, ", "))
+		}
+		if !spentItems[out.Item] {
+			return fmt.Errorf("Item %s is not carried by any input", out.Item)
+		}
+		if len(out.ClaimHash) > 0 {
+			return fmt.Errorf("Item %s cannot be sold and decommissioned at once", out.Item)
+		}
+		if !out.IsLockedWithKey(HashPubKey(signer)) {
+			return fmt.Errorf("Decommissioned item %s must stay with its signer", out.Item)
+		}
+
+		if reason == soldToConsumerReason {
+			err := bc.checkConsumerSale(signer)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkOfferSigner checks that pubKey may spend an offered output: the
+// address it is offered to until the offer expires, and its sender afterwards
+func (bc *Blockchain) checkOfferSigner(out TXOutput, pubKey []byte) error {
+	pubKeyHashes := bc.GetKeyHashes(HashPubKey(pubKey))
+	recipient := Base58Encode(out.OfferTo)
+	expired := bc.IsOfferExpired(out)
+
+	switch {
+	case out.IsOfferedToAnyKey(pubKeyHashes) && expired:
+		return fmt.Errorf("Offer of item %s expired at height %d", out.Item, out.OfferExpiry)
+	case out.IsOfferedToAnyKey(pubKeyHashes):
+		return nil
+	case out.IsLockedWithAnyKey(pubKeyHashes) && out.OfferExpiry == 0:
+		return fmt.Errorf("Item %s is offered to %s until accepted or rejected", out.Item, recipient)
+	case out.IsLockedWithAnyKey(pubKeyHashes) && !expired:
+		return fmt.Errorf("Item %s is offered to %s until height %d", out.Item, recipient, out.OfferExpiry)
+	case out.IsLockedWithAnyKey(pubKeyHashes):
+		return nil
+	}
+
+	return fmt.Errorf("Item %s is offered to %s", out.Item, recipient)
+}
+
+// ValidateOffers checks offers of items and their answers, given the outputs
+// spent by the transaction. An offered item stays with its sender and must be
+// offered to another valid address, until a height that is not past. A
+// transaction answering offers spends nothing else and is signed by a single
+// key: each item goes to the signer if it accepts the offer, or back to the
+// sender if it rejects or reclaims it, and is not offered, sold or
+// decommissioned at the same time.
+func (bc *Blockchain) ValidateOffers(tx *Transaction, spent []TXOutput) error {
+	signer := tx.Vin[0].PubKey
+	signerHashes := bc.GetKeyHashes(HashPubKey(signer))
+
+	for _, out := range tx.Vout {
+		if !out.IsOffered() {
+			continue
+		}
+
+		if !ValidateAddress(string(Base58Encode(out.OfferTo))) {
+			return fmt.Errorf("Item %s is not offered to a valid address", out.Item)
+		}
+		if !out.IsLockedWithKey(HashPubKey(signer)) {
+			return fmt.Errorf("Offered item %s must stay with its sender until accepted", out.Item)
+		}
+		if out.IsOfferedToAnyKey(signerHashes) {
+			return fmt.Errorf("Item %s is offered to its own sender", out.Item)
+		}
+		if len(out.ClaimHash) > 0 || !out.IsSpendable() {
+			return fmt.Errorf("Offered item %s cannot be sold or decommissioned", out.Item)
+		}
+		if next := bc.GetBestHeight() + 1; out.OfferExpiry != 0 && out.OfferExpiry < next {
+			return fmt.Errorf("Offer of item %s expires at height %d, before the next block %d", out.Item, out.OfferExpiry, next)
+		}
+	}
+
+	if !spent[0].IsOffered() {
+		for i, out := range spent {
+			if out.IsOffered() {
+				return fmt.Errorf("Offered output %s must be answered on its own", outpoint(tx.Vin[i].Txid, tx.Vin[i].Vout))
+			}
+		}
+		return nil
+	}
+
+	offers := make(map[string]TXOutput)
+	for i, out := range spent {
+		if !out.IsOffered() {
+			return fmt.Errorf("Output %s is not offered", outpoint(tx.Vin[i].Txid, tx.Vin[i].Vout))
+		}
+		if bytes.Compare(tx.Vin[i].PubKey, signer) != 0 {
+			return errors.New("Answer to offers is signed by more than one key")
+		}
+		offers[out.Item] = out
+	}
+
+	if len(tx.Vout) != len(spent) {
+		return errors.New("Answer to offers must carry each offered item once")
+	}
+	for _, out := range tx.Vout {
+		offer, ok := offers[out.Item]
+		if !ok {
+			return fmt.Errorf("Item %s is not offered", out.Item)
+		}
+		if out.IsOffered() || len(out.ClaimHash) > 0 || !out.IsSpendable() {
+			return fmt.Errorf("Item %s cannot be offered, sold or decommissioned in an answer to its offer", out.Item)
+		}
+
+		returned := bytes.Compare(out.PubKeyHash, offer.PubKeyHash) == 0
+		accepted := offer.IsOfferedToAnyKey(signerHashes) && out.IsLockedWithKey(HashPubKey(signer))
+		if !returned && !accepted {
+			return fmt.Errorf("Offered item %s must go to its recipient or back to its sender", out.Item)
+		}
+	}
+
+	return nil
+}
+
+// ValidateClaimAttempt checks that a claim attempt is signed by a consumer,
+// other than the last one who claimed the item, that it gives a claim code and
+// that the item was claimed and is not waiting to be claimed again. Attempts
+// with a wrong code are recorded too.
+func (bc *Blockchain) ValidateClaimAttempt(attempt *ClaimAttempt) error {
+	if !attempt.Verify() {
+		return errors.New("Claim attempt has an invalid signature")
+	}
+	if len(attempt.Code) == 0 {
+		return errors.New("Claim attempt gives no claim code")
+	}
+
+	if org, err := bc.FindOrganisationByPublicKey(attempt.PubKey); err == nil {
+		return fmt.Errorf("Only consumers can claim items, %s is an organisation", org.Name)
+	}
+
+	item := string(attempt.Item)
+	claim, _, err := bc.FindClaim(item)
+	if err != nil {
+		return fmt.Errorf("Item %s was never claimed", item)
+	}
+	for _, out := range (UTXOSet{bc}).FindItem(item) {
+		if len(out.ClaimHash) > 0 {
+			return fmt.Errorf("Item %s is waiting to be claimed", item)
+		}
+	}
+	if bytes.Compare(claim.Vin[0].PubKey, attempt.PubKey) == 0 {
+		return fmt.Errorf("Item %s was already claimed by %s", item, GetAddressFromPubKey(attempt.PubKey))
+	}
+	return nil
+}
+
+// ValidateTransfer checks that the signers and recipients of a transaction
+// are not suspended or revoked, that the signers hold the current keys of
+// their organisations and that their roles are allowed by the transfer policy.
+// Items sent back to any address of the signer are not transfers, and the
+// recipient of an offered item is the address it is offered to. The registry
+// is read once, and each signer and recipient is looked up once.
+func (bc *Blockchain) ValidateTransfer(tx *Transaction) error {
+	var senders, recipients []transferParty
+	registry := newTransferRegistry(bc)
+	seen := make(map[string]bool)
+
+	for _, vin := range tx.Vin {
+		address := string(GetAddressFromPubKey(vin.PubKey))
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+
+		sender, err := registry.party(address, vin.PubKey)
+		if err != nil {
+			return err
+		}
+		senders = append(senders, sender)
+	}
+
+	seen = make(map[string]bool)
+	for _, out := range tx.Vout {
+		address := out.Recipient()
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+
+		recipient, err := registry.party(address, nil)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	policy := bc.GetTransferPolicy()
+	if policy == nil {
+		return nil
+	}
+
+	for _, sender := range senders {
+		for _, recipient := range recipients {
+			if containsAddress(sender.addresses, recipient.address) {
+				continue
+			}
+
+			err := policy.Check(sender.role, recipient.role)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// transferParty is a signer or recipient of a transaction with its role and
+// every address its organisation held
+type transferParty struct {
+	address   string
+	role      []byte
+	addresses []string
+}
+
+// transferRegistry is the registry the parties of a transaction are checked
+// against for the next block
+type transferRegistry struct {
+	orgs      []Organisation
+	rotations []KeyRotation
+	changes   []StatusChange
+	height    int
+}
+
+func newTransferRegistry(bc *Blockchain) transferRegistry {
+	return transferRegistry{bc.GetOrganisations(), bc.GetKeyRotations(), bc.GetStatusChanges(), bc.GetBestHeight() + 1}
+}
+
+// party resolves the holder of address, returning an error if its organisation
+// is suspended or revoked, or if pubKey, the key it signs with unless nil, was
+// rotated away from. Addresses of no organisation are consumers.
+func (r transferRegistry) party(address string, pubKey []byte) (transferParty, error) {
+	org, ok := findOrganisationByAddress(address, r.orgs, r.rotations)
+	if !ok {
+		return transferParty{address, []byte(consumerRole), []string{address}}, nil
+	}
+
+	keys := keysOf(r.rotations, org.PubKey)
+	if pubKey != nil && bytes.Compare(keys[len(keys)-1], pubKey) != 0 {
+		return transferParty{}, fmt.Errorf("Key of organisation %s at %s has been rotated", org.Name, address)
+	}
+
+	status, change := findStatus(r.changes, org.PubKey, r.height)
+	if status != activeStatus {
+		return transferParty{}, fmt.Errorf("Organisation %s is %s: %s", org.Name, status, change.Reason)
+	}
+
+	return transferParty{address, org.Role, addressesOf(keys)}, nil
+}
+
+// ValidateCoinbase checks the minting rules of a coinbase transaction. It must be
+// signed by a registered Manufacturer that is active and every SGTIN must carry the prefix of
+// that manufacturer, a product code from its catalog and a serial that has never
+// been minted before. Its batch, if any, must be well formed.
+func (u UTXOSet) ValidateCoinbase(tx *Transaction) error {
+	bc := u.Blockchain
+	pubKey := tx.Vin[0].PubKey
+
+	if !tx.VerifyCoinbase() {
+		return errors.New("Coinbase is not signed by its minter")
+	}
+
+	org, err := bc.FindOrganisationByPublicKey(pubKey)
+	if err != nil {
+		return errors.New("Minter is not a registered organisation")
+	}
+	if bytes.Compare(org.Role, []byte("Manufacturer")) != 0 {
+		return fmt.Errorf("Minter %s is not a Manufacturer", org.Name)
+	}
+	if err := bc.CheckActive(pubKey); err != nil {
+		return err
+	}
+
+	address := string(GetAddressFromPubKey(pubKey))
+
+	for _, out := range tx.Vout {
+		prefix, code, _, err := ParseSGTIN(out.Item)
+		if err != nil {
+			return fmt.Errorf("Item %s: %s", out.Item, err)
+		}
+		if len(out.Contents) > 0 {
+			return fmt.Errorf("Item %s is minted with items packed in it", out.Item)
+		}
+		if prefix != string(org.Prefix) {
+			return fmt.Errorf("Item %s does not carry the prefix %s of its minter", out.Item, org.Prefix)
+		}
+		product, err := bc.FindCatalogProduct(address, code)
+		if err != nil {
+			return fmt.Errorf("Product code %d is not in the catalog of %s", code, org.Name)
+		}
+		if product.Discontinued() {
+			return fmt.Errorf("Product code %d of %s is discontinued", code, org.Name)
+		}
+		if bc.IsMinted(out.Item) {
+			return fmt.Errorf("Item %s has already been minted", out.Item)
+		}
+		if len(out.ClaimHash) > 0 {
+			return fmt.Errorf("Item %s is minted waiting to be claimed", out.Item)
+		}
+		if !out.IsSpendable() {
+			return fmt.Errorf("Item %s is minted decommissioned", out.Item)
+		}
+		if out.IsOffered() {
+			return fmt.Errorf("Item %s is minted offered", out.Item)
+		}
+		if out.Batch != nil {
+			if err := out.Batch.Validate(); err != nil {
+				return fmt.Errorf("Item %s: %s", out.Item, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateProducts checks catalog entries: each must be signed by a registered
+// Manufacturer that is active and use a code that manufacturer has not registered yet
+func (bc *Blockchain) ValidateProducts(products []*Product) error {
+	codes := make(map[string]bool)
+
+	for _, p := range products {
+		if p.Code < 1 {
+			return fmt.Errorf("Product %s has invalid code %d", p.Name, p.Code)
+		}
+		if bytes.Compare(bc.GetRole(p.PubKey), []byte("Manufacturer")) != 0 {
+			return fmt.Errorf("Product %s is not registered by a Manufacturer", p.Name)
+		}
+		if err := bc.CheckActive(p.PubKey); err != nil {
+			return err
+		}
+
+		address := string(GetAddressFromPubKey(p.PubKey))
+		if !p.Verify(Base58Decode([]byte(address))) {
+			return fmt.Errorf("Product %s has an invalid signature", p.Name)
+		}
+		if p.Attributes != nil {
+			if err := p.Attributes.Validate(); err != nil {
+				return fmt.Errorf("Product %s: %s", p.Name, err)
+			}
+			org, _ := bc.FindOrganisationByPublicKey(p.PubKey)
+			if err := checkGTIN(p.Attributes, org.Prefix, p.Code); err != nil {
+				return fmt.Errorf("Product %s: %s", p.Name, err)
+			}
+		}
+
+		key := fmt.Sprintf("%s:%d", address, p.Code)
+		if _, err := bc.FindProductByCode(address, p.Code); err == nil || codes[key] {
+			return fmt.Errorf("Product code %d is already registered", p.Code)
+		}
+		codes[key] = true
+	}
+
+	return nil
+}
+
+// ValidateCatalogUpdate checks a new version of a catalog entry: it must be
+// signed by the current key of the active Manufacturer whose catalog holds the
+// product, follow its current version and not change a discontinued product
+func (bc *Blockchain) ValidateCatalogUpdate(update *CatalogUpdate) error {
+	if !update.Verify() {
+		return errors.New("Catalog update has an invalid signature")
+	}
+
+	org, err := bc.FindOrganisationByPublicKey(update.PubKey)
+	if err != nil {
+		return errors.New("Catalog update is not signed by a registered organisation")
+	}
+	if bytes.Compare(org.Role, []byte("Manufacturer")) != 0 {
+		return fmt.Errorf("Catalog update is signed by %s, which is not a Manufacturer", org.Name)
+	}
+	if bytes.Compare(org.Prefix, update.Prefix) != 0 {
+		return fmt.Errorf("Catalog update prefix %s is not the prefix of %s", update.Prefix, org.Name)
+	}
+	if err := bc.checkCurrentKey(update.PubKey); err != nil {
+		return err
+	}
+	if err := bc.CheckActive(update.PubKey); err != nil {
+		return err
+	}
+
+	address := string(GetAddressFromPubKey(update.PubKey))
+	product, err := bc.FindCatalogProduct(address, update.Code)
+	if err != nil {
+		return fmt.Errorf("Product code %d is not in the catalog of %s", update.Code, org.Name)
+	}
+	if product.Discontinued() {
+		return fmt.Errorf("Product code %d of %s is discontinued", update.Code, org.Name)
+	}
+	if version := product.Current().Version + 1; update.Version != version {
+		return fmt.Errorf("Catalog update of product code %d has version %d instead of %d", update.Code, update.Version, version)
+	}
+
+	switch string(update.Action) {
+	case updateAction:
+		if len(update.Name) == 0 {
+			return fmt.Errorf("Catalog update of product code %d has no name", update.Code)
+		}
+		if update.Attributes != nil {
+			if err := update.Attributes.Validate(); err != nil {
+				return err
+			}
+		}
+		return checkGTIN(update.Attributes, org.Prefix, update.Code)
+	case discontinueAction:
+		if len(update.Name) > 0 || update.Attributes != nil {
+			return fmt.Errorf("Discontinuation of product code %d cannot change its name or attributes", update.Code)
+		}
+		if len(update.Reason) == 0 {
+			return fmt.Errorf("Discontinuation of product code %d has no reason", update.Code)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Unknown catalog action %s", update.Action)
+}
+
+// ValidateOrganisation checks an organisation registration: it must be signed by
+// an Admin and must not reuse the GSTIN, prefix or key of another organisation
+func (bc *Blockchain) ValidateOrganisation(org *Organisation) error {
+	if len(org.Name) == 0 || len(org.Role) == 0 || len(org.PubKey) == 0 {
+		return errors.New("Organisation is missing name, role or key")
+	}
+	if bytes.Compare(bc.GetRole(org.AdminPubKey), []byte("Admin")) != 0 {
+		return fmt.Errorf("Organisation %s is not registered by an Admin", org.Name)
+	}
+	if len(org.Signature) == 0 || !org.Verify(Base58Decode(GetAddressFromPubKey(org.AdminPubKey))) {
+		return fmt.Errorf("Organisation %s has an invalid signature", org.Name)
+	}
+	if bc.isOrgDuplicate(org.GSTIN, org.Prefix, org.PubKey) {
+		return fmt.Errorf("Organisation %s duplicates a registered organisation", org.Name)
+	}
+
+	return nil
+}
+
+// ValidateRecall checks a recall: it must be signed by the current key of the
+// registered Manufacturer owning its prefix and cover items of that manufacturer's catalog
+func (bc *Blockchain) ValidateRecall(recall *Recall) error {
+	if !recall.Verify() {
+		return errors.New("Recall has an invalid signature")
+	}
+
+	org, err := bc.FindOrganisationByPublicKey(recall.PubKey)
+	if err != nil {
+		return errors.New("Recall is not signed by a registered organisation")
+	}
+	if bytes.Compare(org.Role, []byte("Manufacturer")) != 0 {
+		return fmt.Errorf("Recall is signed by %s, which is not a Manufacturer", org.Name)
+	}
+	if bytes.Compare(org.Prefix, recall.Prefix) != 0 {
+		return fmt.Errorf("Recall prefix %s is not the prefix of %s", recall.Prefix, org.Name)
+	}
+	if err := bc.checkCurrentKey(recall.PubKey); err != nil {
+		return err
+	}
+	if len(recall.Reason) == 0 {
+		return errors.New("Recall has no reason")
+	}
+
+	address := string(GetAddressFromPubKey(recall.PubKey))
+
+	if len(recall.Items) > 0 {
+		if recall.Code != 0 || recall.FromSerial != 0 || recall.ToSerial != 0 {
+			return errors.New("Recall of listed items cannot also set a product code or serials")
+		}
+		for _, item := range recall.Items {
+			prefix, _, _, err := ParseSGTIN(item)
+			if err != nil {
+				return fmt.Errorf("Item %s: %s", item, err)
+			}
+			if prefix != string(org.Prefix) {
+				return fmt.Errorf("Item %s does not carry the prefix %s", item, org.Prefix)
+			}
+			if !bc.IsMinted(item) {
+				return fmt.Errorf("Item %s has not been minted", item)
+			}
+		}
+	} else {
+		if _, err := bc.FindProductByCode(address, recall.Code); err != nil {
+			return fmt.Errorf("Product code %d is not in the catalog of %s", recall.Code, org.Name)
+		}
+		if recall.FromSerial != 0 || recall.ToSerial != 0 {
+			if recall.FromSerial < 1 || recall.ToSerial < recall.FromSerial {
+				return fmt.Errorf("Serial range %d to %d is not valid", recall.FromSerial, recall.ToSerial)
+			}
+		}
+	}
+
+	for _, other := range bc.GetRecalls() {
+		if bytes.Compare(other.ID, recall.ID) == 0 {
+			return fmt.Errorf("Recall %x is already recorded", recall.ID)
+		}
+	}
+
+	return nil
+}
+
+// ValidateStatusChange checks a status change: it must be signed by an Admin,
+// concern an organisation that is not an Admin and take effect at the next
+// block or later, not before the last change of that organisation. Only active
+// organisations may be suspended, only suspended ones reinstated, and revoked
+// ones cannot change any more.
+func (bc *Blockchain) ValidateStatusChange(change *StatusChange) error {
+	if !change.Verify() {
+		return errors.New("Status change has an invalid signature")
+	}
+	if bytes.Compare(bc.GetRole(change.PubKey), []byte("Admin")) != 0 {
+		return errors.New("Status change is not signed by an Admin")
+	}
+
+	org, err := bc.FindOrganisationByPublicKey(change.OrgPubKey)
+	if err != nil {
+		return errors.New("Status change is not for a registered organisation")
+	}
+	if bytes.Compare(org.Role, []byte("Admin")) == 0 {
+		return fmt.Errorf("Admin %s cannot be suspended or revoked", org.Name)
+	}
+
+	action := string(change.Action)
+	if _, ok := statusOf[action]; !ok {
+		return fmt.Errorf("Action %q is not %s, %s or %s", action, suspendAction, reinstateAction, revokeAction)
+	}
+	if len(change.Reason) == 0 {
+		return errors.New("Status change has no reason")
+	}
+	if next := bc.GetBestHeight() + 1; change.Height < next {
+		return fmt.Errorf("Effective height %d is before the next block %d", change.Height, next)
+	}
+
+	changes := bc.GetStatusChanges()
+	for _, other := range changes {
+		if bytes.Compare(other.ID, change.ID) == 0 {
+			return fmt.Errorf("Status change %x is already recorded", change.ID)
+		}
+	}
+
+	// changes are recorded in order of effect, so the last one sets the
+	// status the next one starts from even if it is not effective yet
+	status, last := findStatus(changes, change.OrgPubKey, math.MaxInt32)
+	if last != nil && change.Height < last.Height {
+		return fmt.Errorf("Effective height %d is before the last change of %s at height %d", change.Height, org.Name, last.Height)
+	}
+
+	switch {
+	case status == revokedStatus:
+		return fmt.Errorf("Organisation %s is revoked", org.Name)
+	case action == suspendAction && status != activeStatus:
+		return fmt.Errorf("Organisation %s is not active", org.Name)
+	case action == reinstateAction && status != suspendedStatus:
+		return fmt.Errorf("Organisation %s is not suspended", org.Name)
+	}
+
+	return nil
+}
+
+// ValidateKeyRotation checks a key rotation: it must replace the current key
+// of a registered organisation that is not revoked, be signed by that key or
+// by an Admin, and be signed by a new key that no organisation ever held
+func (bc *Blockchain) ValidateKeyRotation(rotation *KeyRotation) error {
+	if !rotation.Verify() {
+		return errors.New("Key rotation has an invalid signature")
+	}
+
+	org, err := bc.FindOrganisationByPublicKey(rotation.OldPubKey)
+	if err != nil {
+		return errors.New("Key rotation is not for a registered organisation")
+	}
+	if bytes.Compare(bc.GetCurrentKey(org.PubKey), rotation.OldPubKey) != 0 {
+		return fmt.Errorf("Key of organisation %s at %s has already been rotated", org.Name, GetAddressFromPubKey(rotation.OldPubKey))
+	}
+	if bytes.Compare(rotation.PubKey, rotation.OldPubKey) != 0 && bytes.Compare(bc.GetRole(rotation.PubKey), []byte("Admin")) != 0 {
+		return fmt.Errorf("Key rotation of %s is not signed by its key or an Admin", org.Name)
+	}
+	if other, err := bc.FindOrganisationByPublicKey(rotation.NewPubKey); err == nil {
+		return fmt.Errorf("New key is already held by organisation %s", other.Name)
+	}
+	if status, _ := bc.GetOrganisationStatus(org.PubKey); status == revokedStatus {
+		return fmt.Errorf("Organisation %s is revoked", org.Name)
+	}
+
+	return nil
+}
+
+// ValidateRoleChange checks a role change: it must be signed by an Admin and
+// give a registered organisation that is not revoked a new role, leaving at
+// least one Admin
+func (bc *Blockchain) ValidateRoleChange(change *RoleChange) error {
+	if !change.Verify() {
+		return errors.New("Role change has an invalid signature")
+	}
+	if bytes.Compare(bc.GetRole(change.PubKey), []byte("Admin")) != 0 {
+		return errors.New("Role change is not signed by an Admin")
+	}
+
+	org, err := bc.FindOrganisationByPublicKey(change.OrgPubKey)
+	if err != nil || bytes.Compare(org.PubKey, change.OrgPubKey) != 0 {
+		return errors.New("Role change is not for the registered key of an organisation")
+	}
+	if len(change.Role) == 0 {
+		return errors.New("Role change has no role")
+	}
+	if bytes.Compare(org.Role, change.Role) == 0 {
+		return fmt.Errorf("Organisation %s already has role %s", org.Name, change.Role)
+	}
+	if bytes.Compare(org.Role, []byte("Admin")) == 0 && len(bc.GetAdmins()) == 1 {
+		return fmt.Errorf("Organisation %s is the last Admin", org.Name)
+	}
+	if status, _ := bc.GetOrganisationStatus(org.PubKey); status == revokedStatus {
+		return fmt.Errorf("Organisation %s is revoked", org.Name)
+	}
+
+	for _, other := range bc.GetRoleChanges() {
+		if bytes.Compare(other.ID, change.ID) == 0 {
+			return fmt.Errorf("Role change %x is already recorded", change.ID)
+		}
+	}
+
+	return nil
+}
+
+// ValidateApproval checks an approval of a registry change: it must be signed
+// by the current key of an Admin
+func (bc *Blockchain) ValidateApproval(approval *Approval, entry *Entry) error {
+	if !approval.Verify() {
+		return errors.New("Approval has an invalid signature")
+	}
+	if bytes.Compare(approval.Proposal, entry.ID()) != 0 {
+		return fmt.Errorf("Approval %x is not for registry change %x", approval.ID, entry.ID())
+	}
+	if bytes.Compare(bc.GetRole(approval.PubKey), []byte("Admin")) != 0 {
+		return fmt.Errorf("Approval %x is not signed by an Admin", approval.ID)
+	}
+
+	return nil
+}
+
+// checkApprovers checks the approvals of a registry change and returns the
+// keys of the admins approving it, starting with its proposer. No admin may
+// approve it twice.
+func (bc *Blockchain) checkApprovers(entry *Entry, approvals []*Approval) ([][]byte, error) {
+	approvers := [][]byte{entry.Proposer()}
+
+	for _, approval := range approvals {
+		err := bc.ValidateApproval(approval, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, approver := range approvers {
+			if bytes.Compare(approver, approval.PubKey) == 0 {
+				return nil, fmt.Errorf("Admin %s approves registry change %x twice", GetAddressFromPubKey(approval.PubKey), entry.ID())
+			}
+		}
+		approvers = append(approvers, approval.PubKey)
+	}
+
+	return approvers, nil
+}
+
+// ValidateProposal checks a pending registry change and the approvals
+// collected for it so far, however many there are
+func (bc *Blockchain) ValidateProposal(proposal *Proposal) error {
+	if proposal.Entry == nil || !proposal.Entry.IsWellFormed() || proposal.Entry.Proposer() == nil {
+		return errors.New("Proposal is not a registry change signed by an admin")
+	}
+
+	var err error
+	switch proposal.Entry.Type {
+	case organisationEntry:
+		err = bc.ValidateOrganisation(proposal.Entry.Organisation)
+	case statusEntry:
+		err = bc.ValidateStatusChange(proposal.Entry.Status)
+	case roleEntry:
+		err = bc.ValidateRoleChange(proposal.Entry.Role)
+	case keyEntry:
+		err = bc.ValidateKeyRotation(proposal.Entry.Key)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = bc.checkApprovers(proposal.Entry, proposal.Approvals)
+	return err
+}
+
+// ValidateTransactions checks a set of transactions meant for the same block.
+// Besides the per-transaction rules, no two transactions may spend the same
+// output or carry the same item.
+func (u UTXOSet) ValidateTransactions(txs []*Transaction) error {
+	spent := make(map[string]bool)
+	items := make(map[string]bool)
+
+	for _, tx := range txs {
+		err := u.ValidateTransaction(tx)
+		if err != nil {
+			return fmt.Errorf("Transaction %x: %s", tx.ID, err)
+		}
+
+		if !tx.IsCoinbase() {
+			for _, vin := range tx.Vin {
+				key := outpoint(vin.Txid, vin.Vout)
+				if spent[key] {
+					return fmt.Errorf("Transaction %x: output %s is already spent in this block", tx.ID, key)
+				}
+				spent[key] = true
+			}
+		}
+
+		for _, out := range tx.Vout {
+			for _, item := range out.CarriedItems() {
+				if items[item] {
+					return fmt.Errorf("Transaction %x: item %s is already referenced in this block", tx.ID, item)
+				}
+				items[item] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateEntries checks the entries meant for the same block against the
+// ledger up to its tip. An entry cannot depend on another entry of the same
+// block, and no two entries may register the same organisation, product or
+// recall, change the status, key or role of the same organisation, update the
+// same product, take up the same key, reference the same item or record
+// attempts to claim the same item. Every registry change signed by an
+// admin must come with the approvals governance requires, and every approval
+// with its change.
+func (u UTXOSet) ValidateEntries(entries []*Entry) error {
+	var orgs []*Organisation
+	var products []*Product
+	var txs []*Transaction
+	var recalls []*Recall
+	var changes []*StatusChange
+	var rotations []*KeyRotation
+	var roles []*RoleChange
+	var updates []*CatalogUpdate
+	var attempts []*ClaimAttempt
+	approvals := make(map[string][]*Approval)
+
+	if len(entries) == 0 {
+		return errors.New("No entries")
+	}
+
+	for i, e := range entries {
+		if e == nil || !e.IsWellFormed() {
+			return fmt.Errorf("Entry %d is malformed", i)
+		}
+
+		switch e.Type {
+		case organisationEntry:
+			orgs = append(orgs, e.Organisation)
+		case productEntry:
+			products = append(products, e.Product)
+		case transactionEntry:
+			txs = append(txs, e.Transaction)
+		case recallEntry:
+			recalls = append(recalls, e.Recall)
+		case statusEntry:
+			changes = append(changes, e.Status)
+		case keyEntry:
+			rotations = append(rotations, e.Key)
+		case roleEntry:
+			roles = append(roles, e.Role)
+		case catalogEntry:
+			updates = append(updates, e.Catalog)
+		case claimAttemptEntry:
+			attempts = append(attempts, e.ClaimAttempt)
+		case approvalEntry:
+			id := hex.EncodeToString(e.Approval.Proposal)
+			approvals[id] = append(approvals[id], e.Approval)
+		case policyEntry:
+			return errors.New("Transfer policy can only be recorded in the genesis block")
+		case governanceEntry:
+			return errors.New("Governance can only be recorded in the genesis block")
+		case migrationEntry:
+			return errors.New("Migration can only be recorded in the genesis block")
+		}
+	}
+
+	for i, org := range orgs {
+		err := u.Blockchain.ValidateOrganisation(org)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range orgs[:i] {
+			if bytes.Compare(org.GSTIN, other.GSTIN) == 0 || bytes.Compare(org.Prefix, other.Prefix) == 0 || bytes.Compare(org.PubKey, other.PubKey) == 0 {
+				return fmt.Errorf("Organisation %s duplicates %s in the same block", org.Name, other.Name)
+			}
+		}
+	}
+
+	for i, recall := range recalls {
+		err := u.Blockchain.ValidateRecall(recall)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range recalls[:i] {
+			if bytes.Compare(recall.ID, other.ID) == 0 {
+				return fmt.Errorf("Recall %x is repeated in the same block", recall.ID)
+			}
+		}
+	}
+
+	for i, update := range updates {
+		err := u.Blockchain.ValidateCatalogUpdate(update)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range updates[:i] {
+			if bytes.Compare(update.Prefix, other.Prefix) == 0 && update.Code == other.Code {
+				return fmt.Errorf("Product code %d is updated twice in the same block", update.Code)
+			}
+		}
+	}
+
+	for i, attempt := range attempts {
+		err := u.Blockchain.ValidateClaimAttempt(attempt)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range attempts[:i] {
+			if bytes.Compare(attempt.Item, other.Item) == 0 {
+				return fmt.Errorf("Item %s has two claim attempts in the same block", attempt.Item)
+			}
+		}
+	}
+
+	for i, change := range changes {
+		err := u.Blockchain.ValidateStatusChange(change)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range changes[:i] {
+			if bytes.Compare(change.OrgPubKey, other.OrgPubKey) == 0 {
+				return fmt.Errorf("Organisation %s changes status twice in the same block", GetAddressFromPubKey(change.OrgPubKey))
+			}
+		}
+	}
+
+	for i, rotation := range rotations {
+		err := u.Blockchain.ValidateKeyRotation(rotation)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range rotations[:i] {
+			if bytes.Compare(rotation.OldPubKey, other.OldPubKey) == 0 || bytes.Compare(rotation.NewPubKey, other.NewPubKey) == 0 {
+				return fmt.Errorf("Key rotation %x conflicts with %x in the same block", rotation.ID, other.ID)
+			}
+		}
+		for _, org := range orgs {
+			if bytes.Compare(rotation.NewPubKey, org.PubKey) == 0 {
+				return fmt.Errorf("Key rotation %x takes up the key of %s in the same block", rotation.ID, org.Name)
+			}
+		}
+	}
+
+	demoted := 0
+	for i, role := range roles {
+		err := u.Blockchain.ValidateRoleChange(role)
+		if err != nil {
+			return err
+		}
+
+		for _, other := range roles[:i] {
+			if bytes.Compare(role.OrgPubKey, other.OrgPubKey) == 0 {
+				return fmt.Errorf("Organisation %s changes role twice in the same block", GetAddressFromPubKey(role.OrgPubKey))
+			}
+		}
+		// a change is never to the current role, so it demotes every Admin
+		if org, _ := u.Blockchain.FindOrganisationByPublicKey(role.OrgPubKey); bytes.Compare(org.Role, []byte("Admin")) == 0 {
+			demoted++
+		}
+	}
+	if demoted > 0 && demoted >= len(u.Blockchain.GetAdmins()) {
+		return errors.New("Role changes of the block leave no Admin")
+	}
+
+	required := u.Blockchain.RequiredApprovals()
+	for _, e := range entries {
+		if e.Proposer() == nil {
+			continue
+		}
+
+		id := hex.EncodeToString(e.ID())
+		approvers, err := u.Blockchain.checkApprovers(e, approvals[id])
+		if err != nil {
+			return err
+		}
+		if len(approvers) < required {
+			return fmt.Errorf("Registry change %s has %d of the %d admin approvals required", id, len(approvers), required)
+		}
+		delete(approvals, id)
+	}
+	for id := range approvals {
+		return fmt.Errorf("Approval of %s approves no registry change of the block", id)
+	}
+
+	err := u.Blockchain.ValidateProducts(products)
+	if err != nil {
+		return err
+	}
+
+	return u.ValidateTransactions(txs)
+}
+
+// RejectReason classifies why a block was rejected
+type RejectReason int
+
+// Reasons for rejecting a block
+const (
+	RejectMalformed RejectReason = iota
+	RejectInvalidHash
+	RejectInvalidProofOfWork
+	RejectUnknownParent
+	RejectInvalidHeight
+	RejectInvalidEntry
+	RejectInvalidSeal
+)
+
+var rejectReasonNames = map[RejectReason]string{
+	RejectMalformed:          "malformed block",
+	RejectInvalidHash:        "hash does not match contents",
+	RejectInvalidProofOfWork: "invalid proof of work",
+	RejectUnknownParent:      "unknown parent",
+	RejectInvalidHeight:      "invalid height",
+	RejectInvalidEntry:       "invalid entry",
+	RejectInvalidSeal:        "invalid seal",
+}
+
+func (r RejectReason) String() string {
+	return rejectReasonNames[r]
+}
+
+// BlockRejection is returned when a block fails validation
+type BlockRejection struct {
+	Reason RejectReason
+	Err    error
+}
+
+func (r *BlockRejection) Error() string {
+	return fmt.Sprintf("%s: %s", r.Reason, r.Err)
+}
+
+func rejectBlock(reason RejectReason, format string, a ...interface{}) *BlockRejection {
+	return &BlockRejection{reason, fmt.Errorf(format, a...)}
+}
+
+// ValidateBlock runs the validation pipeline on a block received from a peer:
+// its structure, its position after a known parent, the hash committing to
+// the Merkle root of its contents and the seal of the consensus engine, or
+// the proof of work of blocks converted from the three-chain format. The
+// contents are validated by AddBlock when the block is connected. A
+// *BlockRejection is returned for invalid blocks.
+func (bc *Blockchain) ValidateBlock(block *Block) error {
+	if len(block.Entries) == 0 {
+		return rejectBlock(RejectMalformed, "block carries no entries")
+	}
+	for i, e := range block.Entries {
+		if e == nil || !e.IsWellFormed() {
+			return rejectBlock(RejectMalformed, "entry %d is malformed", i)
+		}
+	}
+
+	if len(block.PrevBlockHash) == 0 {
+		return rejectBlock(RejectMalformed, "the genesis block cannot be replaced")
+	}
+
+	// the seal of a PoA block is checked against the registry of its parent
+	parent, err := bc.GetBlock(block.PrevBlockHash)
+	if err != nil {
+		return rejectBlock(RejectUnknownParent, "parent %x is not known", block.PrevBlockHash)
+	}
+	if block.Height != parent.Height+1 {
+		return rejectBlock(RejectInvalidHeight, "height %d does not follow parent height %d", block.Height, parent.Height)
+	}
+
+	return bc.verifySeal(block)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+// Checks an item must pass to be genuine
+const mintedCheck = "minted"
+const manufacturerCheck = "manufacturer"
+const catalogCheck = "catalog"
+const custodyCheck = "custody"
+
+// ItemCheck is the outcome of one check of an item, with what was found
+type ItemCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// ItemVerification answers whether an item is genuine. Conflicts counts the
+// transactions carrying the item outside its chain of custody: events of the
+// ledger that do not continue it, transactions of blocks off the main chain
+// and waiting transactions that can no longer be mined.
+type ItemVerification struct {
+	Item      string
+	Checks    []ItemCheck
+	Conflicts int
+	Owner     string
+}
+
+// Genuine reports whether the item passed every check
+func (v *ItemVerification) Genuine() bool {
+	for _, check := range v.Checks {
+		if !check.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// VerifyItem checks that an SGTIN was minted by a coinbase of the registered,
+// non-revoked Manufacturer whose prefix it carries, that its product code is
+// in the catalog of that manufacturer and that its chain of custody runs
+// unbroken from the mint to the output holding it in the UTXO set
+func (bc *Blockchain) VerifyItem(item string) ItemVerification {
+	trace := bc.TraceItem(item)
+	verification := ItemVerification{item, nil, bc.countConflicts(item, trace.Events), trace.Owner}
+
+	prefix, code, _, err := ParseSGTIN(item)
+	if err != nil {
+		verification.Checks = append(verification.Checks, ItemCheck{mintedCheck, false, err.Error()})
+		return verification
+	}
+
+	if len(trace.Events) == 0 || !trace.Events[0].Minted {
+		verification.Checks = append(verification.Checks, ItemCheck{mintedCheck, false, "Item was never minted"})
+		return verification
+	}
+	mint := trace.Events[0]
+	verification.Checks = append(verification.Checks, ItemCheck{mintedCheck, true, fmt.Sprintf("Minted by coinbase %x at height %d", mint.TxID, mint.Height)})
+
+	minter, err := bc.findMinter(mint)
+	if err != nil {
+		verification.Checks = append(verification.Checks, ItemCheck{manufacturerCheck, false, err.Error()})
+		return verification
+	}
+	verification.Checks = append(verification.Checks, bc.checkManufacturer(minter, prefix))
+
+	address := string(GetAddressFromPubKey(minter))
+	if product, err := bc.FindCatalogProduct(address, code); err != nil {
+		verification.Checks = append(verification.Checks, ItemCheck{catalogCheck, false, fmt.Sprintf("Product code %d is not in the catalog of %s", code, address)})
+	} else {
+		current := product.Current()
+		detail := fmt.Sprintf("Product code %d is %s, version %d", code, current.Name, current.Version)
+		if product.Discontinued() {
+			detail += ", discontinued"
+		}
+		verification.Checks = append(verification.Checks, ItemCheck{catalogCheck, true, detail})
+	}
+
+	verification.Checks = append(verification.Checks, checkCustody(trace))
+
+	return verification
+}
+
+// findMinter returns the key that signed the coinbase of mint. Coinbases
+// converted from the three-chain format are unsigned and minted to their
+// minter, whose registered key is returned.
+func (bc *Blockchain) findMinter(mint ItemEvent) ([]byte, error) {
+	tx, err := bc.FindTransaction(mint.TxID)
+	if err != nil || !tx.IsCoinbase() {
+		return nil, fmt.Errorf("Mint %x is not a coinbase of the ledger", mint.TxID)
+	}
+
+	block, err := bc.GetBlock(mint.BlockHash)
+	if err == nil && bc.IsMigratedBlock(&block) {
+		org, err := bc.FindOrganisationByAddress(mint.Owner)
+		if err != nil {
+			return nil, fmt.Errorf("Minter %s of coinbase %x is not registered", mint.Owner, mint.TxID)
+		}
+
+		return org.PubKey, nil
+	}
+	if !tx.VerifyCoinbase() {
+		return nil, fmt.Errorf("Coinbase %x is not signed by its minter", mint.TxID)
+	}
+
+	return tx.Vin[0].PubKey, nil
+}
+
+// checkManufacturer checks that minter is the key of a registered
+// Manufacturer with prefix that is not revoked
+func (bc *Blockchain) checkManufacturer(minter []byte, prefix string) ItemCheck {
+	org, err := bc.FindOrganisationByPublicKey(minter)
+	if err != nil {
+		return ItemCheck{manufacturerCheck, false, fmt.Sprintf("Minter %s is not a registered organisation", GetAddressFromPubKey(minter))}
+	}
+	if bytes.Compare(org.Role, []byte("Manufacturer")) != 0 {
+		return ItemCheck{manufacturerCheck, false, fmt.Sprintf("Minter %s is not a Manufacturer", org.Name)}
+	}
+	if string(org.Prefix) != prefix {
+		return ItemCheck{manufacturerCheck, false, fmt.Sprintf("Item carries prefix %s but was minted by %s with prefix %s", prefix, org.Name, org.Prefix)}
+	}
+
+	status, change := bc.GetOrganisationStatus(org.PubKey)
+	if status == revokedStatus {
+		return ItemCheck{manufacturerCheck, false, fmt.Sprintf("Manufacturer %s is revoked: %s", org.Name, change.Reason)}
+	}
+
+	return ItemCheck{manufacturerCheck, true, fmt.Sprintf("Minted by %s, %s, with prefix %s", org.Name, status, org.Prefix)}
+}
+
+// checkCustody checks that no event of the chain of custody of an item has
+// issues, which includes the item being held by the output of its last event
+// unless it was decommissioned. Rejected claims are counted as conflicts
+// instead.
+func checkCustody(trace ItemTrace) ItemCheck {
+	events := 0
+	for _, event := range trace.Events {
+		if event.Action == rejectedClaimAction {
+			continue
+		}
+		if len(event.Issues) > 0 {
+			return ItemCheck{custodyCheck, false, fmt.Sprintf("Transaction %x at height %d: %s", event.TxID, event.Height, event.Issues[0])}
+		}
+		events++
+	}
+
+	last := trace.LastEvent()
+	if last.Action == decommissionAction {
+		return ItemCheck{custodyCheck, true, fmt.Sprintf("%d events from the mint to its decommissioning as %s by transaction %x", events, last.Reason, last.TxID)}
+	}
+	return ItemCheck{custodyCheck, true, fmt.Sprintf("%d events from the mint to output %x:%d held by %s", events, last.TxID, last.Index, trace.Owner)}
+}
+
+// countConflicts counts the transactions carrying item outside its chain of
+// custody events: conflicting events, transactions of stored blocks off the
+// main chain and transactions of the mempool that fail validation
+func (bc *Blockchain) countConflicts(item string, events []ItemEvent) int {
+	conflicts := make(map[string]bool)
+	main := make(map[string]bool)
+
+	for _, event := range events {
+		main[hex.EncodeToString(event.TxID)] = true
+		if event.Conflicting {
+			conflicts[hex.EncodeToString(event.TxID)] = true
+		}
+	}
+
+	carries := func(tx *Transaction) bool {
+		for _, out := range tx.Vout {
+			if _, ok := out.Carries(item); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	onChain := make(map[string]bool)
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+		onChain[hex.EncodeToString(block.Hash)] = true
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	err := bc.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(blocksBucket)).ForEach(func(k, v []byte) error {
+			if bytes.Compare(k, []byte("l")) == 0 || onChain[hex.EncodeToString(k)] {
+				return nil
+			}
+
+			for _, t := range DeserializeBlock(v).Transactions() {
+				id := hex.EncodeToString(t.ID)
+				if !main[id] && carries(t) {
+					conflicts[id] = true
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	UTXOSet := UTXOSet{bc}
+	for id, pending := range mempool {
+		if carries(&pending) && UTXOSet.ValidateTransaction(&pending) != nil {
+			conflicts[id] = true
+		}
+	}
+
+	return len(conflicts)
+}
@@ -0,0 +1,1438 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BlockView is the JSON representation of a block
+type BlockView struct {
+	Hash      string      `json:"hash"`
+	PrevHash  string      `json:"prev_hash"`
+	Height    int         `json:"height"`
+	Timestamp int64       `json:"timestamp"`
+	Nonce     int         `json:"nonce"`
+	Validator string      `json:"validator,omitempty"`
+	Sealed    bool        `json:"sealed"`
+	Entries   []EntryView `json:"entries"`
+}
+
+// BlockViews is the JSON representation of a list of blocks
+type BlockViews []BlockView
+
+// EntryView is the JSON representation of a block entry. Only the payload
+// matching Type is set.
+type EntryView struct {
+	Type         string             `json:"type"`
+	Organisation *OrganisationView  `json:"organisation,omitempty"`
+	Product      *ProductView       `json:"product,omitempty"`
+	Transaction  *TransactionView   `json:"transaction,omitempty"`
+	Recall       *RecallView        `json:"recall,omitempty"`
+	Policy       *PolicyView        `json:"policy,omitempty"`
+	Status       *StatusChangeView  `json:"status,omitempty"`
+	Key          *KeyRotationView   `json:"key,omitempty"`
+	Governance   *GovernanceView    `json:"governance,omitempty"`
+	Role         *RoleChangeView    `json:"role,omitempty"`
+	Approval     *ApprovalView      `json:"approval,omitempty"`
+	Catalog      *CatalogUpdateView `json:"catalog,omitempty"`
+	ClaimAttempt *ClaimAttemptView  `json:"claim_attempt,omitempty"`
+	Migration    *MigrationView     `json:"migration,omitempty"`
+}
+
+// TransactionView is the JSON representation of a transaction
+type TransactionView struct {
+	ID       string       `json:"id"`
+	Coinbase bool         `json:"coinbase"`
+	Inputs   []InputView  `json:"inputs"`
+	Outputs  []OutputView `json:"outputs"`
+}
+
+// TransactionViews is the JSON representation of a list of transactions
+type TransactionViews []TransactionView
+
+// InputView is the JSON representation of a transaction input. Code is only
+// set by claims.
+type InputView struct {
+	Txid      string `json:"txid"`
+	Vout      int    `json:"vout"`
+	Signature string `json:"signature"`
+	PubKey    string `json:"pubkey"`
+	Code      string `json:"code,omitempty"`
+}
+
+// OutputView is the JSON representation of a transaction output. Recalled is
+// only set where the output is listed as part of an inventory, and also when
+// the output packs recalled items. ClaimHash is only set on items waiting to
+// be claimed by a consumer, Decommission on items taken out of circulation and
+// OfferTo and OfferExpiry on items offered to another address.
+type OutputView struct {
+	Index        int              `json:"index"`
+	Item         string           `json:"item"`
+	Address      string           `json:"address"`
+	Recalled     bool             `json:"recalled"`
+	Contents     []PackedItemView `json:"contents,omitempty"`
+	Batch        *BatchView       `json:"batch,omitempty"`
+	ClaimHash    string           `json:"claim_hash,omitempty"`
+	Decommission string           `json:"decommission,omitempty"`
+	OfferTo      string           `json:"offer_to,omitempty"`
+	OfferExpiry  int              `json:"offer_expiry,omitempty"`
+}
+
+// PackedItemView is the JSON representation of an item or logistic unit
+// packed in a logistic unit. Batch is only set where the unit is listed as
+// part of an inventory.
+type PackedItemView struct {
+	Item     string           `json:"item"`
+	Recalled bool             `json:"recalled"`
+	Contents []PackedItemView `json:"contents,omitempty"`
+	Batch    *BatchView       `json:"batch,omitempty"`
+}
+
+// BatchView is the JSON representation of the production data of minted
+// items, with dates as YYYY-MM-DD. Status is expired or near_expiry, and only
+// set where the item is listed as part of an inventory, lot or history.
+type BatchView struct {
+	Lot          string `json:"lot,omitempty"`
+	Manufactured string `json:"manufactured,omitempty"`
+	Expiry       string `json:"expiry,omitempty"`
+	Status       string `json:"status,omitempty"`
+}
+
+// InventoryView is the JSON representation of the items owned by an address.
+// Items it offered are listed as outgoing offers instead, and items offered to
+// it as incoming offers.
+type InventoryView struct {
+	Address  string       `json:"address"`
+	Items    []OutputView `json:"items"`
+	Incoming []OutputView `json:"incoming"`
+	Outgoing []OutputView `json:"outgoing"`
+}
+
+// LotView is the JSON representation of the items minted with a lot number
+type LotView struct {
+	Lot   string        `json:"lot"`
+	Items []LotItemView `json:"items"`
+}
+
+// LotItemView is the JSON representation of an item of a lot, with its
+// current owner and the logistic units it is packed in, outermost first
+type LotItemView struct {
+	Item       string    `json:"item"`
+	Owner      string    `json:"owner"`
+	Containers []string  `json:"containers"`
+	Recalled   bool      `json:"recalled"`
+	Batch      BatchView `json:"batch"`
+}
+
+// ProductView is the JSON representation of a catalog entry. Name and
+// Attributes are those of its current version. Version, Status and Versions
+// are only set where the entry is listed as part of a catalog.
+type ProductView struct {
+	ID           string                 `json:"id"`
+	Code         int                    `json:"code"`
+	Name         string                 `json:"name"`
+	Manufacturer string                 `json:"manufacturer"`
+	PubKey       string                 `json:"pubkey"`
+	Signature    string                 `json:"signature"`
+	Attributes   *ProductAttributesView `json:"attributes,omitempty"`
+	Version      int                    `json:"version,omitempty"`
+	Status       string                 `json:"status,omitempty"`
+	Versions     []ProductVersionView   `json:"versions,omitempty"`
+}
+
+// ProductAttributesView is the JSON representation of the attributes of a
+// catalog entry, with the spec hash in hex. Absent fields are omitted.
+type ProductAttributesView struct {
+	GTIN        string `json:"gtin,omitempty"`
+	Description string `json:"description,omitempty"`
+	Brand       string `json:"brand,omitempty"`
+	Category    string `json:"category,omitempty"`
+	HSCode      string `json:"hs_code,omitempty"`
+	NetQuantity string `json:"net_quantity,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+	SpecHash    string `json:"spec_hash,omitempty"`
+}
+
+// ProductVersionView is the JSON representation of a version of a catalog
+// entry. ID is the ID of the product for its registration and of the catalog
+// update otherwise.
+type ProductVersionView struct {
+	ID         string                 `json:"id"`
+	Version    int                    `json:"version"`
+	Action     string                 `json:"action"`
+	Name       string                 `json:"name"`
+	Attributes *ProductAttributesView `json:"attributes,omitempty"`
+	Reason     string                 `json:"reason,omitempty"`
+	Height     int                    `json:"height"`
+	Timestamp  int64                  `json:"timestamp"`
+}
+
+// CatalogUpdateView is the JSON representation of an update or
+// discontinuation of a catalog entry
+type CatalogUpdateView struct {
+	ID           string                 `json:"id"`
+	Manufacturer string                 `json:"manufacturer"`
+	Prefix       string                 `json:"prefix"`
+	Code         int                    `json:"code"`
+	Version      int                    `json:"version"`
+	Action       string                 `json:"action"`
+	Name         string                 `json:"name,omitempty"`
+	Attributes   *ProductAttributesView `json:"attributes,omitempty"`
+	Reason       string                 `json:"reason,omitempty"`
+	Timestamp    int64                  `json:"timestamp"`
+	PubKey       string                 `json:"pubkey"`
+	Signature    string                 `json:"signature"`
+}
+
+// ProductViews is the JSON representation of a catalog
+type ProductViews []ProductView
+
+// OrganisationView is the JSON representation of an organisation. Status, the
+// change that set it and the current key are only set when organisations are
+// looked up.
+type OrganisationView struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	GSTIN       string `json:"gstin"`
+	Prefix      string `json:"prefix"`
+	Role        string `json:"role"`
+	Address     string `json:"address"`
+	PubKey      string `json:"pubkey"`
+	AdminPubKey string `json:"admin_pubkey"`
+	Signature   string `json:"signature"`
+
+	Status         string            `json:"status,omitempty"`
+	StatusChange   *StatusChangeView `json:"status_change,omitempty"`
+	CurrentAddress string            `json:"current_address,omitempty"`
+	CurrentPubKey  string            `json:"current_pubkey,omitempty"`
+}
+
+// OrganisationViews is the JSON representation of a list of organisations
+type OrganisationViews []OrganisationView
+
+// PartyView is the JSON representation of an address with the organisation
+// registered for it, if any
+type PartyView struct {
+	Address      string `json:"address"`
+	Organisation string `json:"organisation,omitempty"`
+	GSTIN        string `json:"gstin,omitempty"`
+	Role         string `json:"role,omitempty"`
+}
+
+// ItemEventView is the JSON representation of an event of the item history.
+// The sender is omitted for the mint. The sender of a rejected claim is the
+// claimant and its receiver the owner, who keeps the item. Reason is only set
+// on decommissionings and OfferedTo on offers, whose receiver is the sender
+// until the offer is accepted.
+type ItemEventView struct {
+	Block      string     `json:"block"`
+	Height     int        `json:"height"`
+	Timestamp  int64      `json:"timestamp"`
+	Txid       string     `json:"txid"`
+	Index      int        `json:"index"`
+	Owner      string     `json:"owner"`
+	Minted     bool       `json:"minted"`
+	Action     string     `json:"action"`
+	Reason     string     `json:"reason,omitempty"`
+	Containers []string   `json:"containers"`
+	Sender     *PartyView `json:"sender,omitempty"`
+	Receiver   PartyView  `json:"receiver"`
+	OfferedTo  *PartyView `json:"offered_to,omitempty"`
+	Issues     []string   `json:"issues"`
+}
+
+// ItemHistoryView is the JSON representation of the chain of custody of an
+// item, oldest event first, with its current owner and the recall covering it
+// if any. Intact is set when no event has issues, not counting rejected
+// claims.
+type ItemHistoryView struct {
+	Item   string          `json:"item"`
+	GS1    *GS1View        `json:"gs1,omitempty"`
+	Batch  *BatchView      `json:"batch,omitempty"`
+	Recall *RecallView     `json:"recall,omitempty"`
+	Owner  *PartyView      `json:"owner,omitempty"`
+	Intact bool            `json:"intact"`
+	Events []ItemEventView `json:"events"`
+}
+
+// ItemVerificationView is the JSON representation of the verification of an
+// item. Genuine is set when every check passed, and conflicts counts the
+// transactions carrying the item outside its chain of custody.
+type ItemVerificationView struct {
+	Item      string          `json:"item"`
+	Genuine   bool            `json:"genuine"`
+	Checks    []ItemCheckView `json:"checks"`
+	Conflicts int             `json:"conflicts"`
+	Owner     *PartyView      `json:"owner,omitempty"`
+}
+
+// ItemCheckView is the JSON representation of a check of an item
+type ItemCheckView struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// GS1View is the JSON representation of the standard forms of an SGTIN
+type GS1View struct {
+	EPC           string `json:"epc"`
+	GTIN          string `json:"gtin"`
+	ElementString string `json:"element_string"`
+	DigitalLink   string `json:"digital_link"`
+}
+
+// RecallView is the JSON representation of a recall
+type RecallView struct {
+	ID           string   `json:"id"`
+	Manufacturer string   `json:"manufacturer"`
+	Prefix       string   `json:"prefix"`
+	Code         int      `json:"code"`
+	Items        []string `json:"items"`
+	FromSerial   int      `json:"from_serial"`
+	ToSerial     int      `json:"to_serial"`
+	Reason       string   `json:"reason"`
+	Timestamp    int64    `json:"timestamp"`
+	PubKey       string   `json:"pubkey"`
+	Signature    string   `json:"signature"`
+}
+
+// RecallViews is the JSON representation of a list of recalls
+type RecallViews []RecallView
+
+// StatusChangeView is the JSON representation of a change of the status of an
+// organisation
+type StatusChangeView struct {
+	ID           string `json:"id"`
+	Organisation string `json:"organisation"`
+	Admin        string `json:"admin"`
+	Action       string `json:"action"`
+	Reason       string `json:"reason"`
+	Height       int    `json:"height"`
+	Timestamp    int64  `json:"timestamp"`
+	PubKey       string `json:"pubkey"`
+	Signature    string `json:"signature"`
+}
+
+// KeyRotationView is the JSON representation of the rotation of the key of an
+// organisation from one address to another
+type KeyRotationView struct {
+	ID           string `json:"id"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Signer       string `json:"signer"`
+	Timestamp    int64  `json:"timestamp"`
+	PubKey       string `json:"pubkey"`
+	Signature    string `json:"signature"`
+	NewPubKey    string `json:"new_pubkey"`
+	NewSignature string `json:"new_signature"`
+}
+
+// RoleChangeView is the JSON representation of a change of the role of an
+// organisation
+type RoleChangeView struct {
+	ID           string `json:"id"`
+	Organisation string `json:"organisation"`
+	Admin        string `json:"admin"`
+	Role         string `json:"role"`
+	Timestamp    int64  `json:"timestamp"`
+	PubKey       string `json:"pubkey"`
+	Signature    string `json:"signature"`
+}
+
+// ClaimAttemptView is the JSON representation of a rejected claim of an item
+type ClaimAttemptView struct {
+	ID        string `json:"id"`
+	Item      string `json:"item"`
+	Claimant  string `json:"claimant"`
+	Code      string `json:"code"`
+	Timestamp int64  `json:"timestamp"`
+	PubKey    string `json:"pubkey"`
+	Signature string `json:"signature"`
+}
+
+// SaleView is the JSON representation of a sale to consumers, with the claim
+// code of each item sold
+type SaleView struct {
+	Transaction TransactionView `json:"transaction"`
+	Codes       []ClaimCodeView `json:"codes"`
+}
+
+// ClaimCodeView is the JSON representation of the claim code of an item
+type ClaimCodeView struct {
+	Item string `json:"item"`
+	Code string `json:"code"`
+}
+
+// GovernanceView is the JSON representation of the number of admins that must
+// approve registry changes
+type GovernanceView struct {
+	ID        string `json:"id"`
+	Threshold int    `json:"threshold"`
+}
+
+// MigrationView is the JSON representation of the blocks converted from the
+// three-chain format, as Merkle roots of their entries
+type MigrationView struct {
+	ID     string   `json:"id"`
+	Blocks []string `json:"blocks"`
+}
+
+// ApprovalView is the JSON representation of the approval of a registry
+// change by an admin
+type ApprovalView struct {
+	ID        string `json:"id"`
+	Proposal  string `json:"proposal"`
+	Admin     string `json:"admin"`
+	Timestamp int64  `json:"timestamp"`
+	PubKey    string `json:"pubkey"`
+	Signature string `json:"signature"`
+}
+
+// ProposalView is the JSON representation of a registry change waiting for
+// the approvals of admins. Approvers starts with the admin who proposed it.
+// Recorded is set once the change is mined.
+type ProposalView struct {
+	ID           string         `json:"id"`
+	Type         string         `json:"type"`
+	Organisation string         `json:"organisation"`
+	Proposer     string         `json:"proposer"`
+	Approvers    []string       `json:"approvers"`
+	Required     int            `json:"required"`
+	Recorded     bool           `json:"recorded"`
+	Change       EntryView      `json:"change"`
+	Approvals    []ApprovalView `json:"approvals"`
+}
+
+// ProposalViews is the JSON representation of a list of proposals
+type ProposalViews []ProposalView
+
+// PolicyView is the JSON representation of a transfer policy. A ledger
+// without a policy is not restricted and has no rules.
+type PolicyView struct {
+	ID         string           `json:"id,omitempty"`
+	Restricted bool             `json:"restricted"`
+	Rules      []PolicyRuleView `json:"rules"`
+}
+
+// PolicyRuleView is the JSON representation of a transfer rule
+type PolicyRuleView struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// RecallHolderView is the JSON representation of an address holding recalled items
+type RecallHolderView struct {
+	Address string   `json:"address"`
+	Items   []string `json:"items"`
+}
+
+// RecallHolderViews is the JSON representation of the recall holders report
+type RecallHolderViews []RecallHolderView
+
+// AddressView is the JSON representation of an address of the wallet
+type AddressView struct {
+	Address string `json:"address"`
+	PubKey  string `json:"pubkey"`
+}
+
+// AddressViews is the JSON representation of the addresses of the wallet
+type AddressViews []AddressView
+
+// UTXOSetView is the JSON representation of the size of the UTXO set
+type UTXOSetView struct {
+	Transactions int `json:"transactions"`
+}
+
+// NewBlockView builds the JSON representation of a block
+func NewBlockView(block *Block) BlockView {
+	view := BlockView{
+		Hash:      hex.EncodeToString(block.Hash),
+		PrevHash:  hex.EncodeToString(block.PrevBlockHash),
+		Height:    block.Height,
+		Timestamp: block.Timestamp,
+		Nonce:     block.Nonce,
+		Validator: hex.EncodeToString(block.Validator),
+		Entries:   []EntryView{},
+	}
+
+	for _, e := range block.Entries {
+		view.Entries = append(view.Entries, NewEntryView(e))
+	}
+
+	return view
+}
+
+// NewEntryView builds the JSON representation of a block entry
+func NewEntryView(e *Entry) EntryView {
+	entry := EntryView{Type: e.Type}
+
+	switch e.Type {
+	case organisationEntry:
+		org := NewOrganisationView(e.Organisation)
+		entry.Organisation = &org
+	case productEntry:
+		p := NewProductView(e.Product)
+		entry.Product = &p
+	case transactionEntry:
+		tx := NewTransactionView(e.Transaction)
+		entry.Transaction = &tx
+	case recallEntry:
+		recall := NewRecallView(e.Recall)
+		entry.Recall = &recall
+	case policyEntry:
+		policy := NewPolicyView(e.Policy)
+		entry.Policy = &policy
+	case statusEntry:
+		change := NewStatusChangeView(e.Status)
+		entry.Status = &change
+	case keyEntry:
+		rotation := NewKeyRotationView(e.Key)
+		entry.Key = &rotation
+	case governanceEntry:
+		governance := GovernanceView{hex.EncodeToString(e.Governance.ID), e.Governance.Threshold}
+		entry.Governance = &governance
+	case roleEntry:
+		change := NewRoleChangeView(e.Role)
+		entry.Role = &change
+	case approvalEntry:
+		approval := NewApprovalView(e.Approval)
+		entry.Approval = &approval
+	case catalogEntry:
+		update := NewCatalogUpdateView(e.Catalog)
+		entry.Catalog = &update
+	case claimAttemptEntry:
+		attempt := NewClaimAttemptView(e.ClaimAttempt)
+		entry.ClaimAttempt = &attempt
+	case migrationEntry:
+		migration := MigrationView{hex.EncodeToString(e.Migration.ID), nil}
+		for _, root := range e.Migration.Blocks {
+			migration.Blocks = append(migration.Blocks, hex.EncodeToString(root))
+		}
+		entry.Migration = &migration
+	}
+
+	return entry
+}
+
+// NewTransactionView builds the JSON representation of a transaction
+func NewTransactionView(tx *Transaction) TransactionView {
+	view := TransactionView{
+		ID:       hex.EncodeToString(tx.ID),
+		Coinbase: tx.IsCoinbase(),
+		Inputs:   []InputView{},
+		Outputs:  []OutputView{},
+	}
+
+	for _, vin := range tx.Vin {
+		view.Inputs = append(view.Inputs, InputView{
+			Txid:      hex.EncodeToString(vin.Txid),
+			Vout:      vin.Vout,
+			Signature: hex.EncodeToString(vin.Signature),
+			PubKey:    hex.EncodeToString(vin.PubKey),
+			Code:      string(vin.Code),
+		})
+	}
+
+	for _, out := range tx.Vout {
+		view.Outputs = append(view.Outputs, NewOutputView(out))
+	}
+
+	return view
+}
+
+// NewOutputView builds the JSON representation of a transaction output
+func NewOutputView(out TXOutput) OutputView {
+	view := OutputView{out.Index, out.Item, string(Base58Encode(out.PubKeyHash)), false, NewPackedItemViews(out.Contents), NewBatchView(out.Batch), hex.EncodeToString(out.ClaimHash), string(out.Decommission), "", out.OfferExpiry}
+	if out.IsOffered() {
+		view.OfferTo = string(Base58Encode(out.OfferTo))
+	}
+
+	return view
+}
+
+// NewPackedItemViews builds the JSON representation of the contents of a
+// logistic unit, nil if it packs nothing
+func NewPackedItemViews(contents []PackedItem) []PackedItemView {
+	var views []PackedItemView
+
+	for _, packed := range contents {
+		views = append(views, PackedItemView{packed.Item, false, NewPackedItemViews(packed.Contents), nil})
+	}
+
+	return views
+}
+
+// packedItems rebuilds the contents of a logistic unit from their views
+func packedItems(views []PackedItemView) []PackedItem {
+	var contents []PackedItem
+
+	for _, view := range views {
+		contents = append(contents, PackedItem{view.Item, packedItems(view.Contents)})
+	}
+
+	return contents
+}
+
+// markRecalled sets Recalled on the packed items covered by recalls or packing
+// such items, and reports whether any is set
+func markRecalled(views []PackedItemView, recalls []Recall) bool {
+	recalled := false
+
+	for i := range views {
+		views[i].Recalled = findRecall(recalls, views[i].Item) != nil
+		if markRecalled(views[i].Contents, recalls) {
+			views[i].Recalled = true
+		}
+		recalled = recalled || views[i].Recalled
+	}
+
+	return recalled
+}
+
+// markBatches sets the batch and expiry status at now of the packed items
+// minted with one of batches
+func markBatches(views []PackedItemView, batches map[string]*Batch, now time.Time) {
+	for i := range views {
+		views[i].Batch = NewBatchStatusView(batches[views[i].Item], now)
+		markBatches(views[i].Contents, batches, now)
+	}
+}
+
+// NewGS1View builds the JSON representation of the standard forms of an SGTIN
+func NewGS1View(ids GS1Identifiers) GS1View {
+	return GS1View{ids.EPC, ids.GTIN, ids.ElementString, ids.DigitalLink}
+}
+
+// NewBatchView builds the JSON representation of production data, nil if
+// there is none
+func NewBatchView(b *Batch) *BatchView {
+	if b == nil {
+		return nil
+	}
+
+	view := &BatchView{Lot: b.Lot}
+	if t, ok := gs1Date(b.Manufactured); ok {
+		view.Manufactured = t.Format(isoDateLayout)
+	}
+	if t, ok := gs1Date(b.Expiry); ok {
+		view.Expiry = t.Format(isoDateLayout)
+	}
+
+	return view
+}
+
+// NewBatchStatusView builds the JSON representation of production data with
+// the expiry status of its items at now
+func NewBatchStatusView(b *Batch, now time.Time) *BatchView {
+	view := NewBatchView(b)
+	if view != nil {
+		view.Status = b.ExpiryStatus(now)
+	}
+
+	return view
+}
+
+// Batch rebuilds the production data described by the view
+func (view *BatchView) Batch() (*Batch, error) {
+	if view == nil {
+		return nil, nil
+	}
+
+	return NewBatch(view.Lot, view.Manufactured, view.Expiry)
+}
+
+// NewProductView builds the JSON representation of a catalog entry
+func NewProductView(p *Product) ProductView {
+	return ProductView{
+		ID:           hex.EncodeToString(p.ID),
+		Code:         p.Code,
+		Name:         string(p.Name),
+		Manufacturer: string(GetAddressFromPubKey(p.PubKey)),
+		PubKey:       hex.EncodeToString(p.PubKey),
+		Signature:    hex.EncodeToString(p.Signature),
+		Attributes:   NewProductAttributesView(p.Attributes),
+	}
+}
+
+// NewCatalogProductView builds the JSON representation of a catalog entry at
+// its current version, with its version history
+func NewCatalogProductView(p *CatalogProduct) ProductView {
+	current := p.Current()
+
+	view := NewProductView(&p.Product)
+	view.Name = string(current.Name)
+	view.Attributes = NewProductAttributesView(current.Attributes)
+	view.Version = current.Version
+	view.Status = activeStatus
+	if p.Discontinued() {
+		view.Status = discontinuedStatus
+	}
+
+	for _, version := range p.Versions {
+		view.Versions = append(view.Versions, ProductVersionView{
+			ID:         hex.EncodeToString(version.ID),
+			Version:    version.Version,
+			Action:     version.Action,
+			Name:       string(version.Name),
+			Attributes: NewProductAttributesView(version.Attributes),
+			Reason:     string(version.Reason),
+			Height:     version.Height,
+			Timestamp:  version.Timestamp,
+		})
+	}
+
+	return view
+}
+
+// NewProductAttributesView builds the JSON representation of the attributes
+// of a catalog entry, nil if there are none
+func NewProductAttributesView(a *ProductAttributes) *ProductAttributesView {
+	if a == nil {
+		return nil
+	}
+
+	return &ProductAttributesView{a.GTIN, a.Description, a.Brand, a.Category, a.HSCode, a.NetQuantity, a.Unit, hex.EncodeToString(a.SpecHash)}
+}
+
+// Attributes rebuilds the attributes described by the view
+func (view *ProductAttributesView) Attributes() (*ProductAttributes, error) {
+	if view == nil {
+		return nil, nil
+	}
+
+	return NewProductAttributes(view.GTIN, view.Description, view.Brand, view.Category, view.HSCode, view.NetQuantity, view.Unit, view.SpecHash)
+}
+
+// NewCatalogUpdateView builds the JSON representation of a catalog update
+func NewCatalogUpdateView(update *CatalogUpdate) CatalogUpdateView {
+	return CatalogUpdateView{
+		ID:           hex.EncodeToString(update.ID),
+		Manufacturer: string(GetAddressFromPubKey(update.PubKey)),
+		Prefix:       string(update.Prefix),
+		Code:         update.Code,
+		Version:      update.Version,
+		Action:       string(update.Action),
+		Name:         string(update.Name),
+		Attributes:   NewProductAttributesView(update.Attributes),
+		Reason:       string(update.Reason),
+		Timestamp:    update.Timestamp,
+		PubKey:       hex.EncodeToString(update.PubKey),
+		Signature:    hex.EncodeToString(update.Signature),
+	}
+}
+
+// NewOrganisationView builds the JSON representation of an organisation
+func NewOrganisationView(org *Organisation) OrganisationView {
+	return OrganisationView{
+		ID:          hex.EncodeToString(org.ID),
+		Name:        string(org.Name),
+		GSTIN:       string(org.GSTIN),
+		Prefix:      string(org.Prefix),
+		Role:        string(org.Role),
+		Address:     string(GetAddressFromPubKey(org.PubKey)),
+		PubKey:      hex.EncodeToString(org.PubKey),
+		AdminPubKey: hex.EncodeToString(org.AdminPubKey),
+		Signature:   hex.EncodeToString(org.Signature),
+	}
+}
+
+// NewOrganisationStatusView builds the JSON representation of an organisation
+// with its status at block height height according to changes and its current
+// key according to rotations
+func NewOrganisationStatusView(org *Organisation, changes []StatusChange, rotations []KeyRotation, height int) OrganisationView {
+	view := NewOrganisationView(org)
+
+	keys := keysOf(rotations, org.PubKey)
+	view.CurrentAddress = string(GetAddressFromPubKey(keys[len(keys)-1]))
+	view.CurrentPubKey = hex.EncodeToString(keys[len(keys)-1])
+
+	status, change := findStatus(changes, org.PubKey, height)
+	view.Status = status
+	if change != nil {
+		changeView := NewStatusChangeView(change)
+		view.StatusChange = &changeView
+	}
+
+	return view
+}
+
+// NewStatusChangeView builds the JSON representation of a status change
+func NewStatusChangeView(change *StatusChange) StatusChangeView {
+	return StatusChangeView{
+		ID:           hex.EncodeToString(change.ID),
+		Organisation: string(GetAddressFromPubKey(change.OrgPubKey)),
+		Admin:        string(GetAddressFromPubKey(change.PubKey)),
+		Action:       string(change.Action),
+		Reason:       string(change.Reason),
+		Height:       change.Height,
+		Timestamp:    change.Timestamp,
+		PubKey:       hex.EncodeToString(change.PubKey),
+		Signature:    hex.EncodeToString(change.Signature),
+	}
+}
+
+// NewKeyRotationView builds the JSON representation of a key rotation
+func NewKeyRotationView(rotation *KeyRotation) KeyRotationView {
+	return KeyRotationView{
+		ID:           hex.EncodeToString(rotation.ID),
+		From:         string(GetAddressFromPubKey(rotation.OldPubKey)),
+		To:           string(GetAddressFromPubKey(rotation.NewPubKey)),
+		Signer:       string(GetAddressFromPubKey(rotation.PubKey)),
+		Timestamp:    rotation.Timestamp,
+		PubKey:       hex.EncodeToString(rotation.PubKey),
+		Signature:    hex.EncodeToString(rotation.Signature),
+		NewPubKey:    hex.EncodeToString(rotation.NewPubKey),
+		NewSignature: hex.EncodeToString(rotation.NewSignature),
+	}
+}
+
+// NewRoleChangeView builds the JSON representation of a role change
+func NewRoleChangeView(change *RoleChange) RoleChangeView {
+	return RoleChangeView{
+		ID:           hex.EncodeToString(change.ID),
+		Organisation: string(GetAddressFromPubKey(change.OrgPubKey)),
+		Admin:        string(GetAddressFromPubKey(change.PubKey)),
+		Role:         string(change.Role),
+		Timestamp:    change.Timestamp,
+		PubKey:       hex.EncodeToString(change.PubKey),
+		Signature:    hex.EncodeToString(change.Signature),
+	}
+}
+
+// NewApprovalView builds the JSON representation of an approval
+func NewApprovalView(approval *Approval) ApprovalView {
+	return ApprovalView{
+		ID:        hex.EncodeToString(approval.ID),
+		Proposal:  hex.EncodeToString(approval.Proposal),
+		Admin:     string(GetAddressFromPubKey(approval.PubKey)),
+		Timestamp: approval.Timestamp,
+		PubKey:    hex.EncodeToString(approval.PubKey),
+		Signature: hex.EncodeToString(approval.Signature),
+	}
+}
+
+// NewClaimAttemptView builds the JSON representation of a rejected claim
+func NewClaimAttemptView(attempt *ClaimAttempt) ClaimAttemptView {
+	return ClaimAttemptView{
+		ID:        hex.EncodeToString(attempt.ID),
+		Item:      string(attempt.Item),
+		Claimant:  string(GetAddressFromPubKey(attempt.PubKey)),
+		Code:      string(attempt.Code),
+		Timestamp: attempt.Timestamp,
+		PubKey:    hex.EncodeToString(attempt.PubKey),
+		Signature: hex.EncodeToString(attempt.Signature),
+	}
+}
+
+// NewSaleView builds the JSON representation of a sale, with codes in the
+// order of the outputs of the transaction
+func NewSaleView(tx *Transaction, codes []string) SaleView {
+	view := SaleView{NewTransactionView(tx), []ClaimCodeView{}}
+
+	for i, code := range codes {
+		view.Codes = append(view.Codes, ClaimCodeView{tx.Vout[i].Item, code})
+	}
+
+	return view
+}
+
+// NewProposalView builds the JSON representation of a proposal needing
+// required approvals
+func NewProposalView(proposal *Proposal, required int) ProposalView {
+	view := ProposalView{
+		ID:        hex.EncodeToString(proposal.ID()),
+		Type:      proposal.Entry.Type,
+		Proposer:  string(GetAddressFromPubKey(proposal.Entry.Proposer())),
+		Approvers: []string{},
+		Required:  required,
+		Change:    NewEntryView(proposal.Entry),
+		Approvals: []ApprovalView{},
+	}
+
+	switch proposal.Entry.Type {
+	case organisationEntry:
+		view.Organisation = string(GetAddressFromPubKey(proposal.Entry.Organisation.PubKey))
+	case statusEntry:
+		view.Organisation = string(GetAddressFromPubKey(proposal.Entry.Status.OrgPubKey))
+	case roleEntry:
+		view.Organisation = string(GetAddressFromPubKey(proposal.Entry.Role.OrgPubKey))
+	case keyEntry:
+		view.Organisation = string(GetAddressFromPubKey(proposal.Entry.Key.OldPubKey))
+	}
+
+	for _, approver := range proposal.Approvers() {
+		view.Approvers = append(view.Approvers, string(GetAddressFromPubKey(approver)))
+	}
+	for _, approval := range proposal.Approvals {
+		view.Approvals = append(view.Approvals, NewApprovalView(approval))
+	}
+
+	return view
+}
+
+// NewRecallView builds the JSON representation of a recall
+func NewRecallView(recall *Recall) RecallView {
+	items := recall.Items
+	if items == nil {
+		items = []string{}
+	}
+
+	return RecallView{
+		ID:           hex.EncodeToString(recall.ID),
+		Manufacturer: string(GetAddressFromPubKey(recall.PubKey)),
+		Prefix:       string(recall.Prefix),
+		Code:         recall.Code,
+		Items:        items,
+		FromSerial:   recall.FromSerial,
+		ToSerial:     recall.ToSerial,
+		Reason:       string(recall.Reason),
+		Timestamp:    recall.Timestamp,
+		PubKey:       hex.EncodeToString(recall.PubKey),
+		Signature:    hex.EncodeToString(recall.Signature),
+	}
+}
+
+// NewPolicyView builds the JSON representation of a transfer policy, which may be nil
+func NewPolicyView(policy *TransferPolicy) PolicyView {
+	view := PolicyView{Rules: []PolicyRuleView{}}
+	if policy == nil {
+		return view
+	}
+
+	view.ID = hex.EncodeToString(policy.ID)
+	view.Restricted = true
+	for _, rule := range policy.Rules {
+		view.Rules = append(view.Rules, PolicyRuleView{string(rule.From), string(rule.To)})
+	}
+
+	return view
+}
+
+// NewItemVerificationView builds the JSON representation of the verification
+// of an item, resolving its owner among orgs and their key rotations
+func NewItemVerificationView(verification ItemVerification, orgs []Organisation, rotations []KeyRotation) ItemVerificationView {
+	view := ItemVerificationView{verification.Item, verification.Genuine(), []ItemCheckView{}, verification.Conflicts, nil}
+
+	for _, check := range verification.Checks {
+		view.Checks = append(view.Checks, ItemCheckView{check.Name, check.Passed, check.Detail})
+	}
+
+	if verification.Owner != "" {
+		owner := NewPartyView(verification.Owner, orgs, rotations)
+		view.Owner = &owner
+	}
+
+	return view
+}
+
+// NewPartyView resolves an address to the organisation of orgs holding or
+// having held it according to rotations
+func NewPartyView(address string, orgs []Organisation, rotations []KeyRotation) PartyView {
+	if org, ok := findOrganisationByAddress(address, orgs, rotations); ok {
+		return PartyView{address, string(org.Name), string(org.GSTIN), string(org.Role)}
+	}
+
+	return PartyView{Address: address}
+}
+
+// NewItemEventView builds the JSON representation of an event of the item
+// history, resolving its parties among orgs and their key rotations
+func NewItemEventView(event ItemEvent, orgs []Organisation, rotations []KeyRotation) ItemEventView {
+	view := ItemEventView{
+		Block:      hex.EncodeToString(event.BlockHash),
+		Height:     event.Height,
+		Timestamp:  event.Timestamp,
+		Txid:       hex.EncodeToString(event.TxID),
+		Index:      event.Index,
+		Owner:      event.Owner,
+		Minted:     event.Minted,
+		Action:     event.Action,
+		Reason:     event.Reason,
+		Containers: event.Containers,
+		Receiver:   NewPartyView(event.Owner, orgs, rotations),
+		Issues:     event.Issues,
+	}
+
+	if view.Containers == nil {
+		view.Containers = []string{}
+	}
+
+	if event.Sender != "" {
+		sender := NewPartyView(event.Sender, orgs, rotations)
+		view.Sender = &sender
+	}
+	if event.OfferedTo != "" {
+		offeredTo := NewPartyView(event.OfferedTo, orgs, rotations)
+		view.OfferedTo = &offeredTo
+	}
+	if view.Issues == nil {
+		view.Issues = []string{}
+	}
+
+	return view
+}
+
+// NewItemHistoryView builds the JSON representation of the chain of custody of
+// an item, resolving its parties among orgs and their key rotations
+func NewItemHistoryView(trace ItemTrace, orgs []Organisation, rotations []KeyRotation) ItemHistoryView {
+	view := ItemHistoryView{Item: trace.Item, Intact: true, Events: []ItemEventView{}}
+
+	for _, event := range trace.Events {
+		view.Events = append(view.Events, NewItemEventView(event, orgs, rotations))
+		if len(event.Issues) > 0 && event.Action != rejectedClaimAction {
+			view.Intact = false
+		}
+	}
+
+	if trace.Owner != "" {
+		owner := NewPartyView(trace.Owner, orgs, rotations)
+		view.Owner = &owner
+	}
+
+	return view
+}
+
+// Transaction rebuilds the transaction described by the view. Like the ID of
+// transactions built by the node, the ID is the hash of the transaction before
+// its inputs are signed; when given it must match.
+func (view TransactionView) Transaction() (*Transaction, error) {
+	var tx Transaction
+	var signatures [][]byte
+
+	for i, in := range view.Inputs {
+		txID, err := hex.DecodeString(in.Txid)
+		if err != nil {
+			return nil, fmt.Errorf("Input %d has an invalid txid", i)
+		}
+		signature, err := hex.DecodeString(in.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("Input %d has an invalid signature", i)
+		}
+		pubKey, err := hex.DecodeString(in.PubKey)
+		if err != nil {
+			return nil, fmt.Errorf("Input %d has an invalid pubkey", i)
+		}
+
+		tx.Vin = append(tx.Vin, TXInput{txID, in.Vout, nil, pubKey, []byte(in.Code)})
+		signatures = append(signatures, signature)
+	}
+
+	for i, out := range view.Outputs {
+		if !ValidateAddress(out.Address) {
+			return nil, fmt.Errorf("Output %d has an invalid address", i)
+		}
+
+		batch, err := out.Batch.Batch()
+		if err != nil {
+			return nil, fmt.Errorf("Output %d has an invalid batch: %s", i, err)
+		}
+		claimHash, err := hex.DecodeString(out.ClaimHash)
+		if err != nil {
+			return nil, fmt.Errorf("Output %d has an invalid claim hash", i)
+		}
+
+		output := NewTXOutput(out.Index, out.Item, out.Address)
+		output.Contents = packedItems(out.Contents)
+		output.Batch = batch
+		if len(claimHash) > 0 {
+			output.ClaimHash = claimHash
+		}
+		if out.Decommission != "" {
+			output.Decommission = []byte(out.Decommission)
+		}
+		if out.OfferTo != "" {
+			if !ValidateAddress(out.OfferTo) {
+				return nil, fmt.Errorf("Output %d is offered to an invalid address", i)
+			}
+			output.OfferTo = Base58Decode([]byte(out.OfferTo))
+			output.OfferExpiry = out.OfferExpiry
+		}
+		tx.Vout = append(tx.Vout, *output)
+	}
+
+	tx.ID = tx.Hash()
+	if view.ID != "" && view.ID != hex.EncodeToString(tx.ID) {
+		return nil, fmt.Errorf("ID does not match the transaction, expected %x", tx.ID)
+	}
+
+	for i, signature := range signatures {
+		tx.Vin[i].Signature = signature
+	}
+
+	return &tx, nil
+}
+
+// String returns the entry as printed by the CLI
+func (view EntryView) String() string {
+	switch view.Type {
+	case organisationEntry:
+		return view.Organisation.String()
+	case productEntry:
+		return view.Product.String()
+	case transactionEntry:
+		return view.Transaction.String()
+	case recallEntry:
+		return view.Recall.String()
+	case policyEntry:
+		return view.Policy.String()
+	case statusEntry:
+		return view.Status.String()
+	case keyEntry:
+		return view.Key.String()
+	case governanceEntry:
+		return view.Governance.String()
+	case roleEntry:
+		return view.Role.String()
+	case approvalEntry:
+		return view.Approval.String()
+	case catalogEntry:
+		return view.Catalog.String()
+	case claimAttemptEntry:
+		return view.ClaimAttempt.String()
+	case migrationEntry:
+		return view.Migration.String()
+	}
+
+	return fmt.Sprintf("--- Unknown entry %s", view.Type)
+}
+
+// String returns the transaction as printed by the CLI
+func (view TransactionView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Transaction %s:", view.ID))
+
+	for i, input := range view.Inputs {
+		lines = append(lines, fmt.Sprintf("     Input %d:", i))
+		lines = append(lines, fmt.Sprintf("       TXID:      %s", input.Txid))
+		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
+		lines = append(lines, fmt.Sprintf("       Signature: %s", input.Signature))
+		lines = append(lines, fmt.Sprintf("       PubKey:    %s", input.PubKey))
+		if input.Code != "" {
+			lines = append(lines, fmt.Sprintf("       Code:      %s", input.Code))
+		}
+	}
+
+	for i, output := range view.Outputs {
+		lines = append(lines, fmt.Sprintf("     Output %d:", i))
+		lines = append(lines, fmt.Sprintf("       ProductID:  %s", output.Item))
+		if len(output.Contents) > 0 {
+			lines = append(lines, fmt.Sprintf("       Contents: %s", formatPackedItemViews(output.Contents)))
+		}
+		if output.Batch != nil {
+			lines = append(lines, fmt.Sprintf("       Batch: %s", output.Batch))
+		}
+		if output.ClaimHash != "" {
+			lines = append(lines, fmt.Sprintf("       Claim hash: %s", output.ClaimHash))
+		}
+		if output.Decommission != "" {
+			lines = append(lines, fmt.Sprintf("       Decommissioned: %s", output.Decommission))
+		}
+		if output.OfferTo != "" {
+			lines = append(lines, fmt.Sprintf("       Offered to: %s", output.OfferTo))
+		}
+		if output.OfferExpiry > 0 {
+			lines = append(lines, fmt.Sprintf("       Offer expiry: %d", output.OfferExpiry))
+		}
+		lines = append(lines, fmt.Sprintf("       Address: %s", output.Address))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatPackedItemViews lists packed items as printed by the CLI, each logistic
+// unit followed by its contents in brackets
+func formatPackedItemViews(views []PackedItemView) string {
+	var items []string
+
+	for _, view := range views {
+		item := view.Item
+		if view.Recalled {
+			item += " (RECALLED)"
+		}
+		item += view.Batch.Label()
+		if len(view.Contents) > 0 {
+			item += fmt.Sprintf(" [%s]", formatPackedItemViews(view.Contents))
+		}
+		items = append(items, item)
+	}
+
+	return strings.Join(items, ", ")
+}
+
+// String returns the production data as printed by the CLI
+func (view BatchView) String() string {
+	var fields []string
+
+	if view.Lot != "" {
+		fields = append(fields, "Lot "+view.Lot)
+	}
+	if view.Manufactured != "" {
+		fields = append(fields, "manufactured "+view.Manufactured)
+	}
+	if view.Expiry != "" {
+		fields = append(fields, "expires "+view.Expiry)
+	}
+
+	return strings.Join(fields, ", ")
+}
+
+// Label marks an item listed by the CLI as expired or near expiry, empty if it
+// is neither
+func (view *BatchView) Label() string {
+	if view == nil {
+		return ""
+	}
+
+	switch view.Status {
+	case expiredStatus:
+		return fmt.Sprintf(" (EXPIRED %s)", view.Expiry)
+	case nearExpiryStatus:
+		return fmt.Sprintf(" (EXPIRES %s)", view.Expiry)
+	}
+
+	return ""
+}
+
+// String returns the catalog entry as printed by the CLI
+func (view ProductView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Product %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       Name:       %s", view.Name))
+	lines = append(lines, fmt.Sprintf("       Code:       %d", view.Code))
+	lines = append(lines, view.Attributes.lines()...)
+	lines = append(lines, fmt.Sprintf("       Signature: %s", view.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %s", view.PubKey))
+
+	return strings.Join(lines, "\n")
+}
+
+// fields returns the names and values of the attributes that are set, as
+// printed by the CLI
+func (view *ProductAttributesView) fields() [][2]string {
+	var fields [][2]string
+	if view == nil {
+		return fields
+	}
+
+	for _, field := range [][2]string{
+		{"GTIN", view.GTIN},
+		{"Description", view.Description},
+		{"Brand", view.Brand},
+		{"Category", view.Category},
+		{"HS code", view.HSCode},
+		{"Net quantity", strings.TrimSpace(view.NetQuantity + " " + view.Unit)},
+		{"Spec hash", view.SpecHash},
+	} {
+		if field[1] != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// lines returns the attributes that are set, one per line as printed by the CLI
+func (view *ProductAttributesView) lines() []string {
+	var lines []string
+
+	for _, field := range view.fields() {
+		lines = append(lines, fmt.Sprintf("       %s:       %s", field[0], field[1]))
+	}
+
+	return lines
+}
+
+// String returns the catalog update as printed by the CLI
+func (view CatalogUpdateView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Catalog update %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       Code:       %d", view.Code))
+	lines = append(lines, fmt.Sprintf("       Version:       %d", view.Version))
+	lines = append(lines, fmt.Sprintf("       Action:       %s", view.Action))
+	if view.Name != "" {
+		lines = append(lines, fmt.Sprintf("       Name:       %s", view.Name))
+	}
+	lines = append(lines, view.Attributes.lines()...)
+	if view.Reason != "" {
+		lines = append(lines, fmt.Sprintf("       Reason:       %s", view.Reason))
+	}
+	lines = append(lines, fmt.Sprintf("       Signature: %s", view.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %s", view.PubKey))
+
+	return strings.Join(lines, "\n")
+}
+
+// String returns the organisation as printed by the CLI
+func (view OrganisationView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Organisation %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       Name:       %s", view.Name))
+	lines = append(lines, fmt.Sprintf("       GSTIN:       %s", view.GSTIN))
+	lines = append(lines, fmt.Sprintf("       Prefix:       %s", view.Prefix))
+	lines = append(lines, fmt.Sprintf("       Role:       %s", view.Role))
+	lines = append(lines, fmt.Sprintf("       Address:       %s", view.Address))
+	lines = append(lines, fmt.Sprintf("       Signature: %s", view.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %s", view.PubKey))
+	lines = append(lines, fmt.Sprintf("       AdminPubKey: %s", view.AdminPubKey))
+	if view.StatusChange != nil {
+		lines = append(lines, fmt.Sprintf("       Status:       %s since height %d: %s", view.Status, view.StatusChange.Height, view.StatusChange.Reason))
+	} else if view.Status != "" {
+		lines = append(lines, fmt.Sprintf("       Status:       %s", view.Status))
+	}
+	if view.CurrentAddress != "" && view.CurrentAddress != view.Address {
+		lines = append(lines, fmt.Sprintf("       Current address:       %s", view.CurrentAddress))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Scope describes the items covered by the recall
+func (view RecallView) Scope() string {
+	recall := Recall{Items: view.Items, Code: view.Code, FromSerial: view.FromSerial, ToSerial: view.ToSerial}
+
+	return recall.Scope()
+}
+
+// String returns the recall as printed by the CLI
+func (view RecallView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Recall %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       Manufacturer:       %s", view.Manufacturer))
+	lines = append(lines, fmt.Sprintf("       Scope:       %s", view.Scope()))
+	lines = append(lines, fmt.Sprintf("       Reason:       %s", view.Reason))
+	lines = append(lines, fmt.Sprintf("       Signature: %s", view.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %s", view.PubKey))
+
+	return strings.Join(lines, "\n")
+}
+
+// String returns the status change as printed by the CLI
+func (view StatusChangeView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Status change %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       Organisation:       %s", view.Organisation))
+	lines = append(lines, fmt.Sprintf("       Action:       %s", view.Action))
+	lines = append(lines, fmt.Sprintf("       Reason:       %s", view.Reason))
+	lines = append(lines, fmt.Sprintf("       Height:       %d", view.Height))
+	lines = append(lines, fmt.Sprintf("       Signature: %s", view.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %s", view.PubKey))
+
+	return strings.Join(lines, "\n")
+}
+
+// String returns the key rotation as printed by the CLI
+func (view KeyRotationView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Key rotation %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       From:       %s", view.From))
+	lines = append(lines, fmt.Sprintf("       To:       %s", view.To))
+	lines = append(lines, fmt.Sprintf("       Signer:       %s", view.Signer))
+	lines = append(lines, fmt.Sprintf("       Signature: %s", view.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %s", view.PubKey))
+	lines = append(lines, fmt.Sprintf("       NewSignature: %s", view.NewSignature))
+
+	return strings.Join(lines, "\n")
+}
+
+// String returns the role change as printed by the CLI
+func (view RoleChangeView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Role change %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       Organisation:       %s", view.Organisation))
+	lines = append(lines, fmt.Sprintf("       Role:       %s", view.Role))
+	lines = append(lines, fmt.Sprintf("       Signature: %s", view.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %s", view.PubKey))
+
+	return strings.Join(lines, "\n")
+}
+
+// String returns the governance as printed by the CLI
+func (view GovernanceView) String() string {
+	return fmt.Sprintf("--- Governance %s: registry changes need %d admins", view.ID, view.Threshold)
+}
+
+// String returns the migration as printed by the CLI
+func (view MigrationView) String() string {
+	return fmt.Sprintf("--- Migration %s: %d blocks converted from the three-chain format", view.ID, len(view.Blocks))
+}
+
+// String returns the claim attempt as printed by the CLI
+func (view ClaimAttemptView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Claim attempt %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       Item:       %s", view.Item))
+	lines = append(lines, fmt.Sprintf("       Claimant:       %s", view.Claimant))
+	lines = append(lines, fmt.Sprintf("       Code:       %s", view.Code))
+	lines = append(lines, fmt.Sprintf("       Signature: %s", view.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %s", view.PubKey))
+
+	return strings.Join(lines, "\n")
+}
+
+// String returns the approval as printed by the CLI
+func (view ApprovalView) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Approval %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       Proposal:       %s", view.Proposal))
+	lines = append(lines, fmt.Sprintf("       Admin:       %s", view.Admin))
+	lines = append(lines, fmt.Sprintf("       Signature: %s", view.Signature))
+	lines = append(lines, fmt.Sprintf("       PubKey: %s", view.PubKey))
+
+	return strings.Join(lines, "\n")
+}
+
+// String returns the proposal as printed by the CLI, followed by the change
+func (view ProposalView) String() string {
+	var lines []string
+
+	status := "pending"
+	if view.Recorded {
+		status = "recorded"
+	}
+
+	lines = append(lines, fmt.Sprintf("--- Proposal %s:", view.ID))
+	lines = append(lines, fmt.Sprintf("       Change:       %s of %s", view.Type, view.Organisation))
+	lines = append(lines, fmt.Sprintf("       Proposer:       %s", view.Proposer))
+	lines = append(lines, fmt.Sprintf("       Approvals:       %d of %d: %s", len(view.Approvers), view.Required, strings.Join(view.Approvers, ", ")))
+	lines = append(lines, fmt.Sprintf("       Status:       %s", status))
+	lines = append(lines, view.Change.String())
+
+	return strings.Join(lines, "\n")
+}
+
+// String returns the transfer policy as printed by the CLI
+func (view PolicyView) String() string {
+	if !view.Restricted {
+		return "--- Transfer policy: any role may send items to any role"
+	}
+
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("--- Transfer policy %s:", view.ID))
+	for _, rule := range view.Rules {
+		lines = append(lines, fmt.Sprintf("       %s -> %s", rule.From, rule.To))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// String returns the party as printed by the CLI
+func (view PartyView) String() string {
+	if view.Organisation == "" {
+		return view.Address
+	}
+
+	return fmt.Sprintf("%s (GSTIN %s, %s) %s", view.Organisation, view.GSTIN, view.Role, view.Address)
+}
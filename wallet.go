@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"log"
+	"math/big"
 
 	"golang.org/x/crypto/ripemd160"
 )
@@ -30,7 +31,12 @@ func NewWallet() *Wallet {
 
 // GetAddress returns wallet address
 func (w Wallet) GetAddress() []byte {
-	pubKeyHash := HashPubKey(w.PublicKey)
+	return GetAddressFromPubKey(w.PublicKey)
+}
+
+// GetAddressFromPubKey returns the address of a public key
+func GetAddressFromPubKey(pubKey []byte) []byte {
+	pubKeyHash := HashPubKey(pubKey)
 
 	versionedPayload := append([]byte{version}, pubKeyHash...)
 	checksum := checksum(versionedPayload)
@@ -59,6 +65,9 @@ func HashPubKey(pubKey []byte) []byte {
 // ValidateAddress check if address if valid
 func ValidateAddress(address string) bool {
 	pubKeyHash := Base58Decode([]byte(address))
+	if len(pubKeyHash) <= addressChecksumLen {
+		return false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
 	version := pubKeyHash[0]
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
@@ -81,7 +90,45 @@ func newKeyPair() (ecdsa.PrivateKey, []byte) {
 	if err != nil {
 		log.Panic(err)
 	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	// fixed width, as keys are split in half to verify signatures
+	pubKey := make([]byte, 64)
+	private.PublicKey.X.FillBytes(pubKey[:32])
+	private.PublicKey.Y.FillBytes(pubKey[32:])
 
 	return *private, pubKey
 }
+
+// signDigest signs digest with a fixed length signature
+func signDigest(privKey ecdsa.PrivateKey, digest []byte) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, digest)
+	if err != nil {
+		log.Panic(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signature
+}
+
+// verifyDigest checks a signature made by signDigest with the key pubKey
+func verifyDigest(pubKey, digest, signature []byte) bool {
+	if len(signature) != 64 || len(pubKey) == 0 {
+		return false
+	}
+
+	r := big.Int{}
+	s := big.Int{}
+	r.SetBytes(signature[:32])
+	s.SetBytes(signature[32:])
+
+	x := big.Int{}
+	y := big.Int{}
+	keyLen := len(pubKey)
+	x.SetBytes(pubKey[:(keyLen / 2)])
+	y.SetBytes(pubKey[(keyLen / 2):])
+
+	rawPubKey := ecdsa.PublicKey{Curve: elliptic.P256(), X: &x, Y: &y}
+
+	return ecdsa.Verify(&rawPubKey, digest, &r, &s)
+}
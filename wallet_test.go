@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// Signatures and keys with leading zero bytes must verify, which happens for
+// about one signature in a hundred
+func TestSignDigestFixedWidth(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		wallet := NewWallet()
+		digest := sha256.Sum256([]byte(fmt.Sprintf("digest %d", i)))
+
+		signature := signDigest(wallet.PrivateKey, digest[:])
+		if len(signature) != 64 || len(wallet.PublicKey) != 64 {
+			t.Fatalf("signature of %d bytes and key of %d bytes, expected 64", len(signature), len(wallet.PublicKey))
+		}
+		if !verifyDigest(wallet.PublicKey, digest[:], signature) {
+			t.Fatalf("signature %x of key %x does not verify", signature, wallet.PublicKey)
+		}
+
+		other := sha256.Sum256([]byte(fmt.Sprintf("other %d", i)))
+		if verifyDigest(wallet.PublicKey, other[:], signature) {
+			t.Fatalf("signature %x verifies another digest", signature)
+		}
+	}
+}
+
+func TestVerifyDigest(t *testing.T) {
+	wallet := NewWallet()
+	digest := sha256.Sum256([]byte("digest"))
+	signature := signDigest(wallet.PrivateKey, digest[:])
+
+	tests := []struct {
+		name      string
+		pubKey    []byte
+		signature []byte
+		valid     bool
+	}{
+		{"valid", wallet.PublicKey, signature, true},
+		{"other key", NewWallet().PublicKey, signature, false},
+		{"no key", nil, signature, false},
+		{"no signature", wallet.PublicKey, nil, false},
+		{"truncated signature", wallet.PublicKey, signature[:63], false},
+	}
+
+	for _, test := range tests {
+		if valid := verifyDigest(test.pubKey, digest[:], test.signature); valid != test.valid {
+			t.Errorf("%s: verifyDigest returned %t, expected %t", test.name, valid, test.valid)
+		}
+	}
+}